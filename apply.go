@@ -0,0 +1,198 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// applyStats counts the outcome of applying a plan
+type applyStats struct {
+	transferred, rejected, skipped, failed, moved, duplicates int
+}
+
+// runApplyCommand carries out a plan file written by plan, exactly as planned
+func runApplyCommand(args []string) error {
+	var planPath string
+	dryRun := false
+	for _, arg := range args {
+		switch {
+		case arg == "--dry-run":
+			dryRun = true
+		case strings.HasPrefix(arg, "--"):
+			return fmt.Errorf("unknown apply option %s", arg)
+		case planPath != "":
+			return fmt.Errorf("unexpected argument %s", arg)
+		default:
+			planPath = arg
+		}
+	}
+	if planPath == "" {
+		return fmt.Errorf("usage: apply PLAN-FILE [--dry-run]")
+	}
+
+	plan, err := readPlan(planPath)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("📝 Plan %s: %s, %d transfer(s), made %s\n",
+		planPath, plan.CommandLine, len(plan.Entries), plan.Created.Local().Format("2006-01-02 15:04"))
+	if !confirmOperation("apply", plan.Source, plan.Destination, dryRun, 0) {
+		fmt.Println("❌ Operation cancelled by user.")
+		return nil
+	}
+
+	startJournal("apply", plan.Destination, dryRun)
+	defer activeJournal.close()
+
+	var writer *locationWriter
+	for _, entry := range plan.Entries {
+		if entry.Location != nil {
+			writer = newLocationWriter(dryRun)
+			break
+		}
+	}
+
+	var stats applyStats
+	notTransferred := make(map[string]bool) // Sources left in place, whose companions and sidecars stay too
+	for _, entry := range plan.Entries {
+		if entry.Kind == planKindDuplicate {
+			applyDuplicate(entry, plan.Destination, dryRun, &stats)
+			continue
+		}
+		if entry.Of != "" && notTransferred[entry.Of] {
+			fmt.Printf("⏭️  Skipping %s: %s was not transferred\n", filepath.Base(entry.Source), filepath.Base(entry.Of))
+			notTransferred[entry.Source] = true
+			stats.skipped++
+			continue
+		}
+		if err := checkPlanEntry(entry); err != nil {
+			fmt.Printf("⛔ Rejected %s: %v\n", entry.Source, err)
+			notTransferred[entry.Source] = true
+			stats.rejected++
+			continue
+		}
+
+		if dryRun {
+			fmt.Printf("✅ [DRY RUN] %s would be %s to: %s\n", filepath.Base(entry.Source), transferVerb(entry.Mode), entry.Target)
+		} else {
+			if err := os.MkdirAll(filepath.Dir(entry.Target), 0755); err != nil {
+				fmt.Printf("❌ Failed to create directory for %s: %v\n", entry.Target, err)
+				notTransferred[entry.Source] = true
+				stats.failed++
+				continue
+			}
+			if err := transferFile(entry.Source, entry.Target, entry.Mode); err != nil {
+				fmt.Printf("❌ Failed to %s %s to %s: %v\n", entry.Mode, entry.Source, entry.Target, err)
+				notTransferred[entry.Source] = true
+				stats.failed++
+				continue
+			}
+			fmt.Printf("✅ %s %s to: %s\n", filepath.Base(entry.Source), transferVerb(entry.Mode), entry.Target)
+		}
+
+		stats.transferred++
+		if entry.Mode == transferMove {
+			stats.moved++
+		}
+		if entry.Location != nil && entry.Mode != transferSymlink {
+			writer.add(entry.Target, *entry.Location)
+		}
+	}
+	writer.finish()
+
+	if stats.moved > 0 {
+		cleanupEmptyDirectoriesIfNeeded(plan.Command, plan.Source, dryRun, -1)
+	}
+
+	prefix := "📊 Apply"
+	if dryRun {
+		prefix = "📊 Apply [DRY RUN]"
+	}
+	fmt.Printf("\n%s: %d transferred, %d already in the library, %d rejected (changed since planning), %d skipped with them, %d failed\n",
+		prefix, stats.transferred, stats.duplicates, stats.rejected, stats.skipped, stats.failed)
+	return nil
+}
+
+// applyDuplicate journals a planned duplicate and, with action delete, removes it. It is rejected
+// when the source or the library's copy no longer has the content planned
+func applyDuplicate(entry planEntry, destBasePath string, dryRun bool, stats *applyStats) {
+	if err := checkPlanDuplicate(entry); err != nil {
+		fmt.Printf("⛔ Rejected %s: %v\n", entry.Source, err)
+		stats.rejected++
+		return
+	}
+
+	dup := &DuplicateContentError{Source: entry.Source, Existing: entry.Target, SameFile: sameFileAs(entry.Target, entry.Source)}
+	remove := entry.Action == duplicatesDelete && !dup.SameFile
+	if dryRun {
+		action := "left in place"
+		if remove {
+			action = "deleted"
+		}
+		fmt.Printf("♻️  [DRY RUN] %s is already in the library as %s - would be %s\n", filepath.Base(entry.Source), entry.Target, action)
+	} else {
+		fmt.Printf("♻️  %s is already in the library as %s\n", filepath.Base(entry.Source), entry.Target)
+		settleDuplicate(dup, destBasePath, remove)
+	}
+	stats.duplicates++
+}
+
+// checkPlanDuplicate returns why a duplicate entry cannot be applied: an unknown action, a source
+// changed since planning, or a library copy that is gone or no longer the same
+func checkPlanDuplicate(entry planEntry) error {
+	if entry.Action != duplicatesDelete && entry.Action != duplicatesSkip {
+		return fmt.Errorf("unknown duplicate action %q", entry.Action)
+	}
+	if err := checkPlanSource(entry); err != nil {
+		return err
+	}
+	hash, err := calculateFileHash(entry.Target)
+	if err != nil {
+		return fmt.Errorf("the library copy is gone: %v", err)
+	}
+	if hash != entry.Hash {
+		return fmt.Errorf("the library copy %s changed since planning", entry.Target)
+	}
+	return nil
+}
+
+// checkPlanEntry returns why an entry cannot be applied as planned: an unknown mode, a source
+// that is gone or changed since planning, or a target that is taken
+func checkPlanEntry(entry planEntry) error {
+	if !isValidTransferMode(entry.Mode) {
+		return fmt.Errorf("unknown transfer mode %q", entry.Mode)
+	}
+	if err := checkPlanSource(entry); err != nil {
+		return err
+	}
+
+	if _, err := os.Lstat(entry.Target); err == nil {
+		return fmt.Errorf("target %s already exists", entry.Target)
+	}
+	return nil
+}
+
+// checkPlanSource returns why an entry's source no longer is the file that was planned
+func checkPlanSource(entry planEntry) error {
+	if entry.Source == "" || entry.Target == "" {
+		return fmt.Errorf("source and target are required")
+	}
+
+	info, err := os.Stat(entry.Source)
+	if err != nil {
+		return fmt.Errorf("source is gone: %v", err)
+	}
+	if info.Size() != entry.Size {
+		return fmt.Errorf("source changed since planning (size %d, planned %d)", info.Size(), entry.Size)
+	}
+	hash, err := calculateFileHash(entry.Source)
+	if err != nil {
+		return err
+	}
+	if hash != entry.Hash {
+		return fmt.Errorf("source changed since planning (content differs)")
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestApplyDuplicate(t *testing.T) {
+	tests := []struct {
+		name        string
+		action      string
+		libraryEdit bool
+		wantSource  bool
+		wantStats   applyStats
+	}{
+		{"delete", duplicatesDelete, false, false, applyStats{duplicates: 1}},
+		{"skip", duplicatesSkip, false, true, applyStats{duplicates: 1}},
+		{"library copy changed", duplicatesDelete, true, true, applyStats{rejected: 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source, library := t.TempDir(), t.TempDir()
+			sourcePath, libraryPath := filepath.Join(source, "IMG_0001.jpg"), filepath.Join(library, "2023-07-14-Paris.jpg")
+			for _, path := range []string{sourcePath, libraryPath} {
+				if err := os.WriteFile(path, []byte("same photo"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			plan := &executionPlan{reserved: make(map[string]string)}
+			plan.addDuplicate(sourcePath, libraryPath, tt.action)
+			if len(plan.Entries) != 1 {
+				t.Fatalf("plan has %d entries, want 1", len(plan.Entries))
+			}
+			if tt.libraryEdit {
+				if err := os.WriteFile(libraryPath, []byte("edited photo"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var stats applyStats
+			applyDuplicate(plan.Entries[0], library, false, &stats)
+			if stats != tt.wantStats {
+				t.Errorf("stats = %+v, want %+v", stats, tt.wantStats)
+			}
+			if _, err := os.Stat(sourcePath); (err == nil) != tt.wantSource {
+				t.Errorf("source exists = %v, want %v", err == nil, tt.wantSource)
+			}
+		})
+	}
+}
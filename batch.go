@@ -3,9 +3,7 @@ package main
 import (
 	"context"
 	"fmt"
-	"io/ioutil"
 	"os"
-	"os/exec"
 	"strings"
 	"sync"
 	"time"
@@ -15,7 +13,6 @@ import (
 type BatchMetadataUpdater struct {
 	batchSize       int
 	pendingUpdates  []*PhotoBatchInfo
-	tempConfigFiles []string
 	mu              sync.Mutex
 	stats           BatchStats
 }
@@ -23,9 +20,14 @@ type BatchMetadataUpdater struct {
 // PhotoBatchInfo contains information for batch processing
 type PhotoBatchInfo struct {
 	FilePath    string
-	NewLocation string
+	NewLocation string // Destination folder, for reporting; coordinates come from HasGPS
 	NewDate     string
 	Tags        map[string]string
+	
+	HasGPS    bool // Write Latitude/Longitude
+	Latitude  float64
+	Longitude float64
+	Sidecar   string // XMP sidecar to write instead of the file; created from the file when missing
 }
 
 // BatchStats tracks batch processing statistics
@@ -50,7 +52,6 @@ func NewBatchMetadataUpdater(batchSize int) *BatchMetadataUpdater {
 	return &BatchMetadataUpdater{
 		batchSize:       batchSize,
 		pendingUpdates:  make([]*PhotoBatchInfo, 0, batchSize),
-		tempConfigFiles: make([]string, 0),
 		stats: BatchStats{
 			StartTime: time.Now(),
 		},
@@ -59,15 +60,20 @@ func NewBatchMetadataUpdater(batchSize int) *BatchMetadataUpdater {
 
 // AddPhoto adds a photo to the batch for processing
 func (bmu *BatchMetadataUpdater) AddPhoto(filePath, location, date string, tags map[string]string) {
-	bmu.mu.Lock()
-	defer bmu.mu.Unlock()
-	
-	bmu.pendingUpdates = append(bmu.pendingUpdates, &PhotoBatchInfo{
+	bmu.AddUpdate(&PhotoBatchInfo{
 		FilePath:    filePath,
 		NewLocation: location,
 		NewDate:     date,
 		Tags:        tags,
 	})
+}
+
+// AddUpdate adds a prepared update to the batch for processing
+func (bmu *BatchMetadataUpdater) AddUpdate(photo *PhotoBatchInfo) {
+	bmu.mu.Lock()
+	defer bmu.mu.Unlock()
+	
+	bmu.pendingUpdates = append(bmu.pendingUpdates, photo)
 	
 	bmu.stats.TotalFiles++
 	
@@ -100,26 +106,29 @@ func (bmu *BatchMetadataUpdater) processBatch(ctx context.Context) error {
 	
 	fmt.Printf("📦 Processing batch of %d files...\n", batchSize)
 	
-	// Create argument file for ExifTool
-	argFile, err := bmu.createExifToolArgFile(bmu.pendingUpdates)
-	if err != nil {
-		return fmt.Errorf("failed to create ExifTool argument file: %v", err)
+	// Each photo is its own -execute on a pooled exiftool process
+	pool := GetExiftoolPool()
+	var failures []string
+	for _, photo := range bmu.pendingUpdates {
+		if ctx.Err() != nil {
+			failures = append(failures, fmt.Sprintf("%s: cancelled", photo.FilePath))
+			continue
+		}
+		
+		if err := bmu.updatePhoto(pool, photo); err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", photo.FilePath, err))
+		}
 	}
 	
-	// Track temporary file for cleanup
-	bmu.tempConfigFiles = append(bmu.tempConfigFiles, argFile)
-	
-	// Execute ExifTool with argument file
-	err = bmu.processBatchWithArgFile(ctx, argFile)
-	
 	// Update statistics
 	batchDuration := time.Since(batchStart)
-	bmu.updateBatchStats(batchSize, batchDuration, err == nil)
+	bmu.updateBatchStats(batchSize-len(failures), len(failures), batchDuration)
 	
 	// Clear the pending updates
 	bmu.pendingUpdates = bmu.pendingUpdates[:0]
 	
-	if err != nil {
+	if len(failures) > 0 {
+		err := fmt.Errorf("%d of %d files failed: %s", len(failures), batchSize, strings.Join(failures, "; "))
 		fmt.Printf("❌ Batch processing failed: %v\n", err)
 		return err
 	}
@@ -128,80 +137,79 @@ func (bmu *BatchMetadataUpdater) processBatch(ctx context.Context) error {
 	return nil
 }
 
-// createExifToolArgFile creates a temporary argument file for ExifTool
-func (bmu *BatchMetadataUpdater) createExifToolArgFile(photos []*PhotoBatchInfo) (string, error) {
-	tempFile, err := ioutil.TempFile("", "exiftool_batch_*.args")
-	if err != nil {
-		return "", err
-	}
-	defer tempFile.Close()
-	
-	// Write ExifTool arguments to file
-	for _, photo := range photos {
-		// Add common arguments
-		fmt.Fprintf(tempFile, "-overwrite_original\n")
-		fmt.Fprintf(tempFile, "-P\n") // Preserve file modification date
-		
-		// Add GPS coordinates if available
-		if photo.NewLocation != "" {
-			// This is simplified - in reality you'd extract lat/lon from location
-			fmt.Fprintf(tempFile, "-GPSLatitude=%s\n", "0.0") // Placeholder
-			fmt.Fprintf(tempFile, "-GPSLongitude=%s\n", "0.0") // Placeholder
-		}
-		
-		// Add date if available
-		if photo.NewDate != "" {
-			fmt.Fprintf(tempFile, "-AllDates=%s\n", photo.NewDate)
+// buildExifToolArgs builds the exiftool arguments for a single photo update
+func (bmu *BatchMetadataUpdater) buildExifToolArgs(photo *PhotoBatchInfo) []string {
+	args := []string{"-P"} // Preserve file modification date
+	
+	// The file exiftool reads, and the one the tags end up in
+	source, target := photo.FilePath, photo.FilePath
+	if photo.Sidecar != "" {
+		target = photo.Sidecar
+		if _, err := os.Stat(photo.Sidecar); err == nil {
+			source = photo.Sidecar
 		}
-		
-		// Add custom tags
-		for tag, value := range photo.Tags {
-			fmt.Fprintf(tempFile, "-%s=%s\n", tag, value)
-		}
-		
-		// Add file path (must be last for this file)
-		fmt.Fprintf(tempFile, "%s\n", photo.FilePath)
+	}
+	if source != target {
+		// Build a new sidecar from the file's own metadata
+		args = append(args, "-o", target)
+	} else {
+		args = append(args, "-overwrite_original")
 	}
 	
-	return tempFile.Name(), nil
-}
-
-// processBatchWithArgFile executes ExifTool using an argument file
-func (bmu *BatchMetadataUpdater) processBatchWithArgFile(ctx context.Context, argFile string) error {
-	// Create context with timeout
-	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Minute)
-	defer cancel()
+	// Add GPS coordinates if available
+	if photo.HasGPS {
+		args = append(args, gpsWriteArgs(target, photo.Latitude, photo.Longitude)...)
+	}
 	
-	// Execute ExifTool
-	cmd := exec.CommandContext(timeoutCtx, "exiftool", "-@", argFile)
-	output, err := cmd.CombinedOutput()
+	// Add date if available
+	if photo.NewDate != "" {
+		args = append(args, fmt.Sprintf("-AllDates=%s", photo.NewDate))
+	}
 	
-	if err != nil {
-		return fmt.Errorf("ExifTool execution failed: %v\nOutput: %s", err, string(output))
+	// Add custom tags
+	for tag, value := range photo.Tags {
+		args = append(args, fmt.Sprintf("-%s=%s", tag, value))
 	}
 	
-	// Parse output for any warnings or errors
-	outputStr := string(output)
-	if strings.Contains(outputStr, "Warning") || strings.Contains(outputStr, "Error") {
-		fmt.Printf("⚠️  ExifTool warnings/errors:\n%s\n", outputStr)
+	// File path must be last
+	return append(args, source)
+}
+
+// updatePhoto writes one photo's metadata through the exiftool pool
+func (bmu *BatchMetadataUpdater) updatePhoto(pool *ExiftoolPool, photo *PhotoBatchInfo) error {
+	target := photo.FilePath
+	if photo.Sidecar != "" {
+		target = photo.Sidecar
+	}
+	var stdout, stderr string
+	err := activeJournal.edit(target, func() error {
+		var err error
+		stdout, stderr, err = pool.Execute(bmu.buildExifToolArgs(photo)...)
+		if err == nil {
+			err = exiftoolWriteError(stdout, stderr)
+		}
+		return err
+	})
+	invalidateMetadataCache(photo.FilePath)
+	if photo.Sidecar != "" {
+		invalidateMetadataCache(photo.Sidecar)
+	}
+	if strings.Contains(stderr, "Warning") {
+		fmt.Printf("⚠️  ExifTool warnings for %s:\n%s\n", photo.FilePath, strings.TrimSpace(stderr))
 	}
 	
-	return nil
+	return err
 }
 
 // updateBatchStats updates the batch processing statistics
-func (bmu *BatchMetadataUpdater) updateBatchStats(batchSize int, duration time.Duration, success bool) {
+func (bmu *BatchMetadataUpdater) updateBatchStats(processed, failed int, duration time.Duration) {
 	bmu.stats.mu.Lock()
 	defer bmu.stats.mu.Unlock()
 	
 	bmu.stats.BatchCount++
 	bmu.stats.LastBatchTime = duration
-	
-	if success {
-		bmu.stats.ProcessedFiles += batchSize
-	} else {
-		bmu.stats.FailedFiles += batchSize
-	}
+	bmu.stats.ProcessedFiles += processed
+	bmu.stats.FailedFiles += failed
 	
 	// Update average batch time
 	if bmu.stats.BatchCount > 0 {
@@ -215,8 +223,17 @@ func (bmu *BatchMetadataUpdater) GetStats() BatchStats {
 	bmu.stats.mu.Lock()
 	defer bmu.stats.mu.Unlock()
 	
-	// Return a copy
-	return bmu.stats
+	// Return a copy (without the mutex)
+	return BatchStats{
+		TotalFiles:       bmu.stats.TotalFiles,
+		ProcessedFiles:   bmu.stats.ProcessedFiles,
+		BatchCount:       bmu.stats.BatchCount,
+		TotalBatches:     bmu.stats.TotalBatches,
+		FailedFiles:      bmu.stats.FailedFiles,
+		StartTime:        bmu.stats.StartTime,
+		LastBatchTime:    bmu.stats.LastBatchTime,
+		AverageBatchTime: bmu.stats.AverageBatchTime,
+	}
 }
 
 // PrintStats prints detailed batch processing statistics
@@ -243,26 +260,13 @@ func (bmu *BatchMetadataUpdater) PrintStats() {
 	}
 }
 
-// Close cleans up temporary files and resources
+// Close releases resources held by the updater
+// Pooled exiftool processes are shared and shut down with the pool
 func (bmu *BatchMetadataUpdater) Close() error {
 	bmu.mu.Lock()
 	defer bmu.mu.Unlock()
 	
-	var errs []string
-	
-	// Clean up temporary argument files
-	for _, tempFile := range bmu.tempConfigFiles {
-		if err := os.Remove(tempFile); err != nil && !os.IsNotExist(err) {
-			errs = append(errs, fmt.Sprintf("failed to remove %s: %v", tempFile, err))
-		}
-	}
-	
-	bmu.tempConfigFiles = bmu.tempConfigFiles[:0]
-	
-	if len(errs) > 0 {
-		return fmt.Errorf("cleanup errors: %s", strings.Join(errs, "; "))
-	}
-	
+	bmu.pendingUpdates = bmu.pendingUpdates[:0]
 	return nil
 }
 
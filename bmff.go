@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// maxBMFFBoxesPerLevel guards against corrupt files producing endless box lists
+const maxBMFFBoxesPerLevel = 4096
+
+// bmffBox is one ISO base media file format box (also used by QuickTime atoms)
+type bmffBox struct {
+	boxType string
+	offset  int64 // Start of the box payload
+	size    int64 // Payload size, excluding the header
+}
+
+// readBMFFBoxes lists the boxes stored between start and end
+func readBMFFBoxes(r io.ReaderAt, start, end int64) ([]bmffBox, error) {
+	var boxes []bmffBox
+	position := start
+	header := make([]byte, 16)
+
+	for position+8 <= end {
+		if len(boxes) >= maxBMFFBoxesPerLevel {
+			return nil, fmt.Errorf("too many boxes")
+		}
+
+		if _, err := r.ReadAt(header[:8], position); err != nil {
+			return nil, fmt.Errorf("failed to read box header at %d: %v", position, err)
+		}
+
+		boxSize := int64(binary.BigEndian.Uint32(header[0:4]))
+		boxType := string(header[4:8])
+		headerSize := int64(8)
+
+		switch boxSize {
+		case 0:
+			// Box extends to the end of its container
+			boxSize = end - position
+		case 1:
+			// 64-bit largesize follows the type
+			if _, err := r.ReadAt(header[8:16], position+8); err != nil {
+				return nil, fmt.Errorf("failed to read box size at %d: %v", position, err)
+			}
+			boxSize = int64(binary.BigEndian.Uint64(header[8:16]))
+			headerSize = 16
+		}
+
+		if boxSize < headerSize || position+boxSize > end {
+			return nil, fmt.Errorf("invalid size for box %q at %d", boxType, position)
+		}
+
+		boxes = append(boxes, bmffBox{
+			boxType: boxType,
+			offset:  position + headerSize,
+			size:    boxSize - headerSize,
+		})
+		position += boxSize
+	}
+
+	return boxes, nil
+}
+
+// findBMFFBox returns the first box of the given type
+func findBMFFBox(boxes []bmffBox, boxType string) (bmffBox, bool) {
+	for _, box := range boxes {
+		if box.boxType == boxType {
+			return box, true
+		}
+	}
+	return bmffBox{}, false
+}
+
+// readBMFFPayload reads a box payload into memory, refusing anything larger than limit
+func readBMFFPayload(r io.ReaderAt, box bmffBox, limit int64) ([]byte, error) {
+	if box.size > limit {
+		return nil, fmt.Errorf("box %q too large (%d bytes)", box.boxType, box.size)
+	}
+	payload := make([]byte, box.size)
+	if _, err := r.ReadAt(payload, box.offset); err != nil {
+		return nil, fmt.Errorf("failed to read box %q: %v", box.boxType, err)
+	}
+	return payload, nil
+}
+
+// bmffCursor reads big-endian fields sequentially from a box payload
+type bmffCursor struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+// bytes returns the next n bytes, recording an error if the payload is too short
+func (c *bmffCursor) bytes(n int) []byte {
+	if c.err != nil {
+		return nil
+	}
+	if n < 0 || c.pos+n > len(c.data) {
+		c.err = fmt.Errorf("unexpected end of box data")
+		return nil
+	}
+	b := c.data[c.pos : c.pos+n]
+	c.pos += n
+	return b
+}
+
+func (c *bmffCursor) uint8() uint8 {
+	b := c.bytes(1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+func (c *bmffCursor) uint16() uint16 {
+	b := c.bytes(2)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint16(b)
+}
+
+func (c *bmffCursor) uint32() uint32 {
+	b := c.bytes(4)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint32(b)
+}
+
+func (c *bmffCursor) uint64() uint64 {
+	b := c.bytes(8)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint64(b)
+}
+
+// sized reads an unsigned integer of 0, 4 or 8 bytes (the variable-size fields used by iloc)
+func (c *bmffCursor) sized(size int) uint64 {
+	switch size {
+	case 0:
+		return 0
+	case 4:
+		return uint64(c.uint32())
+	case 8:
+		return c.uint64()
+	default:
+		c.err = fmt.Errorf("unsupported field size %d", size)
+		return 0
+	}
+}
+
+// cstring reads a NUL-terminated string
+func (c *bmffCursor) cstring() string {
+	if c.err != nil {
+		return ""
+	}
+	for i := c.pos; i < len(c.data); i++ {
+		if c.data[i] == 0 {
+			s := string(c.data[c.pos:i])
+			c.pos = i + 1
+			return s
+		}
+	}
+	s := string(c.data[c.pos:])
+	c.pos = len(c.data)
+	return s
+}
@@ -205,6 +205,10 @@ func ProcessJobsWithCancellation(jobs []WorkJob, numWorkers int, showProgress bo
 	cancelMgr := NewCancellationManager()
 	signalHandler := NewSignalHandler(cancelMgr)
 	
+	// One long-lived exiftool process per worker, shut down with the run
+	InitExiftoolPool(cancelMgr.Context(), numWorkers)
+	defer CloseExiftoolPool()
+	
 	// Start signal monitoring
 	signalHandler.Start()
 	defer signalHandler.Stop()
@@ -352,6 +356,7 @@ func printCancellableProcessingSummary(results []WorkResult, progress *ProgressT
 		percentage = float64(completed+skipped) / float64(total) * 100
 	}
 	fmt.Printf("📋 Completion: %.1f%%\n", percentage)
+	printGeocodeCacheStats()
 	
 	// Group errors by type and file type
 	errorCounts := make(map[string]int)
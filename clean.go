@@ -277,7 +277,7 @@ func removeDuplicateFiles(duplicateGroups []DuplicateGroup, action DuplicateActi
 					fmt.Printf("Removing: %s\n", file.Path)
 				}
 				
-				if err := os.Remove(file.Path); err != nil {
+				if err := activeJournal.remove(file.Path); err != nil {
 					fmt.Printf("Error removing %s: %v\n", file.Path, err)
 					continue
 				}
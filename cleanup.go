@@ -59,6 +59,11 @@ func previewEmptyDirectoryCleanup(basePath string) error {
 			return nil
 		}
 
+		// The journal keeps the backups undo needs
+		if info.Name() == journalDirName {
+			return filepath.SkipDir
+		}
+
 		// Skip the base directory itself
 		if path == basePath {
 			return nil
@@ -110,6 +115,11 @@ func removeEmptyDirectoriesPass(basePath string) (int, error) {
 			return nil
 		}
 
+		// The journal keeps the backups undo needs
+		if info.Name() == journalDirName {
+			return filepath.SkipDir
+		}
+
 		// Skip the base directory itself
 		if path == basePath {
 			return nil
@@ -182,9 +192,20 @@ func removeNonMediaFiles(dirPath string) error {
 
 		filePath := filepath.Join(dirPath, entry.Name())
 
-		// Remove all non-media files (including hidden files)
+		// Keep sidecars - they hold edits and metadata for media that may live elsewhere
+		if isSidecarFile(filePath) {
+			fmt.Printf("📎 Keeping sidecar file: %s\n", filePath)
+			continue
+		}
+
+		// Takeout .json files hold the GPS and dates Google stripped from the media, so the export keeps them
+		if takeoutMetadataEnabled && strings.EqualFold(filepath.Ext(filePath), ".json") {
+			continue
+		}
+
+		// Remove all other non-media files (including hidden files)
 		if !isMediaFile(filePath) {
-			if err := os.Remove(filePath); err != nil {
+			if err := activeJournal.remove(filePath); err != nil {
 				return fmt.Errorf("failed to remove non-media file %s: %v", entry.Name(), err)
 			}
 		}
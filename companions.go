@@ -0,0 +1,264 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// mediaCompanions maps a primary file to the paired files that move with it (the RAW of a
+// RAW+JPEG pair, the video of a Live Photo). It is filled by the pairing stage before any
+// file is moved, and consulted by the movers
+var mediaCompanions = struct {
+	sync.RWMutex
+	files map[string][]string
+}{files: make(map[string][]string)}
+
+// registerCompanion records that companion moves together with primary
+func registerCompanion(primary, companion string) {
+	mediaCompanions.Lock()
+	defer mediaCompanions.Unlock()
+	mediaCompanions.files[primary] = append(mediaCompanions.files[primary], companion)
+}
+
+// companionsOf returns the files paired with a primary file
+func companionsOf(primary string) []string {
+	mediaCompanions.RLock()
+	defer mediaCompanions.RUnlock()
+	return mediaCompanions.files[primary]
+}
+
+// pairCompanionFiles runs every pairing stage over the collected paths and returns the
+// primary files only - each companion is moved by its primary's job
+func pairCompanionFiles(paths []string) []string {
+	paths = pairRawJPEG(paths)
+	return pairLivePhotos(paths)
+}
+
+// pairCompanionJobs runs the pairing stages over a job list, dropping the companion jobs
+func pairCompanionJobs(jobs []WorkJob) []WorkJob {
+	paths := make([]string, len(jobs))
+	for i, job := range jobs {
+		paths[i] = job.PhotoPath
+	}
+
+	keep := make(map[string]bool)
+	for _, path := range pairCompanionFiles(paths) {
+		keep[path] = true
+	}
+	if len(keep) == len(jobs) {
+		return jobs
+	}
+
+	remaining := make([]WorkJob, 0, len(keep))
+	for _, job := range jobs {
+		if keep[job.PhotoPath] {
+			remaining = append(remaining, job)
+		}
+	}
+	return remaining
+}
+
+// removePairedPaths returns paths without the ones that are now companions
+func removePairedPaths(paths []string, paired map[string]bool) []string {
+	if len(paired) == 0 {
+		return paths
+	}
+	remaining := make([]string, 0, len(paths)-len(paired))
+	for _, path := range paths {
+		if !paired[path] {
+			remaining = append(remaining, path)
+		}
+	}
+	return remaining
+}
+
+// mediaBaseName returns the lower-case filename without its extension
+func mediaBaseName(path string) string {
+	name := strings.ToLower(filepath.Base(path))
+	return strings.TrimSuffix(name, filepath.Ext(name))
+}
+
+// captureTimesMatch reports whether two files were taken within maxGap of each other, by
+// instant or by wall-clock time, comparing file modification times when either has no date in its metadata
+func captureTimesMatch(first, second string, maxGap time.Duration) bool {
+	firstTime, firstErr := extractPhotoDate(first)
+	secondTime, secondErr := extractPhotoDate(second)
+
+	if firstErr != nil || secondErr != nil {
+		firstInfo, err := os.Stat(first)
+		if err != nil {
+			return false
+		}
+		secondInfo, err := os.Stat(second)
+		if err != nil {
+			return false
+		}
+		firstTime, secondTime = firstInfo.ModTime(), secondInfo.ModTime()
+	}
+
+	if absDuration(firstTime.Sub(secondTime)) <= maxGap {
+		return true
+	}
+	// One file may record a UTC offset the other lacks - the camera clock still shows the same wall time
+	return absDuration(inZone(firstTime, time.UTC).Sub(inZone(secondTime, time.UTC))) <= maxGap
+}
+
+// withCompanionMetadata fills GPS and dates missing from a primary file with its
+// companions' values, so a pair is placed by whichever half has them
+func withCompanionMetadata(path string, metadata *MediaMetadata) *MediaMetadata {
+	if metadata.HasGPS && metadata.DateTimeOriginal != "" {
+		return metadata
+	}
+	companions := companionsOf(path)
+	if len(companions) == 0 {
+		return metadata
+	}
+
+	// Copy so the cached metadata of the primary stays as read from the file
+	merged := *metadata
+	for _, companion := range companions {
+		if companionMetadata, err := readMetadata(companion); err == nil {
+			fillMissingMetadata(&merged, companionMetadata)
+		}
+	}
+	return &merged
+}
+
+// companionTarget returns where a companion goes once its primary is at primaryFinalPath
+func companionTarget(companion, primaryFinalPath, destBasePath string) string {
+	if isRawFile(companion) {
+		return rawCompanionTarget(companion, primaryFinalPath)
+	}
+	return livePhotoVideoTarget(companion, primaryFinalPath, destBasePath)
+}
+
+// companionPathsFree reports whether the primary can take primaryFinalPath without any
+// of its sidecars or companions landing on an existing file, so a pair never ends up
+// with mismatched duplicate counters
+func companionPathsFree(sourcePath, primaryFinalPath, destBasePath string) bool {
+	for _, target := range companionTargets(findSidecars(sourcePath), sourcePath, primaryFinalPath, destBasePath) {
+		if _, err := os.Stat(target); err == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// companionTargets lists every path the sidecars and companions of sourcePath would be moved to
+func companionTargets(sidecars []string, sourcePath, primaryFinalPath, destBasePath string) []string {
+	var targets []string
+	for _, assignment := range assignSidecars(sidecars, sourcePath, primaryFinalPath, destBasePath) {
+		targets = append(targets, sidecarTargetPath(assignment.sidecar, assignment.ownerSource, assignment.ownerFinal))
+	}
+	for _, companion := range companionsOf(sourcePath) {
+		target := companionTarget(companion, primaryFinalPath, destBasePath)
+		targets = append(targets, target)
+		for _, sidecar := range companionSidecars(companion, sidecars) {
+			targets = append(targets, sidecarTargetPath(sidecar, companion, target))
+		}
+	}
+	return targets
+}
+
+// sidecarAssignment is a primary's sidecar together with the file it belongs to
+type sidecarAssignment struct {
+	sidecar     string
+	ownerSource string
+	ownerFinal  string
+}
+
+// assignSidecars decides which file each of the primary's sidecars follows. A shared
+// IMG_1234.xmp belongs to the RAW of a RAW+JPEG pair, since that is the file it was written for
+func assignSidecars(sidecars []string, sourcePath, primaryFinalPath, destBasePath string) []sidecarAssignment {
+	var raw string
+	for _, companion := range companionsOf(sourcePath) {
+		if isRawFile(companion) {
+			raw = companion
+			break
+		}
+	}
+
+	assignments := make([]sidecarAssignment, 0, len(sidecars))
+	for _, sidecar := range sidecars {
+		assignment := sidecarAssignment{sidecar: sidecar, ownerSource: sourcePath, ownerFinal: primaryFinalPath}
+
+		name := filepath.Base(sidecar)
+		ext := filepath.Ext(name)
+		sharedName := !strings.EqualFold(strings.TrimSuffix(name, ext), filepath.Base(sourcePath))
+		if raw != "" && sharedName && strings.EqualFold(ext, ".xmp") {
+			assignment.ownerSource = raw
+			assignment.ownerFinal = companionTarget(raw, primaryFinalPath, destBasePath)
+		}
+		assignments = append(assignments, assignment)
+	}
+	return assignments
+}
+
+// transferCompanions moves, copies or links (as mode says) each companion of sourcePath under the
+// primary's final base name, followed by its own sidecars, so sidecars (.xmp, .aae, .thm) and
+// paired files travel with the media file under the same final name. Call it after the primary has
+// been transferred, with the primary's original path and the sidecars found before it moved.
+// Nothing is ever overwritten
+func transferCompanions(sidecars []string, sourcePath, primaryFinalPath, destBasePath string, dryRun bool, mode string) {
+	for _, companion := range companionsOf(sourcePath) {
+		target := companionTarget(companion, primaryFinalPath, destBasePath)
+		transferCompanion(companion, sourcePath, target, companionSidecars(companion, sidecars), dryRun, mode)
+	}
+
+	for _, assignment := range assignSidecars(sidecars, sourcePath, primaryFinalPath, destBasePath) {
+		transferSidecars([]string{assignment.sidecar}, assignment.ownerSource, assignment.ownerFinal, dryRun, mode)
+	}
+}
+
+// companionSidecars returns a companion's own sidecars; ones it shares with the primary
+// (IMG_1234.xmp next to IMG_1234.JPG and IMG_1234.CR2) are placed by assignSidecars
+func companionSidecars(companion string, primarySidecars []string) []string {
+	shared := make(map[string]bool, len(primarySidecars))
+	for _, sidecar := range primarySidecars {
+		shared[sidecar] = true
+	}
+
+	var own []string
+	for _, sidecar := range findSidecars(companion) {
+		if !shared[sidecar] {
+			own = append(own, sidecar)
+		}
+	}
+	return own
+}
+
+// transferCompanion moves, copies or links one paired file of primary and its own sidecars to target
+func transferCompanion(companion, primary, target string, sidecars []string, dryRun bool, mode string) {
+	kind, planKind := "Live Photo video", "live-photo-video"
+	if isRawFile(companion) {
+		kind, planKind = "RAW", "raw"
+	}
+
+	if dryRun {
+		fmt.Printf("🔗 [DRY RUN] %s would be %s to: %s\n", kind, transferVerb(mode), target)
+		activePlan.add(planEntry{Source: companion, Target: target, Mode: mode, Kind: planKind, Of: primary, Reason: "paired with " + filepath.Base(primary)})
+		transferSidecars(sidecars, companion, target, true, mode)
+		return
+	}
+
+	if _, err := os.Stat(target); err == nil {
+		fmt.Printf("⚠️  %s %s not %s: %s already exists\n", kind, filepath.Base(companion), transferVerb(mode), target)
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		fmt.Printf("⚠️  %s %s not %s: %v\n", kind, filepath.Base(companion), transferVerb(mode), err)
+		return
+	}
+
+	if err := transferFile(companion, target, mode); err != nil {
+		fmt.Printf("⚠️  %s %s not %s: %v\n", kind, filepath.Base(companion), transferVerb(mode), err)
+		return
+	}
+
+	fmt.Printf("🔗 %s %s to: %s\n", kind, transferVerb(mode), target)
+	transferSidecars(sidecars, companion, target, false, mode)
+}
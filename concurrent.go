@@ -427,6 +427,7 @@ func printProcessingSummary(results []WorkResult, progress *ProgressTracker) {
 		avgTime := elapsed / time.Duration(completed)
 		fmt.Printf("📈 Average time per file: %v\n", avgTime.Round(time.Millisecond))
 	}
+	printGeocodeCacheStats()
 	
 	// Group errors by type
 	errorCounts := make(map[string]int)
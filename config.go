@@ -0,0 +1,329 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// AppConfig holds optional settings loaded from photo-meta-config.json
+type AppConfig struct {
+	// DefaultTimezone is the IANA zone used for capture times with no offset or GPS time (default: system zone)
+	DefaultTimezone string `json:"default_timezone"`
+
+	// LivePhotoVideo places the MOV half of a Live Photo: "with_photo" (default) or "video_files"
+	LivePhotoVideo string `json:"live_photo_video"`
+
+	// RawJPEGPair decides how RAW+JPEG pairs are placed: "together" (default), "raw_subfolder" or "separate"
+	RawJPEGPair string `json:"raw_jpeg_pair"`
+
+	// PathTemplate is the layout every command files into, e.g.
+	// {video-folder}/{year}/{country}/{city}/{date:2006-01-02}[-{time:1504}]-{city}{ext} (the default)
+	PathTemplate string `json:"path_template"`
+
+	// TransferMode is how every command puts files in the library: "move", "copy", "hardlink", "symlink"
+	// or "reflink" (default: each command's own, move except for merge, which copies)
+	TransferMode string `json:"transfer"`
+
+	// Duplicates is what happens to a file whose content is already in the library under its target
+	// name: "skip" leaves it where it is (default), "delete" removes it
+	Duplicates string `json:"duplicates"`
+
+	// DisableJournal stops runs being recorded in the destination's journal, so they cannot be undone
+	DisableJournal bool `json:"disable_journal"`
+
+	// PlacesFile lists user-defined places (home, family, a holiday flat) that are filed under a
+	// fixed country/city folder before any geocoder is asked
+	PlacesFile string `json:"places_file"`
+
+	// LocationGranularity names the city folder after the "locality" (default), "county", "state" or "country"
+	LocationGranularity string `json:"location_granularity"`
+
+	// LocationGranularityByCountry overrides the granularity per country, keyed by ISO code or name
+	LocationGranularityByCountry map[string]string `json:"location_granularity_by_country"`
+
+	// MergeSmallPlaces files a locality under its county when fewer than this many photos of a run land there (0: off)
+	MergeSmallPlaces int `json:"merge_small_places"`
+
+	// LibraryMatchWindowMinutes is how far in time a GPS photo already in the destination may be from a
+	// GPS-less file for datetime to use its position (default: 30)
+	LibraryMatchWindowMinutes int `json:"library_match_window_minutes"`
+
+	// LibraryMatchInterpolate places the file between the library photos taken before and after it
+	LibraryMatchInterpolate bool `json:"library_match_interpolate"`
+
+	// LibraryMatchMinConfidence rejects library matches below this confidence, 0-1 (default: 0)
+	LibraryMatchMinConfidence float64 `json:"library_match_min_confidence"`
+
+	// DisableLibraryMatch makes datetime match by day only
+	DisableLibraryMatch bool `json:"disable_library_match"`
+
+	// Geocoders lists the reverse geocoding providers to try in order: "offline", "gazetteer", "geonames",
+	// "nominatim" (default: offline, geonames when geonames_file is set, then nominatim)
+	Geocoders []string `json:"geocoders"`
+
+	// GazetteerFile is the tab-separated place file used by the gazetteer provider
+	GazetteerFile string `json:"gazetteer_file"`
+
+	// GazetteerMaxKm is how far the nearest gazetteer place may be (default: 25)
+	GazetteerMaxKm float64 `json:"gazetteer_max_km"`
+
+	// GeoNamesFile is a GeoNames dump (cities1000.txt, allCountries.txt) for offline reverse geocoding;
+	// admin1CodesASCII.txt and admin2Codes.txt are read from the same directory
+	GeoNamesFile string `json:"geonames_file"`
+
+	// GeoNamesMaxKm is how far the nearest GeoNames place may be (default: 50)
+	GeoNamesMaxKm float64 `json:"geonames_max_km"`
+
+	// CountryBoundariesFile is a GeoJSON file of country polygons used instead of the built-in
+	// Natural Earth boundaries to decide the country of a coordinate before any city lookup
+	CountryBoundariesFile string `json:"country_boundaries_file"`
+
+	// DisableGeocodeCache turns off the persistent reverse geocoding cache
+	DisableGeocodeCache bool `json:"disable_geocode_cache"`
+
+	// GeocodeCachePrecisionM is the cache cell size in metres; photos in one cell share a lookup (default: 100)
+	GeocodeCachePrecisionM float64 `json:"geocode_cache_precision_m"`
+
+	// GeocodeCacheTTLDays is how long a cached location is used before it is looked up again (default: 180)
+	GeocodeCacheTTLDays int `json:"geocode_cache_ttl_days"`
+
+	// NominatimURL is the Nominatim server to query (default: https://nominatim.openstreetmap.org)
+	NominatimURL string `json:"nominatim_url"`
+
+	// NominatimUserAgent identifies the application to Nominatim (default: photo-meta with its project URL)
+	NominatimUserAgent string `json:"nominatim_user_agent"`
+
+	// NominatimEmail is sent with each request so the server operator can reach you about heavy use
+	NominatimEmail string `json:"nominatim_email"`
+
+	// NominatimRequestsPerSecond limits requests across all workers (default: 1, the public server's policy)
+	NominatimRequestsPerSecond float64 `json:"nominatim_requests_per_second"`
+
+	// NominatimMaxRetries is how often a 429, 5xx or network failure is retried (default: 3)
+	NominatimMaxRetries int `json:"nominatim_max_retries"`
+}
+
+var (
+	appConfig       *AppConfig
+	appConfigOnce   sync.Once
+	appConfigPath   = "photo-meta-config.json"
+	configOverrides = make(map[string]string) // Set from global command line flags
+)
+
+// GetAppConfig loads the configuration file once, applying command line overrides
+func GetAppConfig() *AppConfig {
+	appConfigOnce.Do(func() {
+		appConfig = &AppConfig{}
+
+		data, err := os.ReadFile(appConfigPath)
+		if err == nil {
+			if err := json.Unmarshal(data, appConfig); err != nil {
+				fmt.Printf("⚠️  Warning: Failed to parse config file %s: %v\n", appConfigPath, err)
+				appConfig = &AppConfig{}
+			}
+		} else if !os.IsNotExist(err) {
+			fmt.Printf("⚠️  Warning: Failed to read config file %s: %v\n", appConfigPath, err)
+		}
+
+		if zone, exists := configOverrides["timezone"]; exists {
+			appConfig.DefaultTimezone = zone
+		}
+		if placement, exists := configOverrides["live_photo_video"]; exists {
+			appConfig.LivePhotoVideo = placement
+		}
+
+		if policy, exists := configOverrides["raw_jpeg_pair"]; exists {
+			appConfig.RawJPEGPair = policy
+		}
+		if template, exists := configOverrides["path_template"]; exists {
+			appConfig.PathTemplate = template
+		}
+		if mode, exists := configOverrides["transfer"]; exists {
+			appConfig.TransferMode = mode
+		}
+		if action, exists := configOverrides["duplicates"]; exists {
+			appConfig.Duplicates = action
+		}
+		if _, exists := configOverrides["disable_journal"]; exists {
+			appConfig.DisableJournal = true
+		}
+		if path, exists := configOverrides["places_file"]; exists {
+			appConfig.PlacesFile = path
+		}
+		if granularity, exists := configOverrides["location_granularity"]; exists {
+			appConfig.LocationGranularity = granularity
+		}
+		if threshold, exists := configOverrides["merge_small_places"]; exists {
+			if n, err := strconv.Atoi(threshold); err == nil {
+				appConfig.MergeSmallPlaces = n
+			} else {
+				fmt.Printf("⚠️  Warning: Invalid --merge-small-places '%s', ignoring\n", threshold)
+			}
+		}
+		if window, exists := configOverrides["library_match_window_minutes"]; exists {
+			if minutes, err := strconv.Atoi(window); err == nil {
+				appConfig.LibraryMatchWindowMinutes = minutes
+			} else {
+				fmt.Printf("⚠️  Warning: Invalid --match-window '%s', ignoring\n", window)
+			}
+		}
+		if _, exists := configOverrides["library_match_interpolate"]; exists {
+			appConfig.LibraryMatchInterpolate = true
+		}
+		if _, exists := configOverrides["disable_library_match"]; exists {
+			appConfig.DisableLibraryMatch = true
+		}
+		if providers, exists := configOverrides["geocoders"]; exists {
+			appConfig.Geocoders = strings.Split(providers, ",")
+		}
+		if path, exists := configOverrides["geonames_file"]; exists {
+			appConfig.GeoNamesFile = path
+		}
+		if path, exists := configOverrides["country_boundaries_file"]; exists {
+			appConfig.CountryBoundariesFile = path
+		}
+		if url, exists := configOverrides["nominatim_url"]; exists {
+			appConfig.NominatimURL = url
+		}
+
+		switch appConfig.LivePhotoVideo {
+		case "", livePhotoVideoWithPhoto, livePhotoVideoVideoFiles:
+		default:
+			fmt.Printf("⚠️  Warning: Unknown live_photo_video '%s', using %s\n", appConfig.LivePhotoVideo, livePhotoVideoWithPhoto)
+			appConfig.LivePhotoVideo = livePhotoVideoWithPhoto
+		}
+		if appConfig.LocationGranularity != "" && !isValidGranularity(appConfig.LocationGranularity) {
+			fmt.Printf("⚠️  Warning: Unknown location_granularity '%s', using %s\n", appConfig.LocationGranularity, granularityLocality)
+			appConfig.LocationGranularity = granularityLocality
+		}
+		for country, granularity := range appConfig.LocationGranularityByCountry {
+			if !isValidGranularity(granularity) {
+				fmt.Printf("⚠️  Warning: Unknown location granularity '%s' for %s, ignoring\n", granularity, country)
+				delete(appConfig.LocationGranularityByCountry, country)
+			}
+		}
+		switch appConfig.Duplicates {
+		case "", duplicatesSkip, duplicatesDelete:
+		default:
+			fmt.Printf("⚠️  Warning: Unknown duplicates action '%s', using %s\n", appConfig.Duplicates, duplicatesSkip)
+			appConfig.Duplicates = duplicatesSkip
+		}
+		if appConfig.TransferMode != "" && !isValidTransferMode(appConfig.TransferMode) {
+			fmt.Printf("⚠️  Warning: Unknown transfer mode '%s', using each command's default\n", appConfig.TransferMode)
+			appConfig.TransferMode = ""
+		}
+		switch appConfig.RawJPEGPair {
+		case "", rawPairTogether, rawPairRawSubfolder, rawPairSeparate:
+		default:
+			fmt.Printf("⚠️  Warning: Unknown raw_jpeg_pair '%s', using %s\n", appConfig.RawJPEGPair, rawPairTogether)
+			appConfig.RawJPEGPair = rawPairTogether
+		}
+	})
+
+	return appConfig
+}
+
+// extractGlobalFlags removes flags that apply to every command from args
+// and records them as configuration overrides
+func extractGlobalFlags(args []string) ([]string, error) {
+	remaining := make([]string, 0, len(args))
+
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--config":
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("--config requires a file path")
+			}
+			appConfigPath = args[i+1]
+			i++
+		case "--timezone":
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("--timezone requires a zone name (e.g. Europe/Madrid)")
+			}
+			configOverrides["timezone"] = args[i+1]
+			i++
+		case "--live-photo-video":
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("--live-photo-video requires with_photo or video_files")
+			}
+			configOverrides["live_photo_video"] = args[i+1]
+			i++
+		case "--raw-pair":
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("--raw-pair requires together, raw_subfolder or separate")
+			}
+			configOverrides["raw_jpeg_pair"] = args[i+1]
+			i++
+		case "--path-template":
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("--path-template requires a template (e.g. {year}/{country}/{city}/{date}-{city}{ext})")
+			}
+			configOverrides["path_template"] = args[i+1]
+			i++
+		case "--transfer":
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("--transfer requires move, copy, hardlink, symlink or reflink")
+			}
+			configOverrides["transfer"] = args[i+1]
+			i++
+		case "--duplicates":
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("--duplicates requires skip or delete")
+			}
+			configOverrides["duplicates"] = args[i+1]
+			i++
+		case "--no-journal":
+			configOverrides["disable_journal"] = "true"
+		case "--places":
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("--places requires a places file")
+			}
+			configOverrides["places_file"] = args[i+1]
+			i++
+		case "--granularity":
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("--granularity requires locality, county, state or country")
+			}
+			configOverrides["location_granularity"] = args[i+1]
+			i++
+		case "--merge-small-places":
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("--merge-small-places requires a photo count")
+			}
+			configOverrides["merge_small_places"] = args[i+1]
+			i++
+		case "--geocoder":
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("--geocoder requires a provider list (e.g. offline,nominatim)")
+			}
+			configOverrides["geocoders"] = args[i+1]
+			i++
+		case "--geonames":
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("--geonames requires a GeoNames file (e.g. cities1000.txt)")
+			}
+			configOverrides["geonames_file"] = args[i+1]
+			i++
+		case "--country-boundaries":
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("--country-boundaries requires a GeoJSON file")
+			}
+			configOverrides["country_boundaries_file"] = args[i+1]
+			i++
+		case "--nominatim-url":
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("--nominatim-url requires a server URL")
+			}
+			configOverrides["nominatim_url"] = args[i+1]
+			i++
+		default:
+			remaining = append(remaining, args[i])
+		}
+	}
+
+	return remaining, nil
+}
@@ -0,0 +1,367 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// country-boundaries.geojson.gz is Natural Earth's 1:10m admin 0 countries (public domain),
+// simplified to about 500 m and keeping only the code and name properties
+//
+//go:embed country-boundaries.geojson.gz
+var countryBoundariesFS embed.FS
+
+// rtreeNodeCapacity is the number of children per R-tree node
+const rtreeNodeCapacity = 16
+
+// countryCodeProperties are the GeoJSON feature properties that may hold an ISO alpha-2 code,
+// covering Natural Earth (ISO_A2_EH, ISO_A2) and the common datahub/geoBoundaries exports
+var countryCodeProperties = []string{"ISO_A2_EH", "ISO_A2", "iso_a2", "ISO3166-1-Alpha-2", "ISO2", "iso2"}
+
+// countryNameProperties are the GeoJSON feature properties that may hold the country name
+var countryNameProperties = []string{"NAME_EN", "NAME", "ADMIN", "name", "admin"}
+
+// boundingBox is an axis-aligned longitude/latitude rectangle
+type boundingBox struct {
+	MinLon, MinLat, MaxLon, MaxLat float64
+}
+
+// contains reports whether the point is inside the box
+func (b boundingBox) contains(lat, lon float64) bool {
+	return lon >= b.MinLon && lon <= b.MaxLon && lat >= b.MinLat && lat <= b.MaxLat
+}
+
+// extend grows the box to cover other
+func (b boundingBox) extend(other boundingBox) boundingBox {
+	return boundingBox{
+		MinLon: math.Min(b.MinLon, other.MinLon),
+		MinLat: math.Min(b.MinLat, other.MinLat),
+		MaxLon: math.Max(b.MaxLon, other.MaxLon),
+		MaxLat: math.Max(b.MaxLat, other.MaxLat),
+	}
+}
+
+// countryBoundary is the border of a country
+type countryBoundary struct {
+	Code string // ISO 3166-1 alpha-2, upper case
+	Name string
+}
+
+// boundaryPolygon is one polygon of a country or timezone: an outer ring followed by its holes,
+// each ring a list of [longitude, latitude] points
+type boundaryPolygon struct {
+	country *countryBoundary // Set for country boundaries
+	zone    string           // IANA zone name, set for timezone boundaries
+	rings   [][][2]float64
+	bounds  boundingBox
+}
+
+// containsPoint tests the point against the outer ring and holes with ray casting
+func (p *boundaryPolygon) containsPoint(lat, lon float64) bool {
+	if !p.bounds.contains(lat, lon) || len(p.rings) == 0 {
+		return false
+	}
+	if !ringContains(p.rings[0], lat, lon) {
+		return false
+	}
+	for _, hole := range p.rings[1:] {
+		if ringContains(hole, lat, lon) {
+			return false
+		}
+	}
+	return true
+}
+
+// ringContains reports whether a closed ring encloses the point (even-odd rule)
+func ringContains(ring [][2]float64, lat, lon float64) bool {
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		xi, yi := ring[i][0], ring[i][1]
+		xj, yj := ring[j][0], ring[j][1]
+		if (yi > lat) != (yj > lat) && lon < (xj-xi)*(lat-yi)/(yj-yi)+xi {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// rtreeNode is a node of a bulk-loaded R-tree; leaves hold polygons
+type rtreeNode struct {
+	bounds   boundingBox
+	children []*rtreeNode
+	polygon  *boundaryPolygon
+}
+
+// countryBoundaries answers which country a coordinate lies in, using polygons indexed in an R-tree
+type countryBoundaries struct {
+	root      *rtreeNode
+	countries int
+}
+
+// countryAt returns the country containing the point, or nil for open sea and unknown data
+func (cb *countryBoundaries) countryAt(lat, lon float64) *countryBoundary {
+	if cb == nil || cb.root == nil {
+		return nil
+	}
+	if polygon := cb.root.find(lat, lon); polygon != nil {
+		return polygon.country
+	}
+	return nil
+}
+
+// find descends into every child whose box contains the point and returns the first polygon containing it
+func (n *rtreeNode) find(lat, lon float64) *boundaryPolygon {
+	if !n.bounds.contains(lat, lon) {
+		return nil
+	}
+	if n.polygon != nil {
+		if n.polygon.containsPoint(lat, lon) {
+			return n.polygon
+		}
+		return nil
+	}
+	for _, child := range n.children {
+		if polygon := child.find(lat, lon); polygon != nil {
+			return polygon
+		}
+	}
+	return nil
+}
+
+// buildRTree bulk-loads leaves with Sort-Tile-Recursive packing
+func buildRTree(nodes []*rtreeNode) *rtreeNode {
+	if len(nodes) == 0 {
+		return nil
+	}
+
+	for len(nodes) > 1 {
+		// Cut the nodes into vertical slices by longitude, then pack each slice by latitude
+		leafCount := int(math.Ceil(float64(len(nodes)) / rtreeNodeCapacity))
+		sliceCount := int(math.Ceil(math.Sqrt(float64(leafCount))))
+		sliceSize := sliceCount * rtreeNodeCapacity
+
+		sort.Slice(nodes, func(i, j int) bool {
+			return nodes[i].bounds.MinLon+nodes[i].bounds.MaxLon < nodes[j].bounds.MinLon+nodes[j].bounds.MaxLon
+		})
+
+		var parents []*rtreeNode
+		for start := 0; start < len(nodes); start += sliceSize {
+			slice := nodes[start:minInt(start+sliceSize, len(nodes))]
+			sort.Slice(slice, func(i, j int) bool {
+				return slice[i].bounds.MinLat+slice[i].bounds.MaxLat < slice[j].bounds.MinLat+slice[j].bounds.MaxLat
+			})
+
+			for groupStart := 0; groupStart < len(slice); groupStart += rtreeNodeCapacity {
+				group := slice[groupStart:minInt(groupStart+rtreeNodeCapacity, len(slice))]
+				parent := &rtreeNode{bounds: group[0].bounds, children: append([]*rtreeNode(nil), group...)}
+				for _, child := range group[1:] {
+					parent.bounds = parent.bounds.extend(child.bounds)
+				}
+				parents = append(parents, parent)
+			}
+		}
+		nodes = parents
+	}
+
+	return nodes[0]
+}
+
+// minInt returns the smaller of two ints
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// geoJSONGeometry is a GeoJSON geometry with its coordinates left undecoded
+type geoJSONGeometry struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates"`
+}
+
+// geoJSONFeatureCollection is the part of a GeoJSON file the boundary loader reads
+type geoJSONFeatureCollection struct {
+	Features []struct {
+		Properties map[string]interface{} `json:"properties"`
+		Geometry   geoJSONGeometry        `json:"geometry"`
+	} `json:"features"`
+}
+
+// polygons decodes a Polygon or MultiPolygon geometry; other types have none
+func (g geoJSONGeometry) polygons() ([][][][2]float64, error) {
+	var polygons [][][][2]float64
+	switch g.Type {
+	case "Polygon":
+		var polygon [][][2]float64
+		if err := json.Unmarshal(g.Coordinates, &polygon); err != nil {
+			return nil, err
+		}
+		polygons = append(polygons, polygon)
+	case "MultiPolygon":
+		if err := json.Unmarshal(g.Coordinates, &polygons); err != nil {
+			return nil, err
+		}
+	}
+	return polygons, nil
+}
+
+// loadGzipAsset reads and decompresses an embedded gzip file
+func loadGzipAsset(fsys embed.FS, name string) ([]byte, error) {
+	compressed, err := fsys.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(reader)
+}
+
+// loadCountryBoundaries reads country polygons from a GeoJSON file
+func loadCountryBoundaries(path string) (*countryBoundaries, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read country boundaries: %v", err)
+	}
+	return parseCountryBoundaries(data, path)
+}
+
+// loadBuiltinCountryBoundaries reads the embedded Natural Earth country polygons
+func loadBuiltinCountryBoundaries() (*countryBoundaries, error) {
+	data, err := loadGzipAsset(countryBoundariesFS, "country-boundaries.geojson.gz")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read built-in country boundaries: %v", err)
+	}
+	return parseCountryBoundaries(data, "built-in country boundaries")
+}
+
+// parseCountryBoundaries reads country polygons from a GeoJSON FeatureCollection of
+// Polygon and MultiPolygon features, such as Natural Earth's admin 0 countries
+func parseCountryBoundaries(data []byte, source string) (*countryBoundaries, error) {
+	var collection geoJSONFeatureCollection
+	if err := json.Unmarshal(data, &collection); err != nil {
+		return nil, fmt.Errorf("failed to parse country boundaries %s: %v", source, err)
+	}
+
+	var leaves []*rtreeNode
+	countries := 0
+	for _, feature := range collection.Features {
+		country := &countryBoundary{
+			Code: strings.ToUpper(featureProperty(feature.Properties, countryCodeProperties)),
+			Name: featureProperty(feature.Properties, countryNameProperties),
+		}
+		if len(country.Code) != 2 {
+			// Natural Earth uses -99 for disputed areas without a code
+			country.Code = ""
+		}
+		if country.Code == "" && country.Name == "" {
+			continue
+		}
+
+		polygons, err := feature.Geometry.polygons()
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s for %s: %v", strings.ToLower(feature.Geometry.Type), country.Name, err)
+		}
+		if len(polygons) == 0 {
+			continue
+		}
+
+		for _, rings := range polygons {
+			if len(rings) == 0 || len(rings[0]) < 3 {
+				continue
+			}
+			polygon := &boundaryPolygon{country: country, rings: rings, bounds: ringBounds(rings[0])}
+			leaves = append(leaves, &rtreeNode{bounds: polygon.bounds, polygon: polygon})
+		}
+		countries++
+	}
+
+	if len(leaves) == 0 {
+		return nil, fmt.Errorf("no country polygons found in %s", source)
+	}
+	return &countryBoundaries{root: buildRTree(leaves), countries: countries}, nil
+}
+
+// ringBounds returns the bounding box of a ring
+func ringBounds(ring [][2]float64) boundingBox {
+	bounds := boundingBox{MinLon: ring[0][0], MinLat: ring[0][1], MaxLon: ring[0][0], MaxLat: ring[0][1]}
+	for _, point := range ring[1:] {
+		bounds = bounds.extend(boundingBox{MinLon: point[0], MinLat: point[1], MaxLon: point[0], MaxLat: point[1]})
+	}
+	return bounds
+}
+
+// featureProperty returns the first non-empty string property among keys
+func featureProperty(properties map[string]interface{}, keys []string) string {
+	for _, key := range keys {
+		if value, ok := properties[key].(string); ok && value != "" && value != "-99" {
+			return value
+		}
+	}
+	return ""
+}
+
+var (
+	boundaries     *countryBoundaries
+	boundariesOnce sync.Once
+)
+
+// GetCountryBoundaries loads the country polygons once: country_boundaries_file when configured,
+// the built-in Natural Earth boundaries otherwise
+func GetCountryBoundaries() *countryBoundaries {
+	boundariesOnce.Do(func() {
+		path := GetAppConfig().CountryBoundariesFile
+		if path == "" {
+			loaded, err := loadBuiltinCountryBoundaries()
+			if err != nil {
+				fmt.Printf("⚠️  Warning: Country boundaries not used: %v\n", err)
+				return
+			}
+			boundaries = loaded
+			return
+		}
+
+		loaded, err := loadCountryBoundaries(path)
+		if err != nil {
+			fmt.Printf("⚠️  Warning: Country boundaries not used: %v\n", err)
+			return
+		}
+		fmt.Printf("🗺️  Loaded boundaries of %d countries from %s\n", loaded.countries, path)
+		boundaries = loaded
+	})
+
+	return boundaries
+}
+
+// displayName returns the country name used for folders
+func (c *countryBoundary) displayName() string {
+	if name := countryNameForCode(c.Code); name != "" {
+		return name
+	}
+	return c.Name
+}
+
+// resultInCountry reports whether a geocoding result lies in the given country
+func resultInCountry(result *GeocodeResult, country *countryBoundary) bool {
+	if result.CountryCode != "" && country.Code != "" {
+		return strings.EqualFold(result.CountryCode, country.Code)
+	}
+	return countrySlug(result.Country) == countrySlug(country.displayName()) ||
+		countrySlug(result.Country) == countrySlug(country.Name)
+}
+
+// countrySlug normalises a country name the way folder names are built
+func countrySlug(name string) string {
+	return strings.ToLower(strings.ReplaceAll(anglicizeName(name), " ", "-"))
+}
@@ -2,58 +2,171 @@ package main
 
 import (
 	"fmt"
-	"os/exec"
 	"strings"
 	"time"
 )
 
+// metadataDateFormats are the date layouts accepted from metadata date fields
+var metadataDateFormats = []string{
+	"2006:01:02 15:04:05",
+	"2006-01-02 15:04:05",
+	"2006:01:02",
+	"2006-01-02",
+}
+
 // extractPhotoDate extracts the date from photo metadata
 func extractPhotoDate(photoPath string) (time.Time, error) {
-	// Use exiftool to extract date information
-	args := []string{
-		"-DateTimeOriginal",
-		"-CreateDate", 
-		"-DateTime",
-		"-T", // Tab-separated output
-		photoPath,
+	// Read metadata natively where possible (exiftool only for unsupported formats)
+	metadata, err := readMetadata(photoPath)
+	if err != nil {
+		return time.Time{}, err
 	}
 	
-	cmd := exec.Command("exiftool", args...)
-	output, err := cmd.CombinedOutput()
+	return dateFromMetadata(metadata)
+}
+
+// GPS timestamps within gpsClockAgreement of the camera clock confirm it; beyond
+// gpsClockTolerance the GPS fix is considered stale and ignored
+const (
+	gpsClockAgreement = 15 * time.Minute
+	gpsClockTolerance = 24 * time.Hour
+)
+
+// dateFromMetadata resolves the local capture time at the place the photo was taken:
+// OffsetTimeOriginal first, then the GPS UTC timestamp in the coordinates' timezone,
+// then the configured default zone
+func dateFromMetadata(metadata *MediaMetadata) (time.Time, error) {
+	wallClock, field, err := pickMetadataDate(metadata)
+	gpsTime, hasGPSTime := gpsTimestamp(metadata)
+	if err != nil && !hasGPSTime {
+		return time.Time{}, err
+	}
 	
-	if err != nil {
-		return time.Time{}, fmt.Errorf("exiftool failed: %v", err)
+	var coordinateZone *time.Location
+	if metadata.HasGPS {
+		coordinateZone = timezoneForCoordinates(metadata.Latitude, metadata.Longitude)
 	}
 	
-	outputStr := strings.TrimSpace(string(output))
-	if outputStr == "" {
-		return time.Time{}, fmt.Errorf("no date metadata found")
+	// 1. The camera recorded its UTC offset alongside DateTimeOriginal
+	if field == "DateTimeOriginal" && metadata.OffsetTimeOriginal != "" {
+		if zone, ok := parseUTCOffset(metadata.OffsetTimeOriginal); ok {
+			return inZone(wallClock, zone), nil
+		}
 	}
 	
-	// Parse tab-separated output: DateTimeOriginal \t CreateDate \t DateTime
-	parts := strings.Split(outputStr, "\t")
+	isUTC := field == "CreateDate" && metadata.CreateDateIsUTC
+	
+	// 2. GPS time is UTC - express it in the timezone of the coordinates
+	if coordinateZone != nil {
+		if hasGPSTime {
+			local := gpsTime.In(coordinateZone)
+			if field == "" || isUTC {
+				return local, nil
+			}
+			
+			// Keep the camera's exact time when its clock agrees with GPS; correct it when
+			// it was left on another zone, but ignore GPS fixes that are clearly stale
+			drift := absDuration(inZone(wallClock, coordinateZone).Sub(local))
+			if drift < gpsClockAgreement {
+				return inZone(wallClock, coordinateZone), nil
+			}
+			if drift <= gpsClockTolerance {
+				return local, nil
+			}
+		}
+		if isUTC {
+			return wallClock.In(coordinateZone), nil
+		}
+		return inZone(wallClock, coordinateZone), nil
+	}
 	
-	// Try each date field in order of preference
-	dateFormats := []string{
-		"2006:01:02 15:04:05",
-		"2006-01-02 15:04:05",
-		"2006:01:02",
-		"2006-01-02",
+	if field == "" {
+		// Only a GPS timestamp without coordinates - nothing to localise it with
+		return gpsTime.In(defaultTimezone()), nil
 	}
 	
-	for _, part := range parts {
-		dateStr := strings.TrimSpace(part)
+	// 3. Fall back to the default zone
+	if isUTC {
+		return wallClock.In(defaultTimezone()), nil
+	}
+	return inZone(wallClock, defaultTimezone()), nil
+}
+
+// pickMetadataDate returns the first parseable date field in order of preference and its name
+// The returned time holds the field's wall-clock value in UTC
+func pickMetadataDate(metadata *MediaMetadata) (time.Time, string, error) {
+	candidates := []struct {
+		field string
+		value string
+	}{
+		{"DateTimeOriginal", metadata.DateTimeOriginal},
+		{"CreateDate", metadata.CreateDate},
+		{"DateTime", metadata.DateTime},
+	}
+	
+	found := false
+	for _, candidate := range candidates {
+		dateStr := strings.TrimSpace(candidate.value)
 		if dateStr == "" || dateStr == "-" {
 			continue
 		}
+		found = true
 		
-		// Try different date formats
-		for _, format := range dateFormats {
-			if date, err := time.Parse(format, dateStr); err == nil {
-				return date, nil
-			}
+		if date, ok := parseMetadataDate(dateStr); ok {
+			return date, candidate.field, nil
 		}
 	}
 	
-	return time.Time{}, fmt.Errorf("could not parse any date from metadata")
-}
\ No newline at end of file
+	if !found {
+		return time.Time{}, "", fmt.Errorf("no date metadata found")
+	}
+	return time.Time{}, "", fmt.Errorf("could not parse any date from metadata")
+}
+
+// gpsTimestamp combines GPSDateStamp and GPSTimeStamp into a UTC time
+func gpsTimestamp(metadata *MediaMetadata) (time.Time, bool) {
+	if metadata.GPSDateStamp == "" || metadata.GPSTimeStamp == "" {
+		return time.Time{}, false
+	}
+	
+	timeStr := strings.ReplaceAll(strings.TrimSpace(metadata.GPSTimeStamp), " ", ":")
+	if dot := strings.Index(timeStr, "."); dot >= 0 {
+		timeStr = timeStr[:dot]
+	}
+	
+	stamp, ok := parseMetadataDate(strings.TrimSpace(metadata.GPSDateStamp) + " " + timeStr)
+	if !ok || stamp.Year() < 1990 {
+		return time.Time{}, false
+	}
+	return stamp, true
+}
+
+// inZone reinterprets a wall-clock time (held in UTC) as the same wall-clock time in zone
+func inZone(wallClock time.Time, zone *time.Location) time.Time {
+	return time.Date(wallClock.Year(), wallClock.Month(), wallClock.Day(),
+		wallClock.Hour(), wallClock.Minute(), wallClock.Second(), wallClock.Nanosecond(), zone)
+}
+
+// absDuration returns the absolute value of a duration
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+// parseMetadataDate parses a metadata date string, ignoring sub-seconds and offset suffixes
+func parseMetadataDate(dateStr string) (time.Time, bool) {
+	for _, format := range metadataDateFormats {
+		if date, err := time.Parse(format, dateStr); err == nil {
+			return date, true
+		}
+		// Tolerate "2006:01:02 15:04:05.123" and "2006:01:02 15:04:05+02:00"
+		if len(dateStr) > len(format) {
+			if date, err := time.Parse(format, dateStr[:len(format)]); err == nil {
+				return date, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
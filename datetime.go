@@ -14,6 +14,7 @@ import (
 // DateLocationDB stores date to location mappings
 type DateLocationDB struct {
 	DateToLocation map[string]string // date -> location path (e.g., "2025-09-03" -> "2025/spain/palma")
+	Library        *libraryIndex     // GPS photos by capture time, for matches closer than a day
 }
 
 // NewDateLocationDB creates a new date-location database
@@ -67,6 +68,14 @@ func processDateTimeMatching(sourcePath, destPath string, dryRun bool, dryRunSam
 		return nil
 	}
 
+	// GPS photos already in the destination place files taken around the same time exactly
+	if !GetAppConfig().DisableLibraryMatch {
+		fmt.Println("📚 Indexing GPS photos in destination by capture time...")
+		if db.Library, err = buildLibraryIndex(destPath); err != nil {
+			fmt.Printf("⚠️  Warning: Library matching disabled: %v\n", err)
+		}
+	}
+
 	// Show database contents for debugging
 	fmt.Println("\n📋 Date-Location Database:")
 	for date, location := range db.DateToLocation {
@@ -208,6 +217,9 @@ func checkForGPSInSource(sourcePath string) (bool, string, error) {
 func buildDateLocationDB(destPath string) (*DateLocationDB, error) {
 	db := NewDateLocationDB()
 
+	// Folders made under another location_granularity are mapped to today's folders
+	regrouped := regroupDestinationFolders(destPath)
+
 	err := filepath.Walk(destPath, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
@@ -229,6 +241,9 @@ func buildDateLocationDB(destPath string) (*DateLocationDB, error) {
 			fmt.Printf("⚠️  Could not parse %s: %v\n", filepath.Base(path), err)
 			return nil // Continue processing other files
 		}
+		if target, exists := regrouped[location]; exists {
+			location = target
+		}
 
 		// Store in database with special UK replacement logic
 		if existingLocation, exists := db.DateToLocation[date]; exists {
@@ -260,6 +275,19 @@ func extractDateLocationFromPath(filePath, basePath string) (string, string, err
 		return "", "", err
 	}
 
+	// RAW files of RAW+JPEG pairs may sit in a RAW/ subfolder of the location
+	if filepath.Base(filepath.Dir(relPath)) == rawSubfolderName {
+		relPath = filepath.Join(filepath.Dir(filepath.Dir(relPath)), filepath.Base(relPath))
+	}
+
+	// Files in the path template's layout give their date and photo folder directly
+	template := GetPathTemplate()
+	if values, ok := template.parse(relPath); ok {
+		if date, ok := dateFromValues(values); ok {
+			return date.Format("2006-01-02"), template.folderFromValues(values, false), nil
+		}
+	}
+
 	// Extract filename for date parsing
 	filename := filepath.Base(filePath)
 
@@ -277,8 +305,8 @@ func extractDateLocationFromPath(filePath, basePath string) (string, string, err
 	location := dirPath
 
 	// Strip VIDEO-FILES/ prefix if present to get the actual location
-	if strings.HasPrefix(location, "VIDEO-FILES/") {
-		location = strings.TrimPrefix(location, "VIDEO-FILES/")
+	if strings.HasPrefix(location, videoFolderName+"/") {
+		location = strings.TrimPrefix(location, videoFolderName+"/")
 	}
 
 	return date, location, nil
@@ -324,10 +352,43 @@ func processFilesWithDateTimeMatching(sourcePath, destPath string, db *DateLocat
 		}
 	}
 
+	// RAW+JPEG pairs and Live Photo videos are moved with their primary file rather than on their own
+	filesToProcess = pairCompanionFiles(filesToProcess)
+
+	libraryMatched := 0
+
 	// Process the collected files
 	for _, path := range filesToProcess {
-		// Extract date from filename
-		date, err := extractDateFromFilename(filepath.Base(path))
+		// A library photo taken within the match window gives the exact place
+		if location, date, match, found := db.Library.matchFromLibrary(path, destPath); found {
+			method := "nearest"
+			if match.Interpolated {
+				method = "interpolated"
+			}
+			fmt.Printf("🎯 Library match for %s: %s taken %v apart, %s, confidence %.2f -> %s\n",
+				filepath.Base(path), filepath.Base(match.Nearest.Path), match.Gap.Round(time.Second), method, match.Confidence, location)
+			reason := fmt.Sprintf("library match: %s taken %v apart, %s, confidence %.2f",
+				filepath.Base(match.Nearest.Path), match.Gap.Round(time.Second), method, match.Confidence)
+			finalPath, err := moveFileToLocation(path, destPath, location, date, reason, dryRun)
+			if err != nil {
+				return fmt.Errorf("failed to move %s: %v", filepath.Base(path), err)
+			}
+			if activeLocationWriter != nil {
+				activeLocationWriter.add(finalPath, coordinateLocation(match.Lat, match.Lon, locationSourceLibraryMatch))
+			}
+
+			libraryMatched++
+			processedCount++
+			if isVideoFile(path) {
+				videoCount++
+			} else {
+				photoCount++
+			}
+			continue
+		}
+
+		// Resolve the local capture date (metadata first, then filename)
+		date, err := extractMatchDate(path)
 		if err != nil {
 			fmt.Printf("🔍 DEBUG: %s - %v\n", filepath.Base(path), err)
 			unmatchedFiles = append(unmatchedFiles, path)
@@ -336,6 +397,7 @@ func processFilesWithDateTimeMatching(sourcePath, destPath string, db *DateLocat
 
 		// Look up location for this date
 		location, exists := db.DateToLocation[date]
+		reason := fmt.Sprintf("library photos of %s are in %s", date, location)
 		if !exists {
 			fmt.Printf("🔍 DEBUG: %s - date %s not found in database\n", filepath.Base(path), date)
 
@@ -349,6 +411,7 @@ func processFilesWithDateTimeMatching(sourcePath, destPath string, db *DateLocat
 				// Automatically use the nearby location and add to database
 				location = nearbyLocation
 				db.DateToLocation[date] = location
+				reason = fmt.Sprintf("library photos of nearby %s are in %s", nearbyDate, location)
 				fmt.Printf("✅ Added %s -> %s to location database\n", date, location)
 			} else {
 				unmatchedFiles = append(unmatchedFiles, path)
@@ -356,10 +419,18 @@ func processFilesWithDateTimeMatching(sourcePath, destPath string, db *DateLocat
 			}
 		}
 		
+		// The location to write is read from the file before it moves
+		var inferred inferredLocation
+		if activeLocationWriter != nil {
+			inferred = datetimeLocation(db, path, destPath, location)
+		}
+		
 		// Move file to matched location
-		if err := moveFileToLocation(path, destPath, location, date, dryRun); err != nil {
+		finalPath, err := moveFileToLocation(path, destPath, location, date, reason, dryRun)
+		if err != nil {
 			return fmt.Errorf("failed to move %s: %v", filepath.Base(path), err)
 		}
+		activeLocationWriter.add(finalPath, inferred)
 
 		processedCount++
 		if isVideoFile(path) {
@@ -372,6 +443,7 @@ func processFilesWithDateTimeMatching(sourcePath, destPath string, db *DateLocat
 	// Summary
 	fmt.Printf("\n📊 DateTime Processing Summary:\n")
 	fmt.Printf("✅ Total files processed: %d\n", processedCount)
+	fmt.Printf("🎯 Placed by library photos taken at the same time: %d\n", libraryMatched)
 	fmt.Printf("📷 Photos processed: %d\n", photoCount)
 	fmt.Printf("🎥 Videos processed: %d (moved to VIDEO-FILES/)\n", videoCount)
 	fmt.Printf("⚠️  Files unmatched: %d\n", len(unmatchedFiles))
@@ -386,6 +458,15 @@ func processFilesWithDateTimeMatching(sourcePath, destPath string, db *DateLocat
 	return nil
 }
 
+// extractMatchDate returns the YYYY-MM-DD used for date matching, preferring the
+// timezone-resolved capture time from metadata over a date in the filename
+func extractMatchDate(path string) (string, error) {
+	if date, err := extractPhotoDate(path); err == nil {
+		return date.Format("2006-01-02"), nil
+	}
+	return extractDateFromFilename(filepath.Base(path))
+}
+
 // extractDateFromFilename extracts date from filename patterns
 // isValidYear checks if a year is reasonable for photo dates
 func isValidYear(yearStr string) bool {
@@ -467,90 +548,16 @@ func extractDateFromFilename(filename string) (string, error) {
 
 
 // moveFileToLocation moves file to the specified location path, with special handling for video files
-func moveFileToLocation(sourcePath, destBasePath, location, date string, dryRun bool) error {
-	// Parse location to get components for filename
-	locationParts := strings.Split(location, string(filepath.Separator))
-	var city string
-	if len(locationParts) > 0 {
-		city = locationParts[len(locationParts)-1] // Last component is city
-	} else {
-		city = "unknown"
-	}
+// It returns the file's final path
+func moveFileToLocation(sourcePath, destBasePath, location, date, reason string, dryRun bool) (string, error) {
+	// The location folder names the country and city; the path template places the file
+	country, city := locationFields(location)
 
 	// Generate new filename preserving existing hour+minute if present
 	dateTime, _ := time.Parse("2006-01-02", date) // Convert date string back to time for helper
-	newFilename := generateFilenameWithTime(sourcePath, dateTime, city)
-
-	var destDir string
-	var fileType string
-
-	// Check if this is a video file
-	if isVideoFile(sourcePath) {
-		// For video files, place in VIDEO-FILES/YYYY/COUNTRY/CITY structure
-		fileType = "video"
-		destDir = filepath.Join(destBasePath, "VIDEO-FILES", location)
-		if dryRun {
-			fmt.Printf("🎥 [DRY RUN] Processing video file: %s\n", filepath.Base(sourcePath))
-		} else {
-			fmt.Printf("🎥 Processing video file: %s\n", filepath.Base(sourcePath))
-		}
-	} else {
-		// For photo files, use the regular location structure
-		fileType = "photo"
-		destDir = filepath.Join(destBasePath, location)
-		if dryRun {
-			fmt.Printf("📷 [DRY RUN] Processing photo file: %s\n", filepath.Base(sourcePath))
-		} else {
-			fmt.Printf("📷 Processing photo file: %s\n", filepath.Base(sourcePath))
-		}
-	}
-
-	destPath := filepath.Join(destDir, newFilename)
-
-	// Handle duplicates simulation
-	finalPath := destPath
-	counter := 1
-	for {
-		if _, err := os.Stat(finalPath); os.IsNotExist(err) {
-			break
-		}
-
-		base := strings.TrimSuffix(newFilename, ext)
-		duplicateFilename := fmt.Sprintf("%s-%d%s", base, counter, ext)
-		finalPath = filepath.Join(destDir, duplicateFilename)
-		counter++
-
-		if counter > 1000 {
-			return fmt.Errorf("too many duplicate filenames")
-		}
-	}
-
-	if dryRun {
-		// Dry run mode - just show what would happen
-		if fileType == "video" {
-			fmt.Printf("✅ [DRY RUN] Video would be moved to: %s\n", finalPath)
-		} else {
-			fmt.Printf("✅ [DRY RUN] Photo would be moved to: %s\n", finalPath)
-		}
-		return nil
-	}
-
-	// Create directory structure if it doesn't exist
-	if err := os.MkdirAll(destDir, 0755); err != nil {
-		return fmt.Errorf("failed to create directory %s: %v", destDir, err)
-	}
-
-	// Move the file
-	if err := os.Rename(sourcePath, finalPath); err != nil {
-		return err
-	}
-
-	if fileType == "video" {
-		fmt.Printf("✅ Video moved to: %s\n", finalPath)
-	} else {
-		fmt.Printf("✅ Photo moved to: %s\n", finalPath)
-	}
-	return nil
+	fields := newLayoutFields(sourcePath, dateTime, country, city)
+	fields.Reason = reason
+	return moveIntoLayout(sourcePath, destBasePath, fields, dryRun)
 }
 
 // promptForConfirmation prompts user for y/n confirmation
@@ -0,0 +1,461 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// TIFF/EXIF tag IDs read by the native parser
+const (
+	tagImageDescription   = 0x010E
+	tagMake               = 0x010F
+	tagModel              = 0x0110
+	tagModifyDate         = 0x0132
+	tagExifIFDPointer     = 0x8769
+	tagGPSIFDPointer      = 0x8825
+	tagDateTimeOriginal   = 0x9003
+	tagCreateDate         = 0x9004
+	tagOffsetTime         = 0x9010
+	tagOffsetTimeOriginal = 0x9011
+	tagMakerNote          = 0x927C
+	tagBodySerialNumber   = 0xA431
+
+	// Apple maker note
+	tagAppleContentIdentifier = 0x0011
+
+	tagGPSLatitudeRef  = 0x0001
+	tagGPSLatitude     = 0x0002
+	tagGPSLongitudeRef = 0x0003
+	tagGPSLongitude    = 0x0004
+	tagGPSTimeStamp    = 0x0007
+	tagGPSDateStamp    = 0x001D
+)
+
+// maxIFDEntries guards against corrupt entry counts sending the parser off into the weeds
+const maxIFDEntries = 1024
+
+// tiffTypeSizes maps TIFF field types to their size in bytes
+var tiffTypeSizes = map[uint16]int{
+	1:  1, // BYTE
+	2:  1, // ASCII
+	3:  2, // SHORT
+	4:  4, // LONG
+	5:  8, // RATIONAL
+	6:  1, // SBYTE
+	7:  1, // UNDEFINED
+	8:  2, // SSHORT
+	9:  4, // SLONG
+	10: 8, // SRATIONAL
+	11: 4, // FLOAT
+	12: 8, // DOUBLE
+	13: 4, // IFD (an offset, used by some writers for the Exif and GPS IFD pointers)
+}
+
+// nativeTIFFExtensions are TIFF-structured formats (including most RAW files) the native reader parses directly
+var nativeTIFFExtensions = map[string]bool{
+	".tif": true, ".tiff": true, ".dng": true, ".cr2": true, ".nef": true,
+	".arw": true, ".pef": true, ".srw": true, ".3fr": true, ".erf": true,
+	".kdc": true, ".dcr": true, ".iiq": true,
+}
+
+// exifMetadataReader reads EXIF directly from JPEG and TIFF-based files without spawning exiftool
+type exifMetadataReader struct{}
+
+func (r *exifMetadataReader) Name() string { return "exif" }
+
+// Supports reports whether the file extension is one the native EXIF parser handles
+func (r *exifMetadataReader) Supports(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".jpg" || ext == ".jpeg" || nativeTIFFExtensions[ext]
+}
+
+// Read parses EXIF from a JPEG APP1 segment or a TIFF header at the start of the file
+func (r *exifMetadataReader) Read(path string) (*MediaMetadata, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %v", err)
+	}
+	defer file.Close()
+
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(file, header); err != nil {
+		return nil, errUnsupportedFormat
+	}
+
+	switch {
+	case header[0] == 0xFF && header[1] == 0xD8:
+		exifData, err := findJPEGExif(file)
+		if err != nil {
+			return nil, err
+		}
+		if exifData == nil {
+			// Valid JPEG without an EXIF segment
+			return &MediaMetadata{Source: "exif"}, nil
+		}
+		return parseTIFFMetadata(bytes.NewReader(exifData), 0, int64(len(exifData)))
+	case isTIFFHeader(header):
+		info, err := file.Stat()
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat file: %v", err)
+		}
+		return parseTIFFMetadata(file, 0, info.Size())
+	default:
+		return nil, errUnsupportedFormat
+	}
+}
+
+// isTIFFHeader checks for the little-endian "II*\0" or big-endian "MM\0*" TIFF signature
+func isTIFFHeader(header []byte) bool {
+	if len(header) < 4 {
+		return false
+	}
+	return (header[0] == 'I' && header[1] == 'I' && header[2] == 0x2A && header[3] == 0x00) ||
+		(header[0] == 'M' && header[1] == 'M' && header[2] == 0x00 && header[3] == 0x2A)
+}
+
+// findJPEGExif walks JPEG markers after SOI and returns the TIFF payload of the first Exif APP1 segment
+func findJPEGExif(file *os.File) ([]byte, error) {
+	if _, err := file.Seek(2, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to seek: %v", err)
+	}
+	reader := bufio.NewReader(file)
+
+	for {
+		marker, err := readJPEGMarker(reader)
+		if err != nil {
+			return nil, err
+		}
+
+		// Start of scan or end of image - no more metadata segments follow
+		if marker == 0xDA || marker == 0xD9 {
+			return nil, nil
+		}
+		// Standalone markers carry no length field
+		if marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7) {
+			continue
+		}
+
+		lengthBytes := make([]byte, 2)
+		if _, err := io.ReadFull(reader, lengthBytes); err != nil {
+			return nil, fmt.Errorf("truncated JPEG segment: %v", err)
+		}
+		length := int(binary.BigEndian.Uint16(lengthBytes)) - 2
+		if length < 0 {
+			return nil, fmt.Errorf("invalid JPEG segment length")
+		}
+
+		if marker == 0xE1 && length >= 6 {
+			segment := make([]byte, length)
+			if _, err := io.ReadFull(reader, segment); err != nil {
+				return nil, fmt.Errorf("truncated APP1 segment: %v", err)
+			}
+			if bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+				return segment[6:], nil
+			}
+			// XMP or another APP1 payload - keep looking
+			continue
+		}
+
+		if _, err := reader.Discard(length); err != nil {
+			return nil, fmt.Errorf("truncated JPEG segment: %v", err)
+		}
+	}
+}
+
+// readJPEGMarker reads the next marker byte, skipping any 0xFF fill bytes
+func readJPEGMarker(reader *bufio.Reader) (byte, error) {
+	b, err := reader.ReadByte()
+	if err != nil {
+		return 0, fmt.Errorf("truncated JPEG: %v", err)
+	}
+	if b != 0xFF {
+		return 0, fmt.Errorf("invalid JPEG marker 0x%02X", b)
+	}
+	for b == 0xFF {
+		b, err = reader.ReadByte()
+		if err != nil {
+			return 0, fmt.Errorf("truncated JPEG: %v", err)
+		}
+	}
+	return b, nil
+}
+
+// tiffParser reads IFDs from a TIFF structure starting at base within r
+type tiffParser struct {
+	r     io.ReaderAt
+	base  int64
+	size  int64
+	order binary.ByteOrder
+}
+
+// tiffEntry is a single decoded IFD entry
+type tiffEntry struct {
+	tag   uint16
+	typ   uint16
+	count uint32
+	data  []byte
+}
+
+// newTIFFParser validates the TIFF header at base and returns a parser plus the IFD0 offset
+func newTIFFParser(r io.ReaderAt, base, size int64) (*tiffParser, uint32, error) {
+	header := make([]byte, 8)
+	if _, err := r.ReadAt(header, base); err != nil {
+		return nil, 0, fmt.Errorf("failed to read TIFF header: %v", err)
+	}
+
+	parser := &tiffParser{r: r, base: base, size: size}
+	switch {
+	case header[0] == 'I' && header[1] == 'I':
+		parser.order = binary.LittleEndian
+	case header[0] == 'M' && header[1] == 'M':
+		parser.order = binary.BigEndian
+	default:
+		return nil, 0, errUnsupportedFormat
+	}
+	if parser.order.Uint16(header[2:4]) != 0x2A {
+		return nil, 0, errUnsupportedFormat
+	}
+
+	return parser, parser.order.Uint32(header[4:8]), nil
+}
+
+// readAt reads n bytes at an offset relative to the TIFF header
+func (p *tiffParser) readAt(offset int64, n int) ([]byte, error) {
+	if offset < 0 || n < 0 || p.base+offset+int64(n) > p.size {
+		return nil, fmt.Errorf("offset %d out of range", offset)
+	}
+	buf := make([]byte, n)
+	if _, err := p.r.ReadAt(buf, p.base+offset); err != nil {
+		return nil, fmt.Errorf("failed to read at offset %d: %v", offset, err)
+	}
+	return buf, nil
+}
+
+// readIFD decodes every entry of the IFD at offset, resolving out-of-line values
+func (p *tiffParser) readIFD(offset uint32) ([]tiffEntry, uint32, error) {
+	countBytes, err := p.readAt(int64(offset), 2)
+	if err != nil {
+		return nil, 0, err
+	}
+	count := int(p.order.Uint16(countBytes))
+	if count > maxIFDEntries {
+		return nil, 0, fmt.Errorf("IFD entry count %d too large", count)
+	}
+
+	raw, err := p.readAt(int64(offset)+2, count*12+4)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	entries := make([]tiffEntry, 0, count)
+	for i := 0; i < count; i++ {
+		field := raw[i*12 : i*12+12]
+		entry := tiffEntry{
+			tag:   p.order.Uint16(field[0:2]),
+			typ:   p.order.Uint16(field[2:4]),
+			count: p.order.Uint32(field[4:8]),
+		}
+
+		typeSize, known := tiffTypeSizes[entry.typ]
+		if !known {
+			continue
+		}
+		total := int64(typeSize) * int64(entry.count)
+		if total > 1<<20 {
+			// Far bigger than any tag we read - skip rather than allocate
+			continue
+		}
+
+		if total <= 4 {
+			entry.data = field[8 : 8+total]
+		} else {
+			valueOffset := p.order.Uint32(field[8:12])
+			data, err := p.readAt(int64(valueOffset), int(total))
+			if err != nil {
+				continue
+			}
+			entry.data = data
+		}
+		entries = append(entries, entry)
+	}
+
+	next := p.order.Uint32(raw[count*12:])
+	return entries, next, nil
+}
+
+// asString decodes an ASCII entry, trimming NUL padding and whitespace
+func (e tiffEntry) asString() string {
+	return strings.TrimSpace(strings.TrimRight(string(e.data), "\x00"))
+}
+
+// asUint32 decodes the first SHORT or LONG value of an entry
+func (p *tiffParser) asUint32(e tiffEntry) (uint32, bool) {
+	switch e.typ {
+	case 3:
+		if len(e.data) >= 2 {
+			return uint32(p.order.Uint16(e.data)), true
+		}
+	case 4, 13:
+		if len(e.data) >= 4 {
+			return p.order.Uint32(e.data), true
+		}
+	}
+	return 0, false
+}
+
+// asRationals decodes RATIONAL values as floats
+func (p *tiffParser) asRationals(e tiffEntry) []float64 {
+	if e.typ != 5 && e.typ != 10 {
+		return nil
+	}
+	values := make([]float64, 0, len(e.data)/8)
+	for i := 0; i+8 <= len(e.data); i += 8 {
+		var num, den float64
+		if e.typ == 5 {
+			num = float64(p.order.Uint32(e.data[i:]))
+			den = float64(p.order.Uint32(e.data[i+4:]))
+		} else {
+			num = float64(int32(p.order.Uint32(e.data[i:])))
+			den = float64(int32(p.order.Uint32(e.data[i+4:])))
+		}
+		if den == 0 {
+			values = append(values, 0)
+			continue
+		}
+		values = append(values, num/den)
+	}
+	return values
+}
+
+// parseTIFFMetadata parses IFD0, the Exif IFD and the GPS IFD of the TIFF structure at base
+func parseTIFFMetadata(r io.ReaderAt, base, size int64) (*MediaMetadata, error) {
+	parser, ifd0Offset, err := newTIFFParser(r, base, size)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := &MediaMetadata{Source: "exif"}
+
+	ifd0, _, err := parser.readIFD(ifd0Offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read IFD0: %v", err)
+	}
+
+	var exifOffset, gpsOffset uint32
+	for _, entry := range ifd0 {
+		switch entry.tag {
+		case tagImageDescription:
+			metadata.ImageDescription = entry.asString()
+		case tagMake:
+			metadata.Make = entry.asString()
+		case tagModel:
+			metadata.Model = entry.asString()
+		case tagModifyDate:
+			metadata.ModifyDate = entry.asString()
+			metadata.DateTime = metadata.ModifyDate
+		case tagExifIFDPointer:
+			exifOffset, _ = parser.asUint32(entry)
+		case tagGPSIFDPointer:
+			gpsOffset, _ = parser.asUint32(entry)
+		}
+	}
+
+	if exifOffset != 0 {
+		exifIFD, _, err := parser.readIFD(exifOffset)
+		if err == nil {
+			for _, entry := range exifIFD {
+				switch entry.tag {
+				case tagDateTimeOriginal:
+					metadata.DateTimeOriginal = entry.asString()
+				case tagCreateDate:
+					metadata.CreateDate = entry.asString()
+				case tagOffsetTime:
+					metadata.OffsetTime = entry.asString()
+				case tagOffsetTimeOriginal:
+					metadata.OffsetTimeOriginal = entry.asString()
+				case tagBodySerialNumber:
+					metadata.SerialNumber = entry.asString()
+				case tagMakerNote:
+					metadata.ContentIdentifier = parseAppleMakerNote(entry.data)
+				}
+			}
+		}
+	}
+
+	if gpsOffset != 0 {
+		gpsIFD, _, err := parser.readIFD(gpsOffset)
+		if err == nil {
+			parseGPSIFD(parser, gpsIFD, metadata)
+		}
+	}
+
+	return metadata, nil
+}
+
+// parseAppleMakerNote returns the Live Photo content identifier from an Apple maker note
+// Layout: "Apple iOS\0", a 2-byte version, "MM", then an IFD with offsets relative to the note
+func parseAppleMakerNote(note []byte) string {
+	if len(note) < 16 || !bytes.HasPrefix(note, []byte("Apple iOS\x00")) || string(note[12:14]) != "MM" {
+		return ""
+	}
+
+	parser := &tiffParser{r: bytes.NewReader(note), size: int64(len(note)), order: binary.BigEndian}
+	entries, _, err := parser.readIFD(14)
+	if err != nil {
+		return ""
+	}
+	for _, entry := range entries {
+		if entry.tag == tagAppleContentIdentifier && entry.typ == 2 {
+			return entry.asString()
+		}
+	}
+	return ""
+}
+
+// parseGPSIFD fills in coordinates and the GPS timestamp from the GPS IFD entries
+func parseGPSIFD(parser *tiffParser, entries []tiffEntry, metadata *MediaMetadata) {
+	var latRef, lonRef string
+	var lat, lon []float64
+
+	for _, entry := range entries {
+		switch entry.tag {
+		case tagGPSLatitudeRef:
+			latRef = entry.asString()
+		case tagGPSLatitude:
+			lat = parser.asRationals(entry)
+		case tagGPSLongitudeRef:
+			lonRef = entry.asString()
+		case tagGPSLongitude:
+			lon = parser.asRationals(entry)
+		case tagGPSTimeStamp:
+			if parts := parser.asRationals(entry); len(parts) == 3 {
+				metadata.GPSTimeStamp = fmt.Sprintf("%02d:%02d:%02d", int(parts[0]), int(parts[1]), int(parts[2]))
+			}
+		case tagGPSDateStamp:
+			metadata.GPSDateStamp = entry.asString()
+		}
+	}
+
+	if len(lat) != 3 || len(lon) != 3 {
+		return
+	}
+
+	latitude := lat[0] + lat[1]/60 + lat[2]/3600
+	longitude := lon[0] + lon[1]/60 + lon[2]/3600
+	if strings.EqualFold(latRef, "S") {
+		latitude = -latitude
+	}
+	if strings.EqualFold(lonRef, "W") {
+		longitude = -longitude
+	}
+
+	metadata.HasGPS = true
+	metadata.Latitude = latitude
+	metadata.Longitude = longitude
+}
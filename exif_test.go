@@ -0,0 +1,94 @@
+package main
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExifMetadataReaderRead(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		want MediaMetadata
+	}{
+		{
+			name: "JPEG with GPS after an XMP segment",
+			path: "testdata/exif/gps.jpg",
+			want: MediaMetadata{
+				Source:             "exif",
+				HasGPS:             true,
+				Latitude:           48.8582,
+				Longitude:          2.29450,
+				DateTimeOriginal:   "2023:07:14 18:30:05",
+				CreateDate:         "2023:07:14 18:30:05",
+				DateTime:           "2023:07:14 18:31:00",
+				ModifyDate:         "2023:07:14 18:31:00",
+				OffsetTimeOriginal: "+02:00",
+				GPSDateStamp:       "2023:07:14",
+				GPSTimeStamp:       "16:30:05",
+				Make:               "Apple",
+				Model:              "iPhone 13",
+			},
+		},
+		{
+			name: "JPEG without EXIF",
+			path: "testdata/exif/no-exif.jpg",
+			want: MediaMetadata{Source: "exif"},
+		},
+		{
+			name: "big-endian TIFF with IFD-typed pointers",
+			path: "testdata/exif/ifd-pointers.tif",
+			want: MediaMetadata{
+				Source:             "exif",
+				HasGPS:             true,
+				Latitude:           -48.8582,
+				Longitude:          -2.29450,
+				DateTimeOriginal:   "2023:07:14 18:30:05",
+				CreateDate:         "2023:07:14 18:30:05",
+				DateTime:           "2023:07:14 18:31:00",
+				ModifyDate:         "2023:07:14 18:31:00",
+				OffsetTimeOriginal: "+02:00",
+				GPSDateStamp:       "2023:07:14",
+				GPSTimeStamp:       "16:30:05",
+				Make:               "Apple",
+				Model:              "iPhone 13",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := (&exifMetadataReader{}).Read(tt.path)
+			if err != nil {
+				t.Fatalf("Read(%s) failed: %v", tt.path, err)
+			}
+			assertMetadata(t, got, tt.want)
+		})
+	}
+}
+
+func TestExifMetadataReaderRejectsOtherFormats(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "movie.jpg")
+	if err := os.WriteFile(path, []byte("\x00\x00\x00\x14ftypqt  "), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (&exifMetadataReader{}).Read(path); err != errUnsupportedFormat {
+		t.Errorf("Read of a QuickTime file returned %v, want errUnsupportedFormat", err)
+	}
+}
+
+// assertMetadata compares metadata field by field, allowing rounding in the coordinates
+func assertMetadata(t *testing.T, got *MediaMetadata, want MediaMetadata) {
+	t.Helper()
+	if math.Abs(got.Latitude-want.Latitude) > 1e-6 || math.Abs(got.Longitude-want.Longitude) > 1e-6 {
+		t.Errorf("coordinates = %.6f,%.6f, want %.6f,%.6f", got.Latitude, got.Longitude, want.Latitude, want.Longitude)
+	}
+	gotFields, wantFields := *got, want
+	gotFields.Latitude, gotFields.Longitude = 0, 0
+	wantFields.Latitude, wantFields.Longitude = 0, 0
+	if gotFields != wantFields {
+		t.Errorf("metadata = %+v, want %+v", gotFields, wantFields)
+	}
+}
@@ -0,0 +1,354 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// exiftoolProcess is a single long-lived "exiftool -stay_open True -@ -" process
+type exiftoolProcess struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+	stderr *bufio.Reader
+
+	waitOnce sync.Once
+	waitDone chan struct{}
+}
+
+// wait reaps the process exactly once, however many callers are waiting on it
+func (proc *exiftoolProcess) wait() {
+	proc.waitOnce.Do(func() {
+		proc.cmd.Wait()
+		close(proc.waitDone)
+	})
+	<-proc.waitDone
+}
+
+// ExiftoolPool hands out long-lived exiftool processes, one per worker slot
+type ExiftoolPool struct {
+	ctx    context.Context
+	slots  chan *exiftoolProcess // nil entries are slots whose process has not been started yet
+	size   int
+	seq    uint64
+	mu     sync.Mutex
+	live   map[*exiftoolProcess]bool
+	closed bool
+}
+
+// Global exiftool pool shared by metadata reads and writes
+var (
+	globalExiftoolPool *ExiftoolPool
+	exiftoolPoolMu     sync.Mutex
+)
+
+// exiftoolShutdownTimeout bounds how long Close waits for a process to exit cleanly
+const exiftoolShutdownTimeout = 2 * time.Second
+
+// NewExiftoolPool creates a pool with size process slots bound to ctx
+// Processes are started lazily on first use
+func NewExiftoolPool(ctx context.Context, size int) *ExiftoolPool {
+	if size < 1 {
+		size = 1
+	}
+
+	pool := &ExiftoolPool{
+		ctx:   ctx,
+		slots: make(chan *exiftoolProcess, size),
+		size:  size,
+		live:  make(map[*exiftoolProcess]bool),
+	}
+	for i := 0; i < size; i++ {
+		pool.slots <- nil
+	}
+
+	// Tear the processes down as soon as the run is cancelled
+	go func() {
+		<-ctx.Done()
+		pool.Close()
+	}()
+
+	return pool
+}
+
+// InitExiftoolPool replaces the global pool with one sized for the given worker count
+func InitExiftoolPool(ctx context.Context, workers int) *ExiftoolPool {
+	exiftoolPoolMu.Lock()
+	defer exiftoolPoolMu.Unlock()
+
+	if globalExiftoolPool != nil {
+		globalExiftoolPool.Close()
+	}
+	globalExiftoolPool = NewExiftoolPool(ctx, workers)
+	return globalExiftoolPool
+}
+
+// GetExiftoolPool returns the global pool, creating a single-process pool for sequential commands
+func GetExiftoolPool() *ExiftoolPool {
+	exiftoolPoolMu.Lock()
+	defer exiftoolPoolMu.Unlock()
+
+	if globalExiftoolPool == nil || globalExiftoolPool.isClosed() {
+		globalExiftoolPool = NewExiftoolPool(context.Background(), 1)
+	}
+	return globalExiftoolPool
+}
+
+// CloseExiftoolPool shuts down the global pool's processes
+func CloseExiftoolPool() {
+	exiftoolPoolMu.Lock()
+	defer exiftoolPoolMu.Unlock()
+
+	if globalExiftoolPool != nil {
+		globalExiftoolPool.Close()
+		globalExiftoolPool = nil
+	}
+}
+
+// isClosed reports whether Close has been called
+func (p *ExiftoolPool) isClosed() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.closed
+}
+
+// Execute runs one exiftool command (args as they would appear on the command line)
+// and returns its stdout and stderr output
+func (p *ExiftoolPool) Execute(args ...string) (string, string, error) {
+	var proc *exiftoolProcess
+	select {
+	case proc = <-p.slots:
+	case <-p.ctx.Done():
+		return "", "", fmt.Errorf("exiftool cancelled: %v", p.ctx.Err())
+	}
+
+	stdout, stderr, proc, err := p.executeWithRestart(proc, args)
+	p.slots <- proc
+	return stdout, stderr, err
+}
+
+// executeWithRestart runs args on proc, restarting the process once if it has crashed
+func (p *ExiftoolPool) executeWithRestart(proc *exiftoolProcess, args []string) (string, string, *exiftoolProcess, error) {
+	var lastErr error
+	for attempt := 0; attempt < 2; attempt++ {
+		if proc == nil {
+			started, err := p.start()
+			if err != nil {
+				return "", "", nil, err
+			}
+			proc = started
+		}
+
+		stdout, stderr, err := p.run(proc, args)
+		if err == nil {
+			return stdout, stderr, proc, nil
+		}
+
+		// The process died or its pipes broke - discard it and retry with a fresh one
+		lastErr = err
+		p.discard(proc)
+		proc = nil
+
+		if p.ctx.Err() != nil || p.isClosed() {
+			break
+		}
+	}
+
+	return "", "", nil, fmt.Errorf("exiftool failed: %v", lastErr)
+}
+
+// exiftoolConfig defines the XMP-photometa namespace photo-meta records provenance in
+// (XMP-photometa:LocationSource). The user's own ~/.ExifTool_config is loaded first, since
+// -config replaces it
+const exiftoolConfig = `# Written by photo-meta - changes are overwritten
+my $userConfig = ($ENV{EXIFTOOL_HOME} || $ENV{HOME} || '.') . '/.ExifTool_config';
+do $userConfig if -f $userConfig;
+
+$Image::ExifTool::UserDefined{'Image::ExifTool::XMP::Main'}{photometa} = {
+    SubDirectory => { TagTable => 'Image::ExifTool::UserDefined::photometa' },
+};
+%Image::ExifTool::UserDefined::photometa = (
+    GROUPS => { 0 => 'XMP', 1 => 'XMP-photometa', 2 => 'Location' },
+    NAMESPACE => { 'photometa' => 'https://github.com/billysbar/photo-meta/ns/1.0/' },
+    WRITABLE => 'string',
+    LocationSource => { },
+);
+1;
+`
+
+var (
+	exiftoolConfigFile     string
+	exiftoolConfigFileOnce sync.Once
+)
+
+// exiftoolConfigPath writes the exiftool config next to the GPS cache database once and
+// returns its path; "" when it cannot be written
+func exiftoolConfigPath() string {
+	exiftoolConfigFileOnce.Do(func() {
+		path := filepath.Join(filepath.Dir(getDefaultCachePath()), "photo-meta-exiftool.config")
+		if err := os.WriteFile(path, []byte(exiftoolConfig), 0644); err != nil {
+			fmt.Printf("⚠️  Warning: Could not write exiftool config, provenance tags will not be written: %v\n", err)
+			return
+		}
+		exiftoolConfigFile = path
+	})
+	return exiftoolConfigFile
+}
+
+// start launches a new stay_open exiftool process
+func (p *ExiftoolPool) start() (*exiftoolProcess, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return nil, fmt.Errorf("exiftool pool is closed")
+	}
+
+	args := []string{"-stay_open", "True", "-@", "-"}
+	if configPath := exiftoolConfigPath(); configPath != "" {
+		args = append([]string{"-config", configPath}, args...)
+	}
+	cmd := exec.Command("exiftool", args...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create exiftool stdin: %v", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create exiftool stdout: %v", err)
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create exiftool stderr: %v", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start exiftool: %v", err)
+	}
+
+	proc := &exiftoolProcess{
+		cmd:      cmd,
+		stdin:    stdin,
+		stdout:   bufio.NewReader(stdout),
+		stderr:   bufio.NewReader(stderr),
+		waitDone: make(chan struct{}),
+	}
+	p.live[proc] = true
+	return proc, nil
+}
+
+// run sends one command to proc and reads output up to the matching ready markers
+func (p *ExiftoolPool) run(proc *exiftoolProcess, args []string) (string, string, error) {
+	seq := atomic.AddUint64(&p.seq, 1)
+
+	var request strings.Builder
+	for _, arg := range args {
+		// The argument file format is one argument per line
+		if strings.ContainsAny(arg, "\r\n") {
+			return "", "", fmt.Errorf("exiftool argument contains a newline: %q", arg)
+		}
+		request.WriteString(arg)
+		request.WriteString("\n")
+	}
+	// -echo4 writes the stderr marker after processing so both streams can be delimited
+	fmt.Fprintf(&request, "-echo4\n{readyerr%d}\n-execute%d\n", seq, seq)
+
+	if _, err := io.WriteString(proc.stdin, request.String()); err != nil {
+		return "", "", fmt.Errorf("failed to write to exiftool: %v", err)
+	}
+
+	stdout, err := readUntilMarker(proc.stdout, fmt.Sprintf("{ready%d}", seq))
+	if err != nil {
+		return "", "", err
+	}
+	stderr, err := readUntilMarker(proc.stderr, fmt.Sprintf("{readyerr%d}", seq))
+	if err != nil {
+		return "", "", err
+	}
+
+	return stdout, stderr, nil
+}
+
+// readUntilMarker reads lines until a line equal to marker, returning everything before it
+func readUntilMarker(reader *bufio.Reader, marker string) (string, error) {
+	var output strings.Builder
+	for {
+		line, err := reader.ReadString('\n')
+		if strings.TrimRight(line, "\r\n") == marker {
+			return output.String(), nil
+		}
+		output.WriteString(line)
+		if err != nil {
+			return "", fmt.Errorf("exiftool process exited: %v", err)
+		}
+	}
+}
+
+// discard kills a broken process and forgets about it
+func (p *ExiftoolPool) discard(proc *exiftoolProcess) {
+	p.mu.Lock()
+	delete(p.live, proc)
+	p.mu.Unlock()
+
+	proc.stdin.Close()
+	if proc.cmd.Process != nil {
+		proc.cmd.Process.Kill()
+	}
+	proc.wait()
+}
+
+// Close asks every process to exit and kills any that do not exit promptly
+func (p *ExiftoolPool) Close() {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+	procs := make([]*exiftoolProcess, 0, len(p.live))
+	for proc := range p.live {
+		procs = append(procs, proc)
+	}
+	p.live = make(map[*exiftoolProcess]bool)
+	p.mu.Unlock()
+
+	for _, proc := range procs {
+		io.WriteString(proc.stdin, "-stay_open\nFalse\n")
+		proc.stdin.Close()
+
+		go proc.wait()
+
+		select {
+		case <-proc.waitDone:
+		case <-time.After(exiftoolShutdownTimeout):
+			if proc.cmd.Process != nil {
+				proc.cmd.Process.Kill()
+			}
+		}
+	}
+}
+
+// exiftoolWriteError checks the output of a write command, returning exiftool's error if the file was not written
+func exiftoolWriteError(stdout, stderr string) error {
+	for _, line := range strings.Split(stderr, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "Error") {
+			return fmt.Errorf("%s", line)
+		}
+	}
+	if strings.Contains(stdout, "image files updated") && !strings.Contains(stdout, " 0 image files updated") {
+		return nil
+	}
+	if strings.Contains(stdout, "image files unchanged") {
+		return nil
+	}
+	return fmt.Errorf("file not updated: %s", strings.TrimSpace(stdout+" "+stderr))
+}
@@ -69,6 +69,9 @@ func processFilesWithFallbackOrganization(sourcePath, destPath string, dryRun bo
 		}
 	}
 
+	// RAW+JPEG pairs and Live Photo videos are moved with their primary file rather than on their own
+	filesToProcess = pairCompanionFiles(filesToProcess)
+
 	// Process the collected files
 	for _, path := range filesToProcess {
 		// Extract date from filename
@@ -102,9 +105,11 @@ func processFilesWithFallbackOrganization(sourcePath, destPath string, dryRun bo
 			}
 
 			// Move file to fallback location with prompted location info
-			if err := moveFileToFallbackLocationWithLocation(path, destPath, location, date, country, city, dryRun); err != nil {
+			finalPath, err := moveFileToFallbackLocationWithLocation(path, destPath, date, country, city, "date in the filename, location entered at the prompt", dryRun)
+			if err != nil {
 				return fmt.Errorf("failed to move %s: %v", filepath.Base(path), err)
 			}
+			activeLocationWriter.add(finalPath, folderLocation(filepath.Join(country, city), locationSourceUser))
 
 			processedCount++
 			if isVideoFile(path) {
@@ -136,89 +141,6 @@ func processFilesWithFallbackOrganization(sourcePath, destPath string, dryRun bo
 	return nil
 }
 
-// moveFileToFallbackLocation moves file to the fallback year/month location
-func moveFileToFallbackLocation(sourcePath, destBasePath, location, date string, dryRun bool) error {
-	// Parse date string to time.Time for generateFilenameWithTime
-	parsedDate, err := time.Parse("2006-01-02", date)
-	if err != nil {
-		return fmt.Errorf("failed to parse date %s: %v", date, err)
-	}
-
-	// Generate new filename preserving existing hour+minute if present
-	newFilename := generateFilenameWithTime(sourcePath, parsedDate, "")
-
-	var destDir string
-	var fileType string
-
-	// Check if this is a video file
-	if isVideoFile(sourcePath) {
-		// For video files, place in VIDEO-FILES/YYYY/Month structure
-		fileType = "video"
-		destDir = filepath.Join(destBasePath, "VIDEO-FILES", location)
-		if dryRun {
-			fmt.Printf("🎥 [DRY RUN] Processing video file: %s\n", filepath.Base(sourcePath))
-		} else {
-			fmt.Printf("🎥 Processing video file: %s\n", filepath.Base(sourcePath))
-		}
-	} else {
-		// For photo files, use the regular location structure
-		fileType = "photo"
-		destDir = filepath.Join(destBasePath, location)
-		if dryRun {
-			fmt.Printf("📷 [DRY RUN] Processing photo file: %s\n", filepath.Base(sourcePath))
-		} else {
-			fmt.Printf("📷 Processing photo file: %s\n", filepath.Base(sourcePath))
-		}
-	}
-
-	destPath := filepath.Join(destDir, newFilename)
-
-	// Handle duplicates simulation
-	finalPath := destPath
-	counter := 1
-	for {
-		if _, err := os.Stat(finalPath); os.IsNotExist(err) {
-			break
-		}
-
-		base := strings.TrimSuffix(newFilename, ext)
-		duplicateFilename := fmt.Sprintf("%s-%d%s", base, counter, ext)
-		finalPath = filepath.Join(destDir, duplicateFilename)
-		counter++
-
-		if counter > 1000 {
-			return fmt.Errorf("too many duplicate filenames")
-		}
-	}
-
-	if dryRun {
-		// Dry run mode - just show what would happen
-		if fileType == "video" {
-			fmt.Printf("✅ [DRY RUN] Video would be moved to: %s\n", finalPath)
-		} else {
-			fmt.Printf("✅ [DRY RUN] Photo would be moved to: %s\n", finalPath)
-		}
-		return nil
-	}
-
-	// Create directory structure if it doesn't exist
-	if err := os.MkdirAll(destDir, 0755); err != nil {
-		return fmt.Errorf("failed to create directory %s: %v", destDir, err)
-	}
-
-	// Move the file
-	if err := os.Rename(sourcePath, finalPath); err != nil {
-		return err
-	}
-
-	if fileType == "video" {
-		fmt.Printf("✅ Video moved to: %s\n", finalPath)
-	} else {
-		fmt.Printf("✅ Photo moved to: %s\n", finalPath)
-	}
-	return nil
-}
-
 // collectSampleFilesForFallback collects sample files for fallback dry-run1 mode
 func collectSampleFilesForFallback(sourcePath string, sampleSize int) ([]string, error) {
 	// Map to track files by subdirectory and type
@@ -349,97 +271,16 @@ func promptForFallbackLocation(filePath string) (country, city string, shouldSki
 }
 
 // moveFileToFallbackLocationWithLocation moves file to fallback location using provided country/city
-func moveFileToFallbackLocationWithLocation(sourcePath, destBasePath, location, date, country, city string, dryRun bool) error {
-	// Parse date string to time.Time for generateFilenameWithTime
+// and returns its final path
+func moveFileToFallbackLocationWithLocation(sourcePath, destBasePath, date, country, city, reason string, dryRun bool) (string, error) {
+	// Parse date string to time.Time for the path template
 	parsedDate, err := time.Parse("2006-01-02", date)
 	if err != nil {
-		return fmt.Errorf("failed to parse date %s: %v", date, err)
+		return "", fmt.Errorf("failed to parse date %s: %v", date, err)
 	}
 
 	// Generate new filename with location information preserving existing hour+minute if present
-	newFilename := generateFilenameWithTime(sourcePath, parsedDate, city)
-
-	var destDir string
-	var fileType string
-
-	// Check if this is a video file
-	if isVideoFile(sourcePath) {
-		// For video files, place in VIDEO-FILES/YYYY/Country/City structure
-		fileType = "video"
-		dateParts := strings.Split(date, "-")
-		if len(dateParts) >= 1 {
-			year := dateParts[0]
-			destDir = filepath.Join(destBasePath, "VIDEO-FILES", year, country, city)
-		} else {
-			destDir = filepath.Join(destBasePath, "VIDEO-FILES", location)
-		}
-		if dryRun {
-			fmt.Printf("🎥 [DRY RUN] Processing video file: %s\n", filepath.Base(sourcePath))
-		} else {
-			fmt.Printf("🎥 Processing video file: %s\n", filepath.Base(sourcePath))
-		}
-	} else {
-		// For photo files, use the YYYY/Country/City structure
-		fileType = "photo"
-		dateParts := strings.Split(date, "-")
-		if len(dateParts) >= 1 {
-			year := dateParts[0]
-			destDir = filepath.Join(destBasePath, year, country, city)
-		} else {
-			destDir = filepath.Join(destBasePath, location)
-		}
-		if dryRun {
-			fmt.Printf("📷 [DRY RUN] Processing photo file: %s\n", filepath.Base(sourcePath))
-		} else {
-			fmt.Printf("📷 Processing photo file: %s\n", filepath.Base(sourcePath))
-		}
-	}
-
-	destPath := filepath.Join(destDir, newFilename)
-
-	// Handle duplicates
-	finalPath := destPath
-	ext := filepath.Ext(sourcePath)
-	counter := 1
-	for {
-		if _, err := os.Stat(finalPath); os.IsNotExist(err) {
-			break
-		}
-
-		base := strings.TrimSuffix(newFilename, ext)
-		duplicateFilename := fmt.Sprintf("%s-%d%s", base, counter, ext)
-		finalPath = filepath.Join(destDir, duplicateFilename)
-		counter++
-
-		if counter > 1000 {
-			return fmt.Errorf("too many duplicate filenames")
-		}
-	}
-
-	if dryRun {
-		// Dry run mode - just show what would happen
-		if fileType == "video" {
-			fmt.Printf("✅ [DRY RUN] Video would be moved to: %s\n", finalPath)
-		} else {
-			fmt.Printf("✅ [DRY RUN] Photo would be moved to: %s\n", finalPath)
-		}
-		return nil
-	}
-
-	// Create directory structure if it doesn't exist
-	if err := os.MkdirAll(destDir, 0755); err != nil {
-		return fmt.Errorf("failed to create directory %s: %v", destDir, err)
-	}
-
-	// Move the file
-	if err := os.Rename(sourcePath, finalPath); err != nil {
-		return err
-	}
-
-	if fileType == "video" {
-		fmt.Printf("✅ Video moved to: %s\n", finalPath)
-	} else {
-		fmt.Printf("✅ Photo moved to: %s\n", finalPath)
-	}
-	return nil
+	fields := newLayoutFields(sourcePath, parsedDate, country, city)
+	fields.Reason = reason
+	return moveIntoLayout(sourcePath, destBasePath, fields, dryRun)
 }
\ No newline at end of file
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// gazetteerDefaultMaxKm is how far the nearest gazetteer place may be before a
+// coordinate is treated as not covered
+const gazetteerDefaultMaxKm = 25.0
+
+// gazetteerPlace is one line of a gazetteer file
+type gazetteerPlace struct {
+	Latitude  float64
+	Longitude float64
+	Result    GeocodeResult
+}
+
+// gazetteerGeocoder answers with the nearest place listed in a local file.
+// The file is tab separated: latitude, longitude, city, country, county, state, country code.
+// Only the first four columns are required; lines starting with # are comments
+type gazetteerGeocoder struct {
+	path     string
+	maxKm    float64
+	places   []gazetteerPlace
+	loadErr  error
+	loadOnce sync.Once
+}
+
+// newGazetteerGeocoder creates a gazetteer provider; the file is read on first use
+func newGazetteerGeocoder(path string, maxKm float64) *gazetteerGeocoder {
+	if maxKm <= 0 {
+		maxKm = gazetteerDefaultMaxKm
+	}
+	return &gazetteerGeocoder{path: path, maxKm: maxKm}
+}
+
+// Name identifies the provider
+func (g *gazetteerGeocoder) Name() string {
+	return geocoderGazetteer
+}
+
+// ReverseGeocode returns the nearest place within the distance limit
+func (g *gazetteerGeocoder) ReverseGeocode(lat, lon float64) (*GeocodeResult, error) {
+	return g.lookup(lat, lon, nil)
+}
+
+// ReverseGeocodeInCountry returns the nearest place in the country within the distance limit
+func (g *gazetteerGeocoder) ReverseGeocodeInCountry(lat, lon float64, country *countryBoundary) (*GeocodeResult, error) {
+	return g.lookup(lat, lon, country)
+}
+
+// lookup finds the nearest place, limited to country unless it is nil
+func (g *gazetteerGeocoder) lookup(lat, lon float64, country *countryBoundary) (*GeocodeResult, error) {
+	g.loadOnce.Do(func() {
+		g.places, g.loadErr = loadGazetteer(g.path)
+		if g.loadErr == nil {
+			fmt.Printf("🗺️  Loaded %d places from gazetteer %s\n", len(g.places), g.path)
+		}
+	})
+	if g.loadErr != nil {
+		return nil, g.loadErr
+	}
+
+	var nearest *gazetteerPlace
+	nearestKm := g.maxKm
+	for i := range g.places {
+		place := &g.places[i]
+		if country != nil && !resultInCountry(&place.Result, country) {
+			continue
+		}
+		if distance := haversineKm(lat, lon, place.Latitude, place.Longitude); distance <= nearestKm {
+			nearest = place
+			nearestKm = distance
+		}
+	}
+	if nearest == nil {
+		return nil, errNoGeocodeMatch
+	}
+
+	result := nearest.Result
+	return &result, nil
+}
+
+// loadGazetteer reads a gazetteer file
+func loadGazetteer(path string) ([]gazetteerPlace, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open gazetteer: %v", err)
+	}
+	defer file.Close()
+
+	var places []gazetteerPlace
+	scanner := bufio.NewScanner(file)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) < 4 {
+			return nil, fmt.Errorf("gazetteer %s line %d: expected at least 4 tab-separated fields", path, lineNumber)
+		}
+		lat, latErr := strconv.ParseFloat(strings.TrimSpace(fields[0]), 64)
+		lon, lonErr := strconv.ParseFloat(strings.TrimSpace(fields[1]), 64)
+		if latErr != nil || lonErr != nil {
+			return nil, fmt.Errorf("gazetteer %s line %d: invalid coordinates", path, lineNumber)
+		}
+
+		// Pad the optional columns
+		for len(fields) < 7 {
+			fields = append(fields, "")
+		}
+		places = append(places, gazetteerPlace{
+			Latitude:  lat,
+			Longitude: lon,
+			Result: GeocodeResult{
+				City:        strings.TrimSpace(fields[2]),
+				Country:     strings.TrimSpace(fields[3]),
+				County:      strings.TrimSpace(fields[4]),
+				State:       strings.TrimSpace(fields[5]),
+				CountryCode: strings.ToLower(strings.TrimSpace(fields[6])),
+				Source:      geocoderGazetteer,
+			},
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read gazetteer: %v", err)
+	}
+
+	return places, nil
+}
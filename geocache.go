@@ -0,0 +1,332 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// geocodeCacheDefaultPrecisionM is the default size of a cache cell in metres
+const geocodeCacheDefaultPrecisionM = 100.0
+
+// geocodeCacheDefaultTTLDays is how long a cached answer is used by default
+const geocodeCacheDefaultTTLDays = 180
+
+// geocodeCacheFlushInterval is how often new answers are written to the cache file during a run;
+// the rest are written when the run ends
+const geocodeCacheFlushInterval = 30 * time.Second
+
+// metresPerDegree is the length of one degree of latitude
+const metresPerDegree = 111320.0
+
+// geocodeCacheEntry is a cached answer; NoMatch records places no provider knows (open sea)
+type geocodeCacheEntry struct {
+	Result   GeocodeResult `json:"result"`
+	NoMatch  bool          `json:"no_match,omitempty"`
+	CachedAt time.Time     `json:"cached_at"`
+}
+
+// geocodeCall is a lookup in progress that other workers asking about the same cell wait for
+type geocodeCall struct {
+	wg     sync.WaitGroup
+	result *GeocodeResult
+	err    error
+}
+
+// cachedGeocoder remembers answers per grid cell across runs and lets concurrent
+// lookups of one cell share a single request
+type cachedGeocoder struct {
+	inner      Geocoder
+	path       string
+	precisionM float64
+	ttl        time.Duration
+
+	mu       sync.Mutex
+	entries  map[string]geocodeCacheEntry
+	inflight map[string]*geocodeCall
+	dirty    bool      // Entries not written to the file yet
+	flushed  time.Time // When the file was last written, or a write was last started
+
+	writeMu sync.Mutex // Keeps cache file writes in the order their snapshots were taken
+
+	hits      int64
+	misses    int64
+	coalesced int64
+}
+
+// getGeocodeCachePath returns the cache file, stored next to the GPS cache database
+func getGeocodeCachePath() string {
+	return filepath.Join(filepath.Dir(getDefaultCachePath()), "photo-meta-geocache.json")
+}
+
+// newCachedGeocoder wraps inner with the persistent cache at path
+func newCachedGeocoder(inner Geocoder, path string, precisionM float64, ttlDays int) *cachedGeocoder {
+	if precisionM <= 0 {
+		precisionM = geocodeCacheDefaultPrecisionM
+	}
+	if ttlDays <= 0 {
+		ttlDays = geocodeCacheDefaultTTLDays
+	}
+
+	cache := &cachedGeocoder{
+		inner:      inner,
+		path:       path,
+		precisionM: precisionM,
+		ttl:        time.Duration(ttlDays) * 24 * time.Hour,
+		entries:    make(map[string]geocodeCacheEntry),
+		inflight:   make(map[string]*geocodeCall),
+		flushed:    time.Now(),
+	}
+	if err := cache.load(); err != nil {
+		fmt.Printf("⚠️  Warning: Could not load geocode cache %s: %v\n", path, err)
+	}
+	return cache
+}
+
+// Name identifies the wrapped provider
+func (c *cachedGeocoder) Name() string {
+	return c.inner.Name() + " (cached)"
+}
+
+// cellKey rounds the coordinates to the cache grid. The precision is part of the key,
+// so changing it never returns answers for the old cell size
+func (c *cachedGeocoder) cellKey(lat, lon float64) string {
+	step := c.precisionM / metresPerDegree
+	return fmt.Sprintf("%g:%d:%d", c.precisionM, int64(math.Floor(lat/step)), int64(math.Floor(lon/step)))
+}
+
+// ReverseGeocode answers from the cache, or asks the wrapped provider once per cell
+func (c *cachedGeocoder) ReverseGeocode(lat, lon float64) (*GeocodeResult, error) {
+	key := c.cellKey(lat, lon)
+
+	c.mu.Lock()
+	if entry, exists := c.entries[key]; exists && time.Since(entry.CachedAt) < c.ttl {
+		c.mu.Unlock()
+		atomic.AddInt64(&c.hits, 1)
+		if entry.NoMatch {
+			return nil, errNoGeocodeMatch
+		}
+		result := entry.Result
+		return &result, nil
+	}
+	if call, exists := c.inflight[key]; exists {
+		// Another worker is already looking this cell up
+		c.mu.Unlock()
+		atomic.AddInt64(&c.coalesced, 1)
+		call.wg.Wait()
+		return copyGeocodeResult(call.result), call.err
+	}
+	call := &geocodeCall{}
+	call.wg.Add(1)
+	c.inflight[key] = call
+	c.mu.Unlock()
+
+	atomic.AddInt64(&c.misses, 1)
+	call.result, call.err = c.inner.ReverseGeocode(lat, lon)
+
+	c.mu.Lock()
+	delete(c.inflight, key)
+	// Provider failures and answers given during an outage are not cached, so the cell is retried next time
+	flushDue := false
+	degraded := errors.Is(call.err, errGeocoderUnavailable) || (call.result != nil && call.result.Degraded)
+	if !degraded && (call.err == nil || errors.Is(call.err, errNoGeocodeMatch)) {
+		entry := geocodeCacheEntry{NoMatch: call.err != nil, CachedAt: time.Now()}
+		if call.result != nil {
+			entry.Result = *call.result
+		}
+		c.entries[key] = entry
+		c.dirty = true
+		if time.Since(c.flushed) >= geocodeCacheFlushInterval {
+			flushDue = true
+			c.flushed = time.Now() // One worker writes; the others carry on
+		}
+	}
+	c.mu.Unlock()
+	call.wg.Done()
+
+	if flushDue {
+		if err := c.flush(); err != nil {
+			fmt.Printf("⚠️  Warning: Could not save geocode cache: %v\n", err)
+		}
+	}
+
+	return copyGeocodeResult(call.result), call.err
+}
+
+// copyGeocodeResult gives each caller its own result
+func copyGeocodeResult(result *GeocodeResult) *GeocodeResult {
+	if result == nil {
+		return nil
+	}
+	copied := *result
+	return &copied
+}
+
+// load reads the cache file, dropping expired entries
+func (c *cachedGeocoder) load() error {
+	data, err := os.ReadFile(c.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if len(data) == 0 {
+		return nil
+	}
+
+	var entries map[string]geocodeCacheEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, entry := range entries {
+		if time.Since(entry.CachedAt) < c.ttl {
+			c.entries[key] = entry
+		}
+	}
+	return nil
+}
+
+// flush writes the cache file when it has new answers, without holding up lookups while it writes
+func (c *cachedGeocoder) flush() error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	c.mu.Lock()
+	if !c.dirty {
+		c.mu.Unlock()
+		return nil
+	}
+	data, err := json.Marshal(c.entries)
+	c.dirty, c.flushed = false, time.Now()
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return c.write(data)
+}
+
+// write replaces the cache file with data
+func (c *cachedGeocoder) write(data []byte) error {
+	tempPath := c.path + ".tmp"
+	if err := os.WriteFile(tempPath, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tempPath, c.path)
+}
+
+// Clear removes every cached answer
+func (c *cachedGeocoder) Clear() error {
+	c.mu.Lock()
+	c.entries = make(map[string]geocodeCacheEntry)
+	c.dirty = true
+	c.mu.Unlock()
+	return c.flush()
+}
+
+// Invalidate removes the cached answer for the cell containing the coordinates
+func (c *cachedGeocoder) Invalidate(lat, lon float64) (bool, error) {
+	c.mu.Lock()
+	key := c.cellKey(lat, lon)
+	if _, exists := c.entries[key]; !exists {
+		c.mu.Unlock()
+		return false, nil
+	}
+	delete(c.entries, key)
+	c.dirty = true
+	c.mu.Unlock()
+	return true, c.flush()
+}
+
+// Stats returns the lookups of this run and the number of cached cells
+func (c *cachedGeocoder) Stats() (hits, misses, coalesced int64, cells int) {
+	c.mu.Lock()
+	cells = len(c.entries)
+	c.mu.Unlock()
+	return atomic.LoadInt64(&c.hits), atomic.LoadInt64(&c.misses), atomic.LoadInt64(&c.coalesced), cells
+}
+
+// flushGeocodeCache writes the answers this run added to the cache file
+func flushGeocodeCache() {
+	// Only when this run built the geocoder
+	cache, ok := geocoder.(*cachedGeocoder)
+	if !ok {
+		return
+	}
+	if err := cache.flush(); err != nil {
+		fmt.Printf("⚠️  Warning: Could not save geocode cache: %v\n", err)
+	}
+}
+
+// printGeocodeCacheStats adds the geocode cache results of this run to a processing summary
+// and saves the answers it added
+func printGeocodeCacheStats() {
+	flushGeocodeCache()
+
+	// Only report when this run built the geocoder
+	cache, ok := geocoder.(*cachedGeocoder)
+	if !ok {
+		return
+	}
+
+	hits, misses, coalesced, _ := cache.Stats()
+	lookups := hits + misses + coalesced
+	if lookups == 0 {
+		return
+	}
+	fmt.Printf("🗺️  Geocode cache: %d hits, %d misses, %d shared lookups (%.0f%% answered without a new lookup)\n",
+		hits, misses, coalesced, float64(hits+coalesced)/float64(lookups)*100)
+}
+
+// runGeocacheCommand shows or invalidates the persistent geocode cache
+func runGeocacheCommand(args []string) error {
+	cache, ok := GetGeocoder().(*cachedGeocoder)
+	if !ok {
+		return fmt.Errorf("the geocode cache is disabled (disable_geocode_cache)")
+	}
+
+	action := "stats"
+	if len(args) > 0 {
+		action = args[0]
+	}
+
+	switch action {
+	case "stats":
+		_, _, _, cells := cache.Stats()
+		fmt.Printf("🗺️  Geocode cache: %s\n", cache.path)
+		fmt.Printf("   Cached cells: %d (cell size %gm, entries kept %d days)\n", cells, cache.precisionM, int(cache.ttl.Hours()/24))
+	case "clear":
+		if len(args) == 3 {
+			var lat, lon float64
+			if _, err := fmt.Sscanf(args[1]+" "+args[2], "%g %g", &lat, &lon); err != nil {
+				return fmt.Errorf("invalid coordinates: %s %s", args[1], args[2])
+			}
+			removed, err := cache.Invalidate(lat, lon)
+			if err != nil {
+				return err
+			}
+			if removed {
+				fmt.Printf("🗑️  Removed cached location for %.6f, %.6f\n", lat, lon)
+			} else {
+				fmt.Printf("ℹ️  No cached location for %.6f, %.6f\n", lat, lon)
+			}
+			return nil
+		}
+		if err := cache.Clear(); err != nil {
+			return err
+		}
+		fmt.Println("✅ Geocode cache cleared")
+	default:
+		return fmt.Errorf("unknown geocache action '%s' (use stats or clear [LAT LON])", action)
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// errNoGeocodeMatch is returned by a Geocoder that has no answer for a coordinate,
+// so a chain moves on to its next provider
+var errNoGeocodeMatch = errors.New("no geocoding match")
+
+// Geocoder provider names (config geocoders)
+const (
+	geocoderOffline   = "offline"   // Built-in bounding-box table
+	geocoderGazetteer = "gazetteer" // Local place file (config gazetteer_file)
+	geocoderGeoNames  = "geonames"  // GeoNames dump with a k-d tree index (config geonames_file)
+	geocoderNominatim = "nominatim" // Nominatim server (config nominatim_*)
+)
+
+// defaultGeocoders is the provider order used when the config does not set one
+var defaultGeocoders = []string{geocoderOffline, geocoderNominatim}
+
+// GeocodeResult is a reverse-geocoded place
+type GeocodeResult struct {
+	City        string // City, town or village
+	County      string
+	State       string
+	Country     string
+	CountryCode string // ISO 3166-1 alpha-2, lower case
+	Source      string // Provider that answered
+
+	// Degraded is set when a provider ahead of the one that answered was unreachable, so a
+	// better answer may exist; such answers are not cached
+	Degraded bool `json:"-"`
+}
+
+// Geocoder turns GPS coordinates into a place
+type Geocoder interface {
+	// Name identifies the provider in messages
+	Name() string
+
+	// ReverseGeocode returns the place at the coordinates, or errNoGeocodeMatch
+	ReverseGeocode(lat, lon float64) (*GeocodeResult, error)
+}
+
+// countryScopedGeocoder is implemented by providers that can limit a lookup to one country,
+// so a point near a border is named after a place on the correct side of it
+type countryScopedGeocoder interface {
+	ReverseGeocodeInCountry(lat, lon float64, country *countryBoundary) (*GeocodeResult, error)
+}
+
+// chainGeocoder asks each provider in turn until one has an answer. With country
+// boundaries loaded, the country is settled first and answers from other countries are rejected
+type chainGeocoder struct {
+	providers  []Geocoder
+	boundaries *countryBoundaries
+}
+
+// Name lists the chained providers
+func (c *chainGeocoder) Name() string {
+	names := make([]string, len(c.providers))
+	for i, provider := range c.providers {
+		names[i] = provider.Name()
+	}
+	return strings.Join(names, " → ")
+}
+
+// ReverseGeocode returns the first provider's answer. Provider errors are passed over
+// so a network failure still lets a later offline provider answer. A provider that cannot be
+// reached counts as having no answer, so an outage still gives the country-only answer
+func (c *chainGeocoder) ReverseGeocode(lat, lon float64) (*GeocodeResult, error) {
+	country := c.boundaries.countryAt(lat, lon)
+
+	var lastErr error
+	degraded := false
+	for _, provider := range c.providers {
+		var result *GeocodeResult
+		var err error
+		if scoped, ok := provider.(countryScopedGeocoder); ok && country != nil {
+			result, err = scoped.ReverseGeocodeInCountry(lat, lon, country)
+		} else {
+			result, err = provider.ReverseGeocode(lat, lon)
+			if err == nil && country != nil && !resultInCountry(result, country) {
+				err = errNoGeocodeMatch
+			}
+		}
+
+		if err == nil {
+			result.Degraded = degraded
+			return result, nil
+		}
+		switch {
+		case isGeocoderOutage(err):
+			degraded = true
+		case !errors.Is(err, errNoGeocodeMatch):
+			lastErr = err
+		}
+	}
+
+	if lastErr != nil {
+		return nil, lastErr
+	}
+	if country != nil {
+		// No town known on this side of the border - the country alone is still right
+		return &GeocodeResult{Country: country.displayName(), CountryCode: strings.ToLower(country.Code), Source: "boundaries", Degraded: degraded}, nil
+	}
+	if degraded {
+		return nil, fmt.Errorf("%w: %w", errNoGeocodeMatch, errGeocoderUnavailable)
+	}
+	return nil, errNoGeocodeMatch
+}
+
+// isGeocoderOutage reports whether err means a provider could not be reached or stayed
+// overloaded through its retries, rather than that it failed on the coordinate
+func isGeocoderOutage(err error) bool {
+	var statusErr *retryableStatusError
+	return errors.Is(err, errGeocoderUnavailable) || isNetworkError(err) || errors.As(err, &statusErr)
+}
+
+var (
+	geocoder     Geocoder
+	geocoderOnce sync.Once
+)
+
+// GetGeocoder builds the provider chain from the config once
+func GetGeocoder() Geocoder {
+	geocoderOnce.Do(func() {
+		config := GetAppConfig()
+
+		names := config.Geocoders
+		if len(names) == 0 {
+			names = defaultGeocoders
+			if config.GeoNamesFile != "" {
+				// A configured GeoNames dump answers before the network is tried
+				names = []string{geocoderOffline, geocoderGeoNames, geocoderNominatim}
+			}
+		}
+
+		chain := &chainGeocoder{boundaries: GetCountryBoundaries()}
+		for _, name := range names {
+			provider, err := newGeocoder(name, config)
+			if err != nil {
+				fmt.Printf("⚠️  Warning: Skipping geocoder %s: %v\n", name, err)
+				continue
+			}
+			chain.providers = append(chain.providers, provider)
+		}
+		geocoder = chain
+
+		if !config.DisableGeocodeCache {
+			geocoder = newCachedGeocoder(chain, getGeocodeCachePath(), config.GeocodeCachePrecisionM, config.GeocodeCacheTTLDays)
+		}
+	})
+
+	return geocoder
+}
+
+// newGeocoder creates a single provider by name
+func newGeocoder(name string, config *AppConfig) (Geocoder, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case geocoderOffline:
+		return &offlineTableGeocoder{}, nil
+	case geocoderGazetteer:
+		if config.GazetteerFile == "" {
+			return nil, fmt.Errorf("gazetteer_file is not set")
+		}
+		return newGazetteerGeocoder(config.GazetteerFile, config.GazetteerMaxKm), nil
+	case geocoderGeoNames:
+		if config.GeoNamesFile == "" {
+			return nil, fmt.Errorf("geonames_file is not set")
+		}
+		return newGeoNamesGeocoder(config.GeoNamesFile, config.GeoNamesMaxKm), nil
+	case geocoderNominatim:
+		return newNominatimGeocoder(config), nil
+	}
+	return nil, fmt.Errorf("unknown provider (use %s, %s, %s or %s)", geocoderOffline, geocoderGazetteer, geocoderGeoNames, geocoderNominatim)
+}
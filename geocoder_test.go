@@ -0,0 +1,125 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// stubGeocoder answers every lookup with the same result or error
+type stubGeocoder struct {
+	result *GeocodeResult
+	err    error
+	calls  int
+}
+
+func (s *stubGeocoder) Name() string { return "stub" }
+
+func (s *stubGeocoder) ReverseGeocode(lat, lon float64) (*GeocodeResult, error) {
+	s.calls++
+	return copyGeocodeResult(s.result), s.err
+}
+
+func TestChainGeocoderOutage(t *testing.T) {
+	boundaries, err := loadBuiltinCountryBoundaries()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		err         error
+		lat, lon    float64
+		wantCountry string
+		wantErr     error
+	}{
+		{"breaker open in France", fmt.Errorf("nominatim: %w", errGeocoderUnavailable), 46.5, 2.5, "France", nil},
+		{"network error in France", &networkError{err: errors.New("connection refused")}, 46.5, 2.5, "France", nil},
+		{"server overloaded in France", &retryableStatusError{status: 503}, 46.5, 2.5, "France", nil},
+		{"breaker open at sea", fmt.Errorf("nominatim: %w", errGeocoderUnavailable), 30, -40, "", errNoGeocodeMatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chain := &chainGeocoder{providers: []Geocoder{&stubGeocoder{err: tt.err}}, boundaries: boundaries}
+			result, err := chain.ReverseGeocode(tt.lat, tt.lon)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) || !errors.Is(err, errGeocoderUnavailable) {
+					t.Fatalf("error = %v, want %v marked unavailable", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ReverseGeocode failed: %v", err)
+			}
+			if result.Country != tt.wantCountry || !result.Degraded {
+				t.Errorf("result = %+v, want a degraded answer for %s", result, tt.wantCountry)
+			}
+		})
+	}
+}
+
+func TestCachedGeocoderSkipsDegradedAnswers(t *testing.T) {
+	boundaries, err := loadBuiltinCountryBoundaries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider := &stubGeocoder{err: fmt.Errorf("nominatim: %w", errGeocoderUnavailable)}
+	cache := newCachedGeocoder(&chainGeocoder{providers: []Geocoder{provider}, boundaries: boundaries},
+		filepath.Join(t.TempDir(), "geocache.json"), 0, 0)
+
+	if _, err := cache.ReverseGeocode(46.5, 2.5); err != nil {
+		t.Fatalf("ReverseGeocode failed: %v", err)
+	}
+	provider.err, provider.result = nil, &GeocodeResult{City: "Bourges", Country: "France", CountryCode: "fr"}
+	result, err := cache.ReverseGeocode(46.5, 2.5)
+	if err != nil || result.City != "Bourges" {
+		t.Errorf("after the outage got %+v, %v, want Bourges from the provider", result, err)
+	}
+	if provider.calls != 2 {
+		t.Errorf("provider asked %d times, want 2", provider.calls)
+	}
+}
+
+func TestCachedGeocoderConcurrentWrites(t *testing.T) {
+	provider := &stubGeocoder{result: &GeocodeResult{City: "Paris", Country: "France", CountryCode: "fr"}}
+	path := filepath.Join(t.TempDir(), "geocache.json")
+	cache := newCachedGeocoder(provider, path, 0, 0)
+	if _, err := cache.ReverseGeocode(48.85, 2.35); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 200; i++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			cache.mu.Lock()
+			cache.dirty = true
+			cache.mu.Unlock()
+			if err := cache.flush(); err != nil {
+				t.Errorf("flush failed: %v", err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if _, err := cache.Invalidate(48.85, 2.35); err != nil {
+				t.Errorf("Invalidate failed: %v", err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if err := cache.Clear(); err != nil {
+				t.Errorf("Clear failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	reloaded := newCachedGeocoder(provider, path, 0, 0)
+	if _, _, _, cells := reloaded.Stats(); cells != 0 {
+		t.Errorf("cache file holds %d cells after Clear, want 0", cells)
+	}
+}
@@ -2,71 +2,119 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"net/http"
+	neturl "net/url"
 	"strings"
-	"time"
 )
 
 // NominatimResponse represents the response from OpenStreetMap's Nominatim API
 type NominatimResponse struct {
 	DisplayName string `json:"display_name"`
 	Address     struct {
-		City    string `json:"city"`
-		Town    string `json:"town"`
-		Village string `json:"village"`
-		County  string `json:"county"`
-		State   string `json:"state"`
-		Country string `json:"country"`
+		City        string `json:"city"`
+		Town        string `json:"town"`
+		Village     string `json:"village"`
+		County      string `json:"county"`
+		State       string `json:"state"`
+		Country     string `json:"country"`
+		CountryCode string `json:"country_code"`
 	} `json:"address"`
 }
 
-// getLocationFromCoordinates converts GPS coordinates to location using reverse geocoding
+// getLocationFromCoordinates converts GPS coordinates to location using the places file,
+// then the configured geocoders
 func getLocationFromCoordinates(lat, lon float64) (string, error) {
-	// Check offline mapping first (for common locations)
-	if offlineLocation := tryOfflineMapping(lat, lon); offlineLocation != "" {
-		return offlineLocation, nil
+	if place := GetPlaces().match(lat, lon); place != nil {
+		return place.location(), nil
 	}
 	
-	// Use OpenStreetMap Nominatim API for reverse geocoding
-	url := fmt.Sprintf("https://nominatim.openstreetmap.org/reverse?lat=%f&lon=%f&format=json&addressdetails=1&accept-language=en", lat, lon)
+	result, err := GetGeocoder().ReverseGeocode(lat, lon)
+	if errors.Is(err, errNoGeocodeMatch) {
+		return "unknown-location", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	
+	return granularLocation(*result, smallPlaces.isSmall), nil
+}
+
+// ReverseGeocode asks the Nominatim server for the address at the coordinates
+func (n *nominatimGeocoder) ReverseGeocode(lat, lon float64) (*GeocodeResult, error) {
+	url := fmt.Sprintf("%s/reverse?lat=%f&lon=%f&format=json&addressdetails=1&accept-language=en", n.baseURL, lat, lon)
+	if n.email != "" {
+		url += "&email=" + neturl.QueryEscape(n.email)
+	}
 	
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Get(url)
+	body, err := n.fetch(url)
 	if err != nil {
-		return "", fmt.Errorf("geocoding request failed: %v", err)
+		return nil, err
 	}
-	defer resp.Body.Close()
 	
-	if resp.StatusCode != 200 {
-		return "", fmt.Errorf("geocoding API returned status: %d", resp.StatusCode)
+	var response NominatimResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to parse geocoding response: %v", err)
 	}
 	
-	var result NominatimResponse
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return "", fmt.Errorf("failed to parse geocoding response: %v", err)
+	// Nominatim answers {"error": "Unable to geocode"} for open sea
+	if response.Address.Country == "" && response.Address.State == "" && response.Address.County == "" {
+		return nil, errNoGeocodeMatch
+	}
+	
+	// Use the most specific settlement name available
+	city := response.Address.City
+	if city == "" {
+		city = response.Address.Town
+	}
+	if city == "" {
+		city = response.Address.Village
 	}
 	
-	return formatLocationName(result), nil
+	return &GeocodeResult{
+		City:        city,
+		County:      response.Address.County,
+		State:       response.Address.State,
+		Country:     response.Address.Country,
+		CountryCode: strings.ToLower(response.Address.CountryCode),
+		Source:      geocoderNominatim,
+	}, nil
 }
 
-// formatLocationName extracts city and country from geocoding result
-func formatLocationName(result NominatimResponse) string {
-	// Extract city name in order of preference
-	cityName := ""
-	if result.Address.City != "" {
-		cityName = result.Address.City
-	} else if result.Address.Town != "" {
-		cityName = result.Address.Town
-	} else if result.Address.Village != "" {
-		cityName = result.Address.Village
-	} else if result.Address.County != "" {
-		cityName = result.Address.County
-	} else if result.Address.State != "" {
-		cityName = result.Address.State
+// ReverseGeocodeInCountry ignores the country: Nominatim matches addresses against
+// full-resolution boundaries, which are more exact than any simplified dataset
+func (n *nominatimGeocoder) ReverseGeocodeInCountry(lat, lon float64, country *countryBoundary) (*GeocodeResult, error) {
+	return n.ReverseGeocode(lat, lon)
+}
+
+// offlineTableGeocoder answers from the built-in bounding boxes of common cities
+type offlineTableGeocoder struct{}
+
+// Name identifies the provider
+func (o *offlineTableGeocoder) Name() string {
+	return geocoderOffline
+}
+
+// ReverseGeocode looks the coordinates up in tryOfflineMapping's table
+func (o *offlineTableGeocoder) ReverseGeocode(lat, lon float64) (*GeocodeResult, error) {
+	location := tryOfflineMapping(lat, lon)
+	if location == "" {
+		return nil, errNoGeocodeMatch
 	}
 	
-	countryName := result.Address.Country
+	country, city, err := parseLocation(location)
+	if err != nil {
+		return nil, errNoGeocodeMatch
+	}
+	return &GeocodeResult{City: city, Country: country, Source: geocoderOffline}, nil
+}
+
+// formatLocationName extracts city and country from geocoding result, naming the
+// city folder after the level given by granularity
+func formatLocationName(result GeocodeResult, granularity string) string {
+	cityName := placeName(result, granularity)
+	
+	countryName := result.Country
 	
 	if cityName == "" && countryName == "" {
 		return "unknown-location"
@@ -0,0 +1,513 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// geonamesDefaultMaxKm is how far the nearest populated place may be before a
+// coordinate is treated as not covered
+const geonamesDefaultMaxKm = 50.0
+
+// GeoNames files read from the directory of geonames_file when present
+const (
+	geonamesAdmin1File = "admin1CodesASCII.txt"
+	geonamesAdmin2File = "admin2Codes.txt"
+)
+
+// geonamesIndexSuffix is appended to geonames_file to name the binary index cache
+const geonamesIndexSuffix = ".pmidx"
+
+// geonamesIndexVersion changes whenever the index layout does, invalidating old caches
+const geonamesIndexVersion uint32 = 1
+
+// geonamesIndexMagic starts every index cache file
+var geonamesIndexMagic = [4]byte{'P', 'M', 'G', 'N'}
+
+// geonamesSkippedFeatures are populated-place codes that are not settlements of their own:
+// city sections (PPLX) would name a neighbourhood instead of the city, the rest no longer exist
+var geonamesSkippedFeatures = map[string]bool{
+	"PPLX": true, "PPLH": true, "PPLQ": true, "PPLW": true, "PPLCH": true,
+}
+
+// geonamesPlace is one populated place in the index; names are indexes into geonamesIndex.names
+type geonamesPlace struct {
+	Latitude    float32
+	Longitude   float32
+	City        uint32
+	County      uint32
+	State       uint32
+	CountryCode [2]byte
+}
+
+// geonamesIndex is a k-d tree of populated places over unit-sphere vectors. The tree is
+// implicit: each range's middle element is its node, split on axis depth%3
+type geonamesIndex struct {
+	names  []string
+	places []geonamesPlace
+	points [][3]float64
+}
+
+// geonamesGeocoder answers with the nearest populated place in a GeoNames dump
+// (cities1000.txt, cities500.txt or allCountries.txt) without any network access
+type geonamesGeocoder struct {
+	path     string
+	maxKm    float64
+	index    *geonamesIndex
+	loadErr  error
+	loadOnce sync.Once
+}
+
+// newGeoNamesGeocoder creates a GeoNames provider; the index is loaded on first use
+func newGeoNamesGeocoder(path string, maxKm float64) *geonamesGeocoder {
+	if maxKm <= 0 {
+		maxKm = geonamesDefaultMaxKm
+	}
+	return &geonamesGeocoder{path: path, maxKm: maxKm}
+}
+
+// Name identifies the provider
+func (g *geonamesGeocoder) Name() string {
+	return geocoderGeoNames
+}
+
+// ReverseGeocode returns the nearest populated place within the distance limit
+func (g *geonamesGeocoder) ReverseGeocode(lat, lon float64) (*GeocodeResult, error) {
+	return g.lookup(lat, lon, "")
+}
+
+// ReverseGeocodeInCountry returns the nearest populated place in the country within the distance limit
+func (g *geonamesGeocoder) ReverseGeocodeInCountry(lat, lon float64, country *countryBoundary) (*GeocodeResult, error) {
+	return g.lookup(lat, lon, country.Code)
+}
+
+// lookup finds the nearest place, limited to countryCode unless it is empty
+func (g *geonamesGeocoder) lookup(lat, lon float64, countryCode string) (*GeocodeResult, error) {
+	g.loadOnce.Do(func() {
+		g.index, g.loadErr = loadGeoNamesIndex(g.path)
+	})
+	if g.loadErr != nil {
+		return nil, g.loadErr
+	}
+
+	place, found := g.index.nearest(lat, lon, g.maxKm, countryCode)
+	if !found {
+		return nil, errNoGeocodeMatch
+	}
+
+	code := string(place.CountryCode[:])
+	country := countryNameForCode(code)
+	if country == "" {
+		country = code
+	}
+
+	return &GeocodeResult{
+		City:        g.index.names[place.City],
+		County:      g.index.names[place.County],
+		State:       g.index.names[place.State],
+		Country:     country,
+		CountryCode: strings.ToLower(code),
+		Source:      geocoderGeoNames,
+	}, nil
+}
+
+// sphereVector converts coordinates to a point on the unit sphere, so straight-line
+// distance orders places the same way as great-circle distance, across the date line too
+func sphereVector(lat, lon float64) [3]float64 {
+	latRad := lat * math.Pi / 180
+	lonRad := lon * math.Pi / 180
+	return [3]float64{
+		math.Cos(latRad) * math.Cos(lonRad),
+		math.Cos(latRad) * math.Sin(lonRad),
+		math.Sin(latRad),
+	}
+}
+
+// chordDistanceSquared is the squared straight-line distance between two unit-sphere points
+func chordDistanceSquared(a, b [3]float64) float64 {
+	dx, dy, dz := a[0]-b[0], a[1]-b[1], a[2]-b[2]
+	return dx*dx + dy*dy + dz*dz
+}
+
+// nearest finds the closest place to the coordinates within maxKm, in countryCode if it is set
+func (idx *geonamesIndex) nearest(lat, lon, maxKm float64, countryCode string) (geonamesPlace, bool) {
+	if len(idx.places) == 0 {
+		return geonamesPlace{}, false
+	}
+
+	// Convert the great-circle limit to a chord length on the unit sphere
+	chord := 2 * math.Sin(math.Min(maxKm/earthRadiusKm, math.Pi)/2)
+	best := -1
+	bestDistance := chord * chord
+
+	var country [2]byte
+	copy(country[:], strings.ToUpper(countryCode))
+
+	idx.search(sphereVector(lat, lon), country, 0, len(idx.points), 0, &best, &bestDistance)
+	if best < 0 {
+		return geonamesPlace{}, false
+	}
+	return idx.places[best], true
+}
+
+// search walks the implicit k-d tree range [lo, hi), skipping subtrees that cannot
+// hold anything closer than the best match so far. A zero country matches every place
+func (idx *geonamesIndex) search(target [3]float64, country [2]byte, lo, hi, depth int, best *int, bestDistance *float64) {
+	if lo >= hi {
+		return
+	}
+
+	mid := (lo + hi) / 2
+	inCountry := country == [2]byte{} || idx.places[mid].CountryCode == country
+	if distance := chordDistanceSquared(target, idx.points[mid]); inCountry && distance <= *bestDistance {
+		*best = mid
+		*bestDistance = distance
+	}
+
+	axis := depth % 3
+	diff := target[axis] - idx.points[mid][axis]
+	if diff < 0 {
+		idx.search(target, country, lo, mid, depth+1, best, bestDistance)
+		if diff*diff <= *bestDistance {
+			idx.search(target, country, mid+1, hi, depth+1, best, bestDistance)
+		}
+	} else {
+		idx.search(target, country, mid+1, hi, depth+1, best, bestDistance)
+		if diff*diff <= *bestDistance {
+			idx.search(target, country, lo, mid, depth+1, best, bestDistance)
+		}
+	}
+}
+
+// buildKDTree reorders places so that each range's middle element splits it on axis depth%3
+func (idx *geonamesIndex) buildKDTree() {
+	idx.buildPoints()
+	idx.buildRange(0, len(idx.places), 0)
+}
+
+// buildRange sorts [lo, hi) on the depth's axis and recurses into both halves
+func (idx *geonamesIndex) buildRange(lo, hi, depth int) {
+	if hi-lo <= 1 {
+		return
+	}
+
+	axis := depth % 3
+	sort.Sort(geonamesAxisSorter{idx: idx, lo: lo, hi: hi, axis: axis})
+
+	mid := (lo + hi) / 2
+	idx.buildRange(lo, mid, depth+1)
+	idx.buildRange(mid+1, hi, depth+1)
+}
+
+// geonamesAxisSorter sorts a range of places and their points together on one axis
+type geonamesAxisSorter struct {
+	idx          *geonamesIndex
+	lo, hi, axis int
+}
+
+func (s geonamesAxisSorter) Len() int { return s.hi - s.lo }
+
+func (s geonamesAxisSorter) Less(i, j int) bool {
+	return s.idx.points[s.lo+i][s.axis] < s.idx.points[s.lo+j][s.axis]
+}
+
+func (s geonamesAxisSorter) Swap(i, j int) {
+	i, j = s.lo+i, s.lo+j
+	s.idx.points[i], s.idx.points[j] = s.idx.points[j], s.idx.points[i]
+	s.idx.places[i], s.idx.places[j] = s.idx.places[j], s.idx.places[i]
+}
+
+// loadGeoNamesIndex reads the binary index cache, rebuilding it from the GeoNames
+// files when it is missing or older than they are
+func loadGeoNamesIndex(path string) (*geonamesIndex, error) {
+	stamp, err := geonamesSourceStamp(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cachePath := path + geonamesIndexSuffix
+	if idx, err := readGeoNamesIndexCache(cachePath, stamp); err == nil {
+		idx.buildPoints()
+		return idx, nil
+	}
+
+	fmt.Printf("🗺️  Building GeoNames index from %s (first run only)...\n", filepath.Base(path))
+	start := time.Now()
+
+	idx, err := parseGeoNames(path)
+	if err != nil {
+		return nil, err
+	}
+	idx.buildKDTree()
+
+	fmt.Printf("🗺️  Indexed %d places in %v\n", len(idx.places), time.Since(start).Round(time.Millisecond))
+
+	if err := writeGeoNamesIndexCache(cachePath, stamp, idx); err != nil {
+		fmt.Printf("⚠️  Warning: Could not save GeoNames index cache: %v\n", err)
+	}
+	return idx, nil
+}
+
+// buildPoints computes the sphere vector of every place
+func (idx *geonamesIndex) buildPoints() {
+	idx.points = make([][3]float64, len(idx.places))
+	for i, place := range idx.places {
+		idx.points[i] = sphereVector(float64(place.Latitude), float64(place.Longitude))
+	}
+}
+
+// geonamesSourceStamp identifies the current versions of the GeoNames files
+func geonamesSourceStamp(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open GeoNames file: %v", err)
+	}
+	parts := []string{fmt.Sprintf("%d:%d", info.Size(), info.ModTime().UnixNano())}
+
+	dir := filepath.Dir(path)
+	for _, name := range []string{geonamesAdmin1File, geonamesAdmin2File} {
+		if info, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			parts = append(parts, fmt.Sprintf("%s=%d:%d", name, info.Size(), info.ModTime().UnixNano()))
+		}
+	}
+	return strings.Join(parts, ";"), nil
+}
+
+// parseGeoNames reads the populated places of a GeoNames dump, naming their state and
+// county from the admin code files next to it
+func parseGeoNames(path string) (*geonamesIndex, error) {
+	dir := filepath.Dir(path)
+	admin1, err := loadGeoNamesAdminCodes(filepath.Join(dir, geonamesAdmin1File))
+	if err != nil {
+		fmt.Printf("⚠️  Warning: %v - places will have no state\n", err)
+	}
+	admin2, err := loadGeoNamesAdminCodes(filepath.Join(dir, geonamesAdmin2File))
+	if err != nil && !os.IsNotExist(err) {
+		fmt.Printf("⚠️  Warning: %v - places will have no county\n", err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open GeoNames file: %v", err)
+	}
+	defer file.Close()
+
+	idx := &geonamesIndex{names: []string{""}}
+	nameIDs := map[string]uint32{"": 0}
+	intern := func(name string) uint32 {
+		if id, exists := nameIDs[name]; exists {
+			return id
+		}
+		id := uint32(len(idx.names))
+		idx.names = append(idx.names, name)
+		nameIDs[name] = id
+		return id
+	}
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024) // Alternate names make some lines very long
+	for scanner.Scan() {
+		// geonameid, name, asciiname, alternatenames, latitude, longitude, feature class,
+		// feature code, country code, cc2, admin1, admin2, ...
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) < 12 || fields[6] != "P" || geonamesSkippedFeatures[fields[7]] || len(fields[8]) != 2 {
+			continue
+		}
+
+		lat, latErr := strconv.ParseFloat(fields[4], 64)
+		lon, lonErr := strconv.ParseFloat(fields[5], 64)
+		if latErr != nil || lonErr != nil {
+			continue
+		}
+
+		name := fields[2] // ASCII name keeps folder names plain
+		if name == "" {
+			name = fields[1]
+		}
+
+		code := fields[8]
+		place := geonamesPlace{
+			Latitude:  float32(lat),
+			Longitude: float32(lon),
+			City:      intern(name),
+			State:     intern(admin1[code+"."+fields[10]]),
+			County:    intern(admin2[code+"."+fields[10]+"."+fields[11]]),
+		}
+		copy(place.CountryCode[:], code)
+		idx.places = append(idx.places, place)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read GeoNames file: %v", err)
+	}
+	if len(idx.places) == 0 {
+		return nil, fmt.Errorf("no populated places found in %s", path)
+	}
+
+	return idx, nil
+}
+
+// loadGeoNamesAdminCodes reads admin1CodesASCII.txt or admin2Codes.txt into code -> ASCII name
+func loadGeoNamesAdminCodes(path string) (map[string]string, error) {
+	codes := make(map[string]string)
+
+	file, err := os.Open(path)
+	if err != nil {
+		return codes, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// code, name, asciiname, geonameid
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) < 3 {
+			continue
+		}
+		name := fields[2]
+		if name == "" {
+			name = fields[1]
+		}
+		codes[fields[0]] = name
+	}
+	return codes, scanner.Err()
+}
+
+// writeGeoNamesIndexCache saves the index in tree order:
+// magic, version, stamp, names (uint16 length + bytes), places (fixed-size records)
+func writeGeoNamesIndexCache(path, stamp string, idx *geonamesIndex) error {
+	tempPath := path + ".tmp"
+	file, err := os.Create(tempPath)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(file)
+	writeErr := func() error {
+		if err := binary.Write(w, binary.LittleEndian, geonamesIndexMagic); err != nil {
+			return err
+		}
+		if err := binary.Write(w, binary.LittleEndian, geonamesIndexVersion); err != nil {
+			return err
+		}
+		if err := writeIndexString(w, stamp); err != nil {
+			return err
+		}
+		if err := binary.Write(w, binary.LittleEndian, uint32(len(idx.names))); err != nil {
+			return err
+		}
+		for _, name := range idx.names {
+			if err := writeIndexString(w, name); err != nil {
+				return err
+			}
+		}
+		if err := binary.Write(w, binary.LittleEndian, uint32(len(idx.places))); err != nil {
+			return err
+		}
+		if err := binary.Write(w, binary.LittleEndian, idx.places); err != nil {
+			return err
+		}
+		return w.Flush()
+	}()
+	closeErr := file.Close()
+
+	if writeErr == nil {
+		writeErr = closeErr
+	}
+	if writeErr != nil {
+		os.Remove(tempPath)
+		return writeErr
+	}
+	return os.Rename(tempPath, path)
+}
+
+// readGeoNamesIndexCache loads an index cache, failing if it was built from other files
+func readGeoNamesIndexCache(path, stamp string) (*geonamesIndex, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	r := bufio.NewReader(file)
+
+	var magic [4]byte
+	var version uint32
+	if err := binary.Read(r, binary.LittleEndian, &magic); err != nil {
+		return nil, err
+	}
+	if err := binary.Read(r, binary.LittleEndian, &version); err != nil {
+		return nil, err
+	}
+	if magic != geonamesIndexMagic || version != geonamesIndexVersion {
+		return nil, fmt.Errorf("unsupported index format")
+	}
+	cachedStamp, err := readIndexString(r)
+	if err != nil {
+		return nil, err
+	}
+	if cachedStamp != stamp {
+		return nil, fmt.Errorf("index is out of date")
+	}
+
+	var nameCount uint32
+	if err := binary.Read(r, binary.LittleEndian, &nameCount); err != nil {
+		return nil, err
+	}
+	idx := &geonamesIndex{names: make([]string, nameCount)}
+	for i := range idx.names {
+		if idx.names[i], err = readIndexString(r); err != nil {
+			return nil, err
+		}
+	}
+
+	var placeCount uint32
+	if err := binary.Read(r, binary.LittleEndian, &placeCount); err != nil {
+		return nil, err
+	}
+	idx.places = make([]geonamesPlace, placeCount)
+	if err := binary.Read(r, binary.LittleEndian, idx.places); err != nil {
+		return nil, err
+	}
+	for _, place := range idx.places {
+		if place.City >= nameCount || place.County >= nameCount || place.State >= nameCount {
+			return nil, fmt.Errorf("corrupt index")
+		}
+	}
+
+	return idx, nil
+}
+
+// writeIndexString writes a uint16 length-prefixed string
+func writeIndexString(w io.Writer, value string) error {
+	if len(value) > math.MaxUint16 {
+		value = value[:math.MaxUint16]
+	}
+	if err := binary.Write(w, binary.LittleEndian, uint16(len(value))); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, value)
+	return err
+}
+
+// readIndexString reads a uint16 length-prefixed string
+func readIndexString(r io.Reader) (string, error) {
+	var length uint16
+	if err := binary.Read(r, binary.LittleEndian, &length); err != nil {
+		return "", err
+	}
+	buf := make([]byte, length)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
@@ -0,0 +1,335 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// geotagDefaultMaxGap is how far from the nearest track point a photo may be taken by default
+const geotagDefaultMaxGap = 15 * time.Minute
+
+// geotagger places photos without GPS on a recorded track by their capture time
+type geotagger struct {
+	track       *trackLog
+	maxGap      time.Duration
+	clockOffset time.Duration  // How far the camera clock was ahead of the real time
+	cameraZone  *time.Location // Zone the camera clock was set to; nil uses the default zone
+
+	tagged   int64
+	untagged int64
+}
+
+// activeGeotagger makes readMetadata fill missing GPS from the track; set by the geotag command
+var activeGeotagger *geotagger
+
+// captureInstant returns the real UTC time a file was taken: its capture date read in the
+// camera's zone, corrected by the camera clock offset
+func (g *geotagger) captureInstant(metadata *MediaMetadata) (time.Time, bool) {
+	wallClock, field, err := pickMetadataDate(metadata)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	var instant time.Time
+	if field == "CreateDate" && metadata.CreateDateIsUTC {
+		instant = wallClock
+	} else if zone, ok := parseUTCOffset(metadata.OffsetTimeOriginal); ok && field == "DateTimeOriginal" {
+		instant = inZone(wallClock, zone)
+	} else if g.cameraZone != nil {
+		instant = inZone(wallClock, g.cameraZone)
+	} else {
+		instant = inZone(wallClock, defaultTimezone())
+	}
+	return instant.Add(-g.clockOffset).UTC(), true
+}
+
+// locate returns the track position at the file's capture time
+func (g *geotagger) locate(metadata *MediaMetadata) (lat, lon float64, found bool) {
+	instant, ok := g.captureInstant(metadata)
+	if !ok {
+		return 0, 0, false
+	}
+	return g.track.positionAt(instant, g.maxGap)
+}
+
+// mergeTrackMetadata fills a file's missing GPS from the track
+func (g *geotagger) mergeTrackMetadata(metadata *MediaMetadata) *MediaMetadata {
+	if metadata == nil || metadata.HasGPS {
+		return metadata
+	}
+
+	lat, lon, found := g.locate(metadata)
+	if !found {
+		atomic.AddInt64(&g.untagged, 1)
+		return metadata
+	}
+	atomic.AddInt64(&g.tagged, 1)
+
+	tagged := *metadata
+	tagged.HasGPS = true
+	tagged.Latitude = lat
+	tagged.Longitude = lon
+	return &tagged
+}
+
+// writeGeotags writes the track position into every file under sourcePath that has no GPS
+func (g *geotagger) writeGeotags(sourcePath string, dryRun bool) error {
+	written, skipped, unmatched, failed := 0, 0, 0, 0
+
+	err := filepath.Walk(sourcePath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !isMediaFile(path) {
+			return nil
+		}
+
+		metadata, err := readMetadata(path)
+		if err != nil || metadata.HasGPS {
+			skipped++
+			return nil
+		}
+
+		lat, lon, found := g.locate(metadata)
+		if !found {
+			fmt.Printf("⚠️  No track point within %v of %s\n", g.maxGap, filepath.Base(path))
+			unmatched++
+			return nil
+		}
+		args := gpsWriteArgs(path, lat, lon)
+		if len(args) == 0 {
+			skipped++
+			return nil
+		}
+
+		if dryRun {
+			fmt.Printf("📌 [DRY RUN] Would geotag %s: %.6f, %.6f\n", filepath.Base(path), lat, lon)
+			written++
+			return nil
+		}
+
+		args = append([]string{"-overwrite_original"}, args...)
+		args = append(args, path)
+		err = activeJournal.edit(path, func() error {
+			stdout, stderr, err := GetExiftoolPool().Execute(args...)
+			if err == nil {
+				err = exiftoolWriteError(stdout, stderr)
+			}
+			return err
+		})
+		invalidateMetadataCache(path)
+		if err != nil {
+			fmt.Printf("❌ Failed to geotag %s: %v\n", filepath.Base(path), err)
+			failed++
+			return nil
+		}
+
+		fmt.Printf("📌 Geotagged %s: %.6f, %.6f\n", filepath.Base(path), lat, lon)
+		written++
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("📊 Geotag: %d tagged, %d outside the track, %d already had GPS or cannot be tagged, %d failed\n",
+		written, unmatched, skipped, failed)
+	return nil
+}
+
+// parseClockOffset parses a camera clock offset such as +1h2m3s, -90s, +2d6h or -00:01:30
+func parseClockOffset(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	sign := time.Duration(1)
+	if strings.HasPrefix(value, "-") {
+		sign, value = -1, value[1:]
+	} else {
+		value = strings.TrimPrefix(value, "+")
+	}
+	// the sign applies to the whole offset, so none may follow it
+	if strings.ContainsAny(value, "+-") {
+		return 0, fmt.Errorf("invalid clock offset '%s' (a sign may only lead the offset)", value)
+	}
+
+	// time.ParseDuration has no days
+	var days time.Duration
+	if d := strings.Index(value, "d"); d > 0 {
+		n, err := strconv.Atoi(value[:d])
+		if err != nil {
+			return 0, fmt.Errorf("invalid clock offset '%s'", value)
+		}
+		days, value = time.Duration(n)*24*time.Hour, value[d+1:]
+		if value == "" {
+			return sign * days, nil
+		}
+	}
+
+	if parts := strings.Split(value, ":"); len(parts) == 3 {
+		var total time.Duration
+		for i, unit := range []time.Duration{time.Hour, time.Minute, time.Second} {
+			n, err := strconv.Atoi(parts[i])
+			if err != nil {
+				return 0, fmt.Errorf("invalid clock offset '%s'", value)
+			}
+			total += time.Duration(n) * unit
+		}
+		return sign * (days + total), nil
+	}
+
+	offset, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid clock offset '%s' (e.g. +2m30s, +1d2h or -01:00:00)", value)
+	}
+	return sign * (days + offset), nil
+}
+
+// runGeotagCommand places files without GPS on GPX/KML/GeoJSON tracks. With a destination
+// the positions feed the process pipeline; without one they are written into the files
+func runGeotagCommand(args []string) error {
+	var paths, tracks []string
+	g := &geotagger{maxGap: geotagDefaultMaxGap}
+	workers := 4
+	dryRun := false
+	dryRunSampleSize := 0
+	showProgress := true
+	writeMetadata := false
+
+	for i := 0; i < len(args); i++ {
+		needsValue := func() (string, error) {
+			if i+1 >= len(args) {
+				return "", fmt.Errorf("%s requires a value", args[i])
+			}
+			i++
+			return args[i], nil
+		}
+
+		switch args[i] {
+		case "--track":
+			value, err := needsValue()
+			if err != nil {
+				return err
+			}
+			tracks = append(tracks, value)
+		case "--max-gap":
+			value, err := needsValue()
+			if err != nil {
+				return err
+			}
+			if g.maxGap, err = time.ParseDuration(value); err != nil || g.maxGap <= 0 {
+				return fmt.Errorf("invalid --max-gap '%s' (e.g. 10m)", value)
+			}
+		case "--clock-offset":
+			value, err := needsValue()
+			if err != nil {
+				return err
+			}
+			if g.clockOffset, err = parseClockOffset(value); err != nil {
+				return err
+			}
+		case "--camera-timezone":
+			value, err := needsValue()
+			if err != nil {
+				return err
+			}
+			if g.cameraZone, err = time.LoadLocation(value); err != nil {
+				return fmt.Errorf("unknown --camera-timezone '%s': %v", value, err)
+			}
+		case "--workers":
+			value, err := needsValue()
+			if err != nil {
+				return err
+			}
+			if workers, err = strconv.Atoi(value); err != nil || workers < 1 {
+				return fmt.Errorf("invalid worker count: %s", value)
+			}
+		case "--dry-run":
+			dryRun = true
+			if i+1 < len(args) && !strings.HasPrefix(args[i+1], "--") {
+				if size, err := strconv.Atoi(args[i+1]); err == nil && size > 0 {
+					dryRunSampleSize = size
+					i++
+				}
+			}
+		case "--progress":
+			showProgress = true
+		case "--no-progress":
+			showProgress = false
+		case "--write-metadata":
+			writeMetadata = true
+		default:
+			if strings.HasPrefix(args[i], "--") {
+				return fmt.Errorf("unknown geotag option %s", args[i])
+			}
+			paths = append(paths, args[i])
+		}
+	}
+
+	if len(paths) == 0 || len(paths) > 2 || len(tracks) == 0 {
+		return fmt.Errorf("usage: geotag /source/path [/destination/path] --track FILE [--track FILE ...]")
+	}
+	sourcePath := paths[0]
+	if _, err := os.Stat(sourcePath); os.IsNotExist(err) {
+		return fmt.Errorf("source path does not exist: %s", sourcePath)
+	}
+
+	track, err := loadTrackLog(tracks)
+	if err != nil {
+		return err
+	}
+	g.track = track
+	first, last := track.span()
+	fmt.Printf("🛰️  Loaded %d track points from %d file(s), %s to %s UTC\n",
+		len(track.points), len(tracks), first.Format("2006-01-02 15:04"), last.Format("2006-01-02 15:04"))
+	if g.clockOffset != 0 {
+		fmt.Printf("⏱️  Camera clock offset: %v\n", g.clockOffset)
+	}
+
+	// Without a destination the coordinates are only written into the files
+	if len(paths) == 1 {
+		if !confirmOperation("geotag", sourcePath, "", dryRun, 0) {
+			fmt.Println("❌ Operation cancelled by user.")
+			return nil
+		}
+		startJournal("geotag", sourcePath, dryRun)
+		defer activeJournal.close()
+		return g.writeGeotags(sourcePath, dryRun)
+	}
+
+	destPath := paths[1]
+	if !confirmOperation("geotag", sourcePath, destPath, dryRun, dryRunSampleSize) {
+		fmt.Println("❌ Operation cancelled by user.")
+		return nil
+	}
+	if err := os.MkdirAll(destPath, 0755); err != nil {
+		return fmt.Errorf("failed to create destination path: %v", err)
+	}
+	startJournal("geotag", destPath, dryRun)
+	defer activeJournal.close()
+
+	if writeMetadata {
+		fmt.Printf("✏️  Writing track positions into the files...\n")
+		if err := g.writeGeotags(sourcePath, dryRun); err != nil {
+			return err
+		}
+	}
+
+	// The process pipeline reads the track positions as if the files had GPS. Reads cached by
+	// writeGeotags predate the track, and a dry run left the files without it
+	activeGeotagger = g
+	clearMetadataCache()
+	if err := processPhotosWithProgress(sourcePath, destPath, workers, dryRun, dryRunSampleSize, showProgress, false, ""); err != nil {
+		return err
+	}
+	cleanupSourceIfMoved("process", sourcePath, dryRun)
+
+	fmt.Printf("📌 %d file(s) placed on the track\n", atomic.LoadInt64(&g.tagged))
+	if untagged := atomic.LoadInt64(&g.untagged); untagged > 0 {
+		fmt.Printf("ℹ️  %d file(s) were taken outside the track and left in place - try a larger --max-gap or the datetime command\n", untagged)
+	}
+	return nil
+}
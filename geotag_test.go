@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseClockOffset(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"+1h2m3s", time.Hour + 2*time.Minute + 3*time.Second, true},
+		{"-90s", -90 * time.Second, true},
+		{"2m30s", 2*time.Minute + 30*time.Second, true},
+		{"+2d6h", 54 * time.Hour, true},
+		{"-1d", -24 * time.Hour, true},
+		{"-00:01:30", -90 * time.Second, true},
+		{"+1d01:00:00", 25 * time.Hour, true},
+		{"+-5m", 0, false},
+		{"--5m", 0, false},
+		{"2d-3h", 0, false},
+		{"1h-30m", 0, false},
+		{"-01:-01:00", 0, false},
+		{"+", 0, false},
+		{"soon", 0, false},
+	}
+
+	for _, tt := range tests {
+		got, err := parseClockOffset(tt.value)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("parseClockOffset(%q) = %v, %v, want %v (ok %v)", tt.value, got, err, tt.want, tt.ok)
+		}
+	}
+}
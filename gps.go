@@ -2,57 +2,25 @@ package main
 
 import (
 	"fmt"
-	"os/exec"
-	"strconv"
+	"math"
+	"path/filepath"
 	"strings"
 )
 
 // extractGPSCoordinates extracts GPS latitude and longitude from photo metadata
 func extractGPSCoordinates(photoPath string) (lat float64, lon float64, err error) {
-	// Use exiftool to extract GPS coordinates
-	args := []string{
-		"-GPSLatitude",
-		"-GPSLongitude", 
-		"-n", // Output coordinates in decimal degrees
-		"-T", // Tab-separated output
-		photoPath,
-	}
-	
-	cmd := exec.Command("exiftool", args...)
-	output, err := cmd.CombinedOutput()
-	
+	// Read metadata natively where possible (exiftool only for unsupported formats)
+	metadata, err := readMetadata(photoPath)
 	if err != nil {
-		return 0, 0, fmt.Errorf("exiftool failed: %v", err)
+		return 0, 0, err
 	}
 	
-	outputStr := strings.TrimSpace(string(output))
-	if outputStr == "" || outputStr == "-\t-" {
+	if !metadata.HasGPS {
 		return 0, 0, fmt.Errorf("no GPS data found")
 	}
 	
-	// Parse tab-separated output: lat \t lon
-	parts := strings.Split(outputStr, "\t")
-	if len(parts) != 2 || parts[0] == "-" || parts[1] == "-" {
-		return 0, 0, fmt.Errorf("invalid GPS data format")
-	}
-	
-	latStr := strings.TrimSpace(parts[0])
-	lonStr := strings.TrimSpace(parts[1])
-	
-	if latStr == "" || lonStr == "" {
-		return 0, 0, fmt.Errorf("empty GPS coordinates")
-	}
-	
-	// Convert to float
-	lat, err = strconv.ParseFloat(latStr, 64)
-	if err != nil {
-		return 0, 0, fmt.Errorf("invalid latitude: %v", err)
-	}
-	
-	lon, err = strconv.ParseFloat(lonStr, 64)
-	if err != nil {
-		return 0, 0, fmt.Errorf("invalid longitude: %v", err)
-	}
+	lat = metadata.Latitude
+	lon = metadata.Longitude
 	
 	// Validate coordinate ranges
 	if lat < -90.0 || lat > 90.0 {
@@ -64,4 +32,33 @@ func extractGPSCoordinates(photoPath string) (lat float64, lon float64, err erro
 	}
 	
 	return lat, lon, nil
-}
\ No newline at end of file
+}
+
+// gpsWriteArgs builds the exiftool assignments that store coordinates in a file: QuickTime
+// keys for MOV/MP4, signed XMP values for .xmp sidecars, EXIF GPS tags for images.
+// Nil for containers exiftool cannot write
+func gpsWriteArgs(path string, lat, lon float64) []string {
+	if strings.EqualFold(filepath.Ext(path), ".xmp") {
+		return []string{fmt.Sprintf("-XMP:GPSLatitude=%.6f", lat), fmt.Sprintf("-XMP:GPSLongitude=%.6f", lon)}
+	}
+	if isQuickTimeFile(path) {
+		return []string{fmt.Sprintf("-Keys:GPSCoordinates=%.6f, %.6f", lat, lon)}
+	}
+	if isVideoFile(path) {
+		return nil
+	}
+	
+	latRef, lonRef := "N", "E"
+	if lat < 0 {
+		latRef = "S"
+	}
+	if lon < 0 {
+		lonRef = "W"
+	}
+	return []string{
+		fmt.Sprintf("-GPSLatitude=%.6f", math.Abs(lat)),
+		fmt.Sprintf("-GPSLatitudeRef=%s", latRef),
+		fmt.Sprintf("-GPSLongitude=%.6f", math.Abs(lon)),
+		fmt.Sprintf("-GPSLongitudeRef=%s", lonRef),
+	}
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Location granularity (config location_granularity): which level of a geocoding result names the folder
+const (
+	granularityLocality = "locality" // City, town or village (default)
+	granularityCounty   = "county"
+	granularityState    = "state" // State or region
+	granularityCountry  = "country"
+)
+
+// isValidGranularity checks a configured granularity
+func isValidGranularity(granularity string) bool {
+	switch granularity {
+	case granularityLocality, granularityCounty, granularityState, granularityCountry:
+		return true
+	}
+	return false
+}
+
+// granularityFor returns the granularity configured for the result's country, matched by
+// ISO code or name, falling back to the global setting
+func granularityFor(result GeocodeResult) string {
+	config := GetAppConfig()
+	for country, granularity := range config.LocationGranularityByCountry {
+		if (result.CountryCode != "" && strings.EqualFold(country, result.CountryCode)) ||
+			(result.Country != "" && countrySlug(country) == countrySlug(result.Country)) {
+			return granularity
+		}
+	}
+	if config.LocationGranularity != "" {
+		return config.LocationGranularity
+	}
+	return granularityLocality
+}
+
+// placeName picks the name of the result at the granularity, falling back to the
+// nearest level the provider returned
+func placeName(result GeocodeResult, granularity string) string {
+	var levels []string
+	switch granularity {
+	case granularityCountry:
+		// The country names the city folder too (spain/spain)
+		return result.Country
+	case granularityState:
+		levels = []string{result.State, result.County, result.City}
+	case granularityCounty:
+		levels = []string{result.County, result.State, result.City}
+	default:
+		levels = []string{result.City, result.County, result.State}
+	}
+
+	for _, name := range levels {
+		if name != "" {
+			return name
+		}
+	}
+	return ""
+}
+
+// smallPlaceCounter counts how many photos each locality has in the destination and the run,
+// so that merge_small_places can roll the small ones up to their county
+type smallPlaceCounter struct {
+	mu     sync.Mutex
+	counts map[string]int // Locality location (e.g. deia-spain) -> photos
+}
+
+var smallPlaces = &smallPlaceCounter{}
+
+// isSmall reports whether fewer than merge_small_places photos land in the locality, counting
+// those already filed there. Localities that were not counted are never rolled up
+func (s *smallPlaceCounter) isSmall(location string) bool {
+	threshold := GetAppConfig().MergeSmallPlaces
+	if threshold <= 0 {
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	count, counted := s.counts[location]
+	return counted && count < threshold
+}
+
+// countSmallPlaces counts the photos of each locality before processing: those already in the
+// destination's locality folders plus the run's, geocoded by the workers. Lookups go through the
+// geocode cache, so the run itself reuses them. Counting the destination keeps a place in the same
+// folder from one run to the next
+func countSmallPlaces(jobs []WorkJob, workers int) {
+	threshold := GetAppConfig().MergeSmallPlaces
+	if threshold <= 0 || len(jobs) == 0 {
+		return
+	}
+
+	if workers < 1 {
+		workers = 1
+	}
+
+	fmt.Printf("🏘️  Counting photos per place (merge_small_places: %d)...\n", threshold)
+	counts := countDestinationPlaces(jobs[0].DestPath)
+	inRun := make(map[string]bool)
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	queue := make(chan WorkJob, workers*2)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range queue {
+				lat, lon, err := extractGPSCoordinates(job.PhotoPath)
+				if err != nil || GetPlaces().match(lat, lon) != nil {
+					continue
+				}
+				result, err := GetGeocoder().ReverseGeocode(lat, lon)
+				if err != nil || granularityFor(*result) != granularityLocality {
+					continue
+				}
+				location := formatLocationName(*result, granularityLocality)
+				mu.Lock()
+				counts[location]++
+				inRun[location] = true
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, job := range jobs {
+		queue <- job
+	}
+	close(queue)
+	wg.Wait()
+
+	small := 0
+	for location := range inRun {
+		if counts[location] < threshold {
+			small++
+		}
+	}
+	fmt.Printf("🏘️  %d of the run's %d places have fewer than %d photos and are filed under their county\n", small, len(inRun), threshold)
+
+	smallPlaces.mu.Lock()
+	smallPlaces.counts = counts
+	smallPlaces.mu.Unlock()
+}
+
+// countDestinationPlaces counts the media files already in each location folder of the
+// destination, keyed like formatLocationName (city-country). RAW files of pairs are not
+// counted, as their JPEG is
+func countDestinationPlaces(destPath string) map[string]int {
+	counts := make(map[string]int)
+	filepath.Walk(destPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() {
+			if info.Name() == journalDirName || info.Name() == rawSubfolderName {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !isMediaFile(path) {
+			return nil
+		}
+		_, location, err := extractDateLocationFromPath(path, destPath)
+		if err != nil {
+			return nil
+		}
+		if country, city := locationFields(location); country != "" && city != "" {
+			counts[city+"-"+country]++
+		}
+		return nil
+	})
+	return counts
+}
+
+// granularLocation formats a geocoding result at its configured granularity, rolling the
+// locality up to its county when small reports too few photos there
+func granularLocation(result GeocodeResult, small func(location string) bool) string {
+	granularity := granularityFor(result)
+	location := formatLocationName(result, granularity)
+	if granularity == granularityLocality && small(location) {
+		return formatLocationName(result, granularityCounty)
+	}
+	return location
+}
+
+// locationGranularityConfigured reports whether folders are named at anything other than the locality
+func locationGranularityConfigured() bool {
+	config := GetAppConfig()
+	return (config.LocationGranularity != "" && config.LocationGranularity != granularityLocality) ||
+		len(config.LocationGranularityByCountry) > 0 || config.MergeSmallPlaces > 0
+}
+
+// regroupDestinationFolders maps destination folders (as extractDateLocationFromPath returns
+// them) to the folders the configured granularity gives today, so the datetime database
+// files photos the way process and merge do. Each folder is resolved from the first of its
+// photos with GPS; folders without one keep their name
+func regroupDestinationFolders(destPath string) map[string]string {
+	regrouped := make(map[string]string)
+	if !locationGranularityConfigured() {
+		return regrouped
+	}
+
+	folders := make(map[string][]string)
+	var order []string
+	filepath.Walk(destPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || !isMediaFile(path) {
+			return nil
+		}
+		_, location, err := extractDateLocationFromPath(path, destPath)
+		if err != nil {
+			return nil
+		}
+		if _, exists := folders[location]; !exists {
+			order = append(order, location)
+		}
+		folders[location] = append(folders[location], path)
+		return nil
+	})
+
+	threshold := GetAppConfig().MergeSmallPlaces
+	for _, location := range order {
+		if !strings.Contains(filepath.ToSlash(location), "/") {
+			continue // Not a country/city folder
+		}
+		files := folders[location]
+		_, folderCity := locationFields(location)
+
+		result := geocodeFolder(files)
+		if result == nil {
+			continue
+		}
+
+		newLocation := granularLocation(*result, func(string) bool {
+			if threshold <= 0 {
+				return false
+			}
+			// A folder already named after the county was rolled up by an earlier run
+			_, countyCity, _ := parseLocation(formatLocationName(*result, granularityCounty))
+			return len(files) < threshold || folderCity == countyCity
+		})
+		country, city, err := parseLocation(newLocation)
+		if err != nil {
+			continue
+		}
+
+		target := relocateFolder(location, country, city)
+		if target != location {
+			regrouped[location] = target
+			fmt.Printf("🗂️  Folder %s -> %s (location granularity)\n", location, target)
+		}
+	}
+
+	return regrouped
+}
+
+// geocodeFolder geocodes the first photo with GPS among files; nil when none has GPS,
+// one is inside a user-defined place, or no geocoder answers
+func geocodeFolder(files []string) *GeocodeResult {
+	for _, path := range files {
+		lat, lon, err := extractGPSCoordinates(path)
+		if err != nil {
+			continue
+		}
+		if GetPlaces().match(lat, lon) != nil {
+			return nil
+		}
+
+		result, err := GetGeocoder().ReverseGeocode(lat, lon)
+		if err != nil {
+			return nil
+		}
+		return result
+	}
+	return nil
+}
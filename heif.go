@@ -0,0 +1,311 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Size limits for the in-memory parts of a HEIF file
+const (
+	maxHEIFMetaBoxSize = 16 << 20
+	maxHEIFExifSize    = 16 << 20
+)
+
+// heifExtent is one piece of an item's data as described by the iloc box
+type heifExtent struct {
+	offset uint64
+	length uint64
+}
+
+// heifItemLocation describes where an item's data lives
+type heifItemLocation struct {
+	constructionMethod uint16 // 0 = file offset, 1 = idat offset
+	baseOffset         uint64
+	extents            []heifExtent
+}
+
+// heifMetadataReader reads EXIF from HEIC/HEIF/AVIF files by walking the ISOBMFF meta box
+type heifMetadataReader struct{}
+
+func (r *heifMetadataReader) Name() string { return "heif" }
+
+// Supports reports whether the file extension is an ISOBMFF still image format
+func (r *heifMetadataReader) Supports(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".heic" || ext == ".heif" || ext == ".hif" || ext == ".avif"
+}
+
+// Read locates the Exif item through meta/iinf/iloc and decodes it with the EXIF parser
+func (r *heifMetadataReader) Read(path string) (*MediaMetadata, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %v", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat file: %v", err)
+	}
+
+	topLevel, err := readBMFFBoxes(file, 0, info.Size())
+	if err != nil || len(topLevel) == 0 || topLevel[0].boxType != "ftyp" {
+		return nil, errUnsupportedFormat
+	}
+
+	metaBox, found := findBMFFBox(topLevel, "meta")
+	if !found {
+		return nil, fmt.Errorf("no meta box found")
+	}
+	meta, err := readBMFFPayload(file, metaBox, maxHEIFMetaBoxSize)
+	if err != nil {
+		return nil, err
+	}
+	if len(meta) < 4 {
+		return nil, fmt.Errorf("meta box too short")
+	}
+
+	// meta is a full box - skip version and flags
+	metaReader := bytes.NewReader(meta)
+	children, err := readBMFFBoxes(metaReader, 4, int64(len(meta)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse meta box: %v", err)
+	}
+
+	iinf, found := findBMFFBox(children, "iinf")
+	if !found {
+		return nil, fmt.Errorf("no iinf box found")
+	}
+	exifItemID, found, err := findHEIFExifItem(meta[iinf.offset : iinf.offset+iinf.size])
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		// Valid HEIF without EXIF
+		return &MediaMetadata{Source: "heif"}, nil
+	}
+
+	iloc, found := findBMFFBox(children, "iloc")
+	if !found {
+		return nil, fmt.Errorf("no iloc box found")
+	}
+	location, err := findHEIFItemLocation(meta[iloc.offset:iloc.offset+iloc.size], exifItemID)
+	if err != nil {
+		return nil, err
+	}
+
+	var idat []byte
+	if box, found := findBMFFBox(children, "idat"); found {
+		idat = meta[box.offset : box.offset+box.size]
+	}
+
+	exifData, err := readHEIFItemData(file, info.Size(), idat, location)
+	if err != nil {
+		return nil, err
+	}
+
+	tiffStart, err := heifTIFFStart(exifData)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata, err := parseTIFFMetadata(bytes.NewReader(exifData), tiffStart, int64(len(exifData)))
+	if err != nil {
+		return nil, err
+	}
+	metadata.Source = "heif"
+	return metadata, nil
+}
+
+// findHEIFExifItem scans the iinf item entries for the item of type "Exif"
+func findHEIFExifItem(iinf []byte) (uint32, bool, error) {
+	cursor := &bmffCursor{data: iinf}
+	version := cursor.uint8()
+	cursor.bytes(3) // flags
+
+	var entryCount uint32
+	if version == 0 {
+		entryCount = uint32(cursor.uint16())
+	} else {
+		entryCount = cursor.uint32()
+	}
+	if cursor.err != nil {
+		return 0, false, fmt.Errorf("invalid iinf box: %v", cursor.err)
+	}
+
+	entries, err := readBMFFBoxes(bytes.NewReader(iinf), int64(cursor.pos), int64(len(iinf)))
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid iinf box: %v", err)
+	}
+	if uint32(len(entries)) < entryCount {
+		entryCount = uint32(len(entries))
+	}
+
+	for _, entry := range entries[:entryCount] {
+		if entry.boxType != "infe" {
+			continue
+		}
+
+		infe := &bmffCursor{data: iinf[entry.offset : entry.offset+entry.size]}
+		infeVersion := infe.uint8()
+		infe.bytes(3) // flags
+		if infeVersion < 2 {
+			// Version 0/1 entries predate item types and never describe EXIF
+			continue
+		}
+
+		var itemID uint32
+		if infeVersion == 2 {
+			itemID = uint32(infe.uint16())
+		} else {
+			itemID = infe.uint32()
+		}
+		infe.uint16() // item_protection_index
+		itemType := string(infe.bytes(4))
+
+		if infe.err == nil && itemType == "Exif" {
+			return itemID, true, nil
+		}
+	}
+
+	return 0, false, nil
+}
+
+// findHEIFItemLocation decodes the iloc box and returns the location of itemID
+func findHEIFItemLocation(iloc []byte, itemID uint32) (*heifItemLocation, error) {
+	cursor := &bmffCursor{data: iloc}
+	version := cursor.uint8()
+	cursor.bytes(3) // flags
+
+	sizes := cursor.uint16()
+	offsetSize := int(sizes >> 12)
+	lengthSize := int((sizes >> 8) & 0x0F)
+	baseOffsetSize := int((sizes >> 4) & 0x0F)
+	indexSize := 0
+	if version == 1 || version == 2 {
+		indexSize = int(sizes & 0x0F)
+	}
+
+	var itemCount uint32
+	if version < 2 {
+		itemCount = uint32(cursor.uint16())
+	} else {
+		itemCount = cursor.uint32()
+	}
+
+	for i := uint32(0); i < itemCount && cursor.err == nil; i++ {
+		var id uint32
+		if version < 2 {
+			id = uint32(cursor.uint16())
+		} else {
+			id = cursor.uint32()
+		}
+
+		location := &heifItemLocation{}
+		if version == 1 || version == 2 {
+			location.constructionMethod = cursor.uint16() & 0x0F
+		}
+		cursor.uint16() // data_reference_index
+		location.baseOffset = cursor.sized(baseOffsetSize)
+
+		extentCount := cursor.uint16()
+		for e := uint16(0); e < extentCount && cursor.err == nil; e++ {
+			if indexSize > 0 {
+				cursor.sized(indexSize)
+			}
+			extent := heifExtent{
+				offset: cursor.sized(offsetSize),
+				length: cursor.sized(lengthSize),
+			}
+			location.extents = append(location.extents, extent)
+		}
+
+		if cursor.err == nil && id == itemID {
+			return location, nil
+		}
+	}
+
+	if cursor.err != nil {
+		return nil, fmt.Errorf("invalid iloc box: %v", cursor.err)
+	}
+	return nil, fmt.Errorf("no location for Exif item %d", itemID)
+}
+
+// readHEIFItemData concatenates an item's extents from the file or the idat box
+func readHEIFItemData(file *os.File, fileSize int64, idat []byte, location *heifItemLocation) ([]byte, error) {
+	var data []byte
+
+	for _, extent := range location.extents {
+		// Offsets and lengths come straight from the file, so every sum is checked before it is formed
+		if extent.offset > math.MaxUint64-location.baseOffset {
+			return nil, fmt.Errorf("Exif item offset out of range")
+		}
+		start := location.baseOffset + extent.offset
+		length := extent.length
+
+		switch location.constructionMethod {
+		case 0:
+			if start > uint64(fileSize) {
+				return nil, fmt.Errorf("Exif item offset %d out of range", start)
+			}
+			if length == 0 {
+				// Zero length means the extent runs to the end of the file
+				length = uint64(fileSize) - start
+			}
+			if length > uint64(fileSize)-start || length > maxHEIFExifSize-uint64(len(data)) {
+				return nil, fmt.Errorf("Exif item extent out of range")
+			}
+			chunk := make([]byte, length)
+			if _, err := file.ReadAt(chunk, int64(start)); err != nil {
+				return nil, fmt.Errorf("failed to read Exif item: %v", err)
+			}
+			data = append(data, chunk...)
+		case 1:
+			if start > uint64(len(idat)) {
+				return nil, fmt.Errorf("Exif item offset %d out of idat range", start)
+			}
+			if length == 0 {
+				length = uint64(len(idat)) - start
+			}
+			if length > uint64(len(idat))-start {
+				return nil, fmt.Errorf("Exif item extent out of idat range")
+			}
+			data = append(data, idat[start:start+length]...)
+		default:
+			return nil, fmt.Errorf("unsupported iloc construction method %d", location.constructionMethod)
+		}
+	}
+
+	return data, nil
+}
+
+// heifTIFFStart returns where the TIFF header begins inside a HEIF Exif item
+// The item starts with a 4-byte offset to the TIFF header (normally past an "Exif\0\0" prefix)
+func heifTIFFStart(exifData []byte) (int64, error) {
+	if len(exifData) < 12 {
+		return 0, fmt.Errorf("Exif item too short")
+	}
+
+	start := 4 + int64(binary.BigEndian.Uint32(exifData[0:4]))
+	if start+8 <= int64(len(exifData)) && isTIFFHeader(exifData[start:start+4]) {
+		return start, nil
+	}
+
+	// Some writers get the offset wrong - look for the header just after it
+	if idx := bytes.Index(exifData[4:], []byte("Exif\x00\x00")); idx >= 0 {
+		start = int64(4 + idx + 6)
+		if start+8 <= int64(len(exifData)) && isTIFFHeader(exifData[start:start+4]) {
+			return start, nil
+		}
+	}
+	if isTIFFHeader(exifData[4:8]) {
+		return 4, nil
+	}
+
+	return 0, fmt.Errorf("no TIFF header in Exif item")
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestHeifMetadataReaderRead(t *testing.T) {
+	withExif := MediaMetadata{
+		Source:             "heif",
+		HasGPS:             true,
+		Latitude:           48.8582,
+		Longitude:          2.29450,
+		DateTimeOriginal:   "2023:07:14 18:30:05",
+		CreateDate:         "2023:07:14 18:30:05",
+		DateTime:           "2023:07:14 18:31:00",
+		ModifyDate:         "2023:07:14 18:31:00",
+		OffsetTimeOriginal: "+02:00",
+		GPSDateStamp:       "2023:07:14",
+		GPSTimeStamp:       "16:30:05",
+		Make:               "Apple",
+		Model:              "iPhone 13",
+	}
+
+	tests := []struct {
+		name string
+		path string
+		want MediaMetadata
+	}{
+		{name: "Exif item in mdat", path: "testdata/heif/exif.heic", want: withExif},
+		{name: "Exif item in idat", path: "testdata/heif/idat.heic", want: withExif},
+		{name: "no Exif item", path: "testdata/heif/no-exif.heic", want: MediaMetadata{Source: "heif"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := (&heifMetadataReader{}).Read(tt.path)
+			if err != nil {
+				t.Fatalf("Read(%s) failed: %v", tt.path, err)
+			}
+			assertMetadata(t, got, tt.want)
+		})
+	}
+}
+
+func TestHeifMetadataReaderRejectsBadOffset(t *testing.T) {
+	if _, err := (&heifMetadataReader{}).Read("testdata/heif/bad-offset.heic"); err == nil {
+		t.Error("Read accepted an Exif item past the end of the file")
+	}
+}
+
+func TestReadHEIFItemDataRejectsOverflow(t *testing.T) {
+	idat := make([]byte, 32)
+	tests := []struct {
+		name     string
+		location heifItemLocation
+		want     string
+	}{
+		{
+			name:     "base offset plus extent offset wraps",
+			location: heifItemLocation{baseOffset: math.MaxUint64 - 4, extents: []heifExtent{{offset: 8, length: 4}}},
+			want:     "offset out of range",
+		},
+		{
+			name:     "idat extent longer than idat",
+			location: heifItemLocation{constructionMethod: 1, extents: []heifExtent{{offset: 16, length: math.MaxUint64 - 8}}},
+			want:     "out of idat range",
+		},
+		{
+			name:     "idat offset past idat",
+			location: heifItemLocation{constructionMethod: 1, extents: []heifExtent{{offset: 64, length: 4}}},
+			want:     "out of idat range",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			location := tt.location
+			_, err := readHEIFItemData(nil, 0, idat, &location)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("readHEIFItemData error = %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+}
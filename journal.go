@@ -0,0 +1,270 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// journalDirName is the folder in a destination holding its journal and the backups undo restores from
+const journalDirName = ".photo-meta-journal"
+
+// journalFileName is the append-only journal, one JSON entry per line
+const journalFileName = "journal.jsonl"
+
+// Journal operations besides the transfer modes (move, copy, hardlink, symlink, reflink)
+const (
+	journalRun      = "run"      // Start of a run, with its command line
+	journalRename   = "rename"   // Renamed in place (tiff, timeshift); undone like a move
+	journalDelete   = "delete"   // Removed; the file is kept as the backup
+	journalEdit     = "edit"     // Metadata written into an existing file; its previous content is the backup
+	journalCreate   = "create"   // New file written, such as an XMP sidecar
+	journalUndone   = "undone"   // An operation of an earlier run was undone
+	journalImported = "imported" // Source left alone: the library already has it as the target
+)
+
+// journalEntry is one line of the journal
+type journalEntry struct {
+	Run     string    `json:"run"`
+	Seq     int       `json:"seq"`
+	Time    time.Time `json:"time"`
+	Op      string    `json:"op"`
+	Source  string    `json:"source,omitempty"`
+	Target  string    `json:"target,omitempty"`
+	Hash    string    `json:"hash,omitempty"`   // SHA-256 of the target once the operation finished
+	Backup  string    `json:"backup,omitempty"` // Previous content of a deleted or edited file
+	Command string    `json:"command,omitempty"`
+	Undoes  string    `json:"undoes,omitempty"` // RUN:SEQ of the undone operation
+}
+
+// journal appends one run's file operations to the journal of its destination
+type journal struct {
+	mu      sync.Mutex
+	runID   string
+	dir     string
+	file    *os.File
+	seq     int
+	backups int
+}
+
+// activeJournal records the operations of the current run; nil in dry runs and with --no-journal
+var activeJournal *journal
+
+// startJournal opens the journal in root for a new run of command and records its command line
+func startJournal(command, root string, dryRun bool) {
+	if dryRun || GetAppConfig().DisableJournal {
+		return
+	}
+	j, err := openJournal(root)
+	if err != nil {
+		fmt.Printf("⚠️  Warning: Could not open the journal, this %s run cannot be undone: %v\n", command, err)
+		return
+	}
+	j.record(journalEntry{Op: journalRun, Command: strings.Join(os.Args[1:], " ")})
+	activeJournal = j
+}
+
+// openJournal opens root's journal for appending under a new run ID
+func openJournal(root string) (*journal, error) {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Join(absRoot, journalDirName)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	// Run IDs are the start time; a second run in the same second gets a suffix
+	existing := make(map[string]bool)
+	entries, err := readJournal(absRoot)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		existing[entry.Run] = true
+	}
+	runID := time.Now().Format("20060102-150405")
+	for n := 2; existing[runID]; n++ {
+		runID = fmt.Sprintf("%s-%d", time.Now().Format("20060102-150405"), n)
+	}
+
+	file, err := os.OpenFile(filepath.Join(dir, journalFileName), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &journal{runID: runID, dir: dir, file: file}, nil
+}
+
+// readJournal reads every entry of root's journal; an empty list when there is none
+func readJournal(root string) ([]journalEntry, error) {
+	file, err := os.Open(filepath.Join(root, journalDirName, journalFileName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []journalEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var entry journalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			// A run killed mid-write can leave a torn last line
+			fmt.Printf("⚠️  Skipping unreadable journal line %d: %v\n", line, err)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// record appends an entry under the run's ID and next sequence number
+func (j *journal) record(entry journalEntry) {
+	if j == nil {
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.seq++
+	entry.Run, entry.Seq, entry.Time = j.runID, j.seq, time.Now()
+	data, err := json.Marshal(entry)
+	if err == nil {
+		_, err = j.file.Write(append(data, '\n'))
+	}
+	if err != nil {
+		fmt.Printf("⚠️  Warning: Could not write to the journal: %v\n", err)
+	}
+}
+
+// recordFile records an operation on target along with target's current hash
+func (j *journal) recordFile(op, source, target, backup string) {
+	if j == nil {
+		return
+	}
+	entry := journalEntry{Op: op, Source: absPath(source), Target: absPath(target), Backup: backup}
+	if hash, err := calculateFileHash(target); err == nil {
+		entry.Hash = hash
+	}
+	j.record(entry)
+}
+
+// transferred records a file moved, copied or linked from source to target
+func (j *journal) transferred(mode, source, target string) {
+	j.recordFile(mode, source, target, "")
+}
+
+// renamed records a file renamed in place
+func (j *journal) renamed(source, target string) {
+	j.recordFile(journalRename, source, target, "")
+}
+
+// imported records a file skipped because the library already has the same content
+func (j *journal) imported(source, existing string) {
+	j.recordFile(journalImported, source, existing, "")
+}
+
+// backupPath reserves the next backup file of the run
+func (j *journal) backupPath() (string, error) {
+	j.mu.Lock()
+	j.backups++
+	n := j.backups
+	j.mu.Unlock()
+
+	dir := filepath.Join(j.dir, j.runID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	// Not a media extension, so library scans pass the backups by
+	return filepath.Join(dir, fmt.Sprintf("%06d.bak", n)), nil
+}
+
+// remove deletes path, keeping it as a backup so undo can restore it
+func (j *journal) remove(path string) error {
+	if j == nil {
+		return os.Remove(path)
+	}
+	hash, _ := calculateFileHash(path)
+	backup, err := j.backupPath()
+	if err != nil {
+		return err
+	}
+	if err := safeFileMove(path, backup); err != nil {
+		return err
+	}
+	j.record(journalEntry{Op: journalDelete, Target: absPath(path), Hash: hash, Backup: backup})
+	return nil
+}
+
+// edit runs write, which changes path in place or creates it, and records it. The previous
+// content is kept as a backup: a hard link when possible, since exiftool replaces files rather
+// than rewriting them
+func (j *journal) edit(path string, write func() error) error {
+	if j == nil {
+		return write()
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		if err := write(); err != nil {
+			return err
+		}
+		j.recordFile(journalCreate, "", path, "")
+		return nil
+	}
+
+	backup, err := j.backupPath()
+	if err == nil {
+		if linkErr := os.Link(path, backup); linkErr != nil {
+			err = copyFile(path, backup)
+		}
+	}
+	if err != nil {
+		fmt.Printf("⚠️  Warning: Could not back up %s, the edit cannot be undone: %v\n", filepath.Base(path), err)
+		return write()
+	}
+
+	if err := write(); err != nil {
+		os.Remove(backup)
+		return err
+	}
+	j.recordFile(journalEdit, "", path, backup)
+	return nil
+}
+
+// close ends the run and says how to undo it
+func (j *journal) close() {
+	if j == nil {
+		return
+	}
+	j.mu.Lock()
+	operations := j.seq - 1 // Not counting the run entry
+	j.mu.Unlock()
+	j.file.Close()
+
+	if operations > 0 {
+		fmt.Printf("📒 Journal: run %s recorded %d operation(s) - undo with: ./photo-meta undo %s %s\n",
+			j.runID, operations, filepath.Dir(j.dir), j.runID)
+	}
+}
+
+// absPath makes a journaled path absolute; "" stays empty
+func absPath(path string) string {
+	if path == "" {
+		return ""
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
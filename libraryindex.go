@@ -0,0 +1,250 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// libraryMatchDefaultWindowMinutes is how far in time a library photo may be from the file by default
+const libraryMatchDefaultWindowMinutes = 30
+
+// libraryMatchAgreementKm is the distance between the two neighbouring library photos at
+// which a match's confidence is halved
+const libraryMatchAgreementKm = 10.0
+
+// libraryPhoto is a GPS photo already in the destination
+type libraryPhoto struct {
+	Path    string    `json:"path"`
+	Time    time.Time `json:"time"` // UTC capture time
+	Lat     float64   `json:"lat"`
+	Lon     float64   `json:"lon"`
+	Indexed bool      `json:"indexed"` // False for files without GPS or capture time, remembered so they are not read again
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// libraryIndex holds the destination's GPS photos sorted by capture time
+type libraryIndex struct {
+	photos  []libraryPhoto
+	folders map[string][]libraryPhoto // Folder -> its photos, built on first use
+}
+
+// libraryMatch is the position a GPS-less file gets from the library
+type libraryMatch struct {
+	Lat, Lon     float64
+	Nearest      libraryPhoto  // Library photo closest in time
+	Gap          time.Duration // Time between the file and Nearest
+	Interpolated bool
+	Confidence   float64 // 0-1: 1 is taken at the same moment as a library photo
+}
+
+// getLibraryIndexPath returns the index cache file, stored next to the GPS cache database
+func getLibraryIndexPath() string {
+	return filepath.Join(filepath.Dir(getDefaultCachePath()), "photo-meta-library-index.json")
+}
+
+// buildLibraryIndex indexes every photo with GPS and a capture time under destPath. Files
+// unchanged since the last run are read from the index cache instead of being parsed again
+func buildLibraryIndex(destPath string) (*libraryIndex, error) {
+	cachePath := getLibraryIndexPath()
+	cached := make(map[string]libraryPhoto)
+	if data, err := os.ReadFile(cachePath); err == nil {
+		if err := json.Unmarshal(data, &cached); err != nil {
+			fmt.Printf("⚠️  Warning: Ignoring unreadable library index %s: %v\n", cachePath, err)
+			cached = make(map[string]libraryPhoto)
+		}
+	}
+
+	index := &libraryIndex{}
+	seen := make(map[string]bool)
+	read, reused := 0, 0
+	err := filepath.Walk(destPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !isMediaFile(path) {
+			return nil
+		}
+
+		absPath, err := filepath.Abs(path)
+		if err != nil {
+			absPath = path
+		}
+		seen[absPath] = true
+
+		entry, exists := cached[absPath]
+		if exists && entry.Size == info.Size() && entry.ModTime.Equal(info.ModTime()) {
+			reused++
+		} else {
+			read++
+			entry = libraryPhoto{Path: absPath, Size: info.Size(), ModTime: info.ModTime()}
+			if lat, lon, err := extractGPSCoordinates(path); err == nil {
+				if taken, err := extractPhotoDate(path); err == nil {
+					entry.Time, entry.Lat, entry.Lon, entry.Indexed = taken.UTC(), lat, lon, true
+				}
+			}
+			cached[absPath] = entry
+		}
+
+		if entry.Indexed {
+			index.photos = append(index.photos, entry)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Forget files that have left this destination; entries of other destinations are kept
+	absDest, _ := filepath.Abs(destPath)
+	for path := range cached {
+		if strings.HasPrefix(path, absDest+string(filepath.Separator)) && !seen[path] {
+			delete(cached, path)
+		}
+	}
+	if data, err := json.Marshal(cached); err == nil {
+		if err := os.WriteFile(cachePath, data, 0644); err != nil {
+			fmt.Printf("⚠️  Warning: Could not save library index: %v\n", err)
+		}
+	}
+
+	sort.Slice(index.photos, func(i, j int) bool {
+		return index.photos[i].Time.Before(index.photos[j].Time)
+	})
+	fmt.Printf("📚 Indexed %d GPS photos in the destination (%d read, %d unchanged)\n", len(index.photos), read, reused)
+	return index, nil
+}
+
+// match finds the position at the instant from the library photos within window of it.
+// Without interpolation the closest photo's position is used. Confidence falls linearly
+// with the time gap and halves when the two neighbours are libraryMatchAgreementKm apart
+func (idx *libraryIndex) match(instant time.Time, window time.Duration, interpolate bool) (libraryMatch, bool) {
+	photos := idx.photos
+	next := sort.Search(len(photos), func(i int) bool {
+		return !photos[i].Time.Before(instant)
+	})
+
+	var before, after *libraryPhoto
+	if next > 0 && instant.Sub(photos[next-1].Time) <= window {
+		before = &photos[next-1]
+	}
+	if next < len(photos) && photos[next].Time.Sub(instant) <= window {
+		after = &photos[next]
+	}
+	if before == nil && after == nil {
+		return libraryMatch{}, false
+	}
+
+	var result libraryMatch
+	switch {
+	case before == nil:
+		result.Nearest = *after
+	case after == nil:
+		result.Nearest = *before
+	case instant.Sub(before.Time) <= after.Time.Sub(instant):
+		result.Nearest = *before
+	default:
+		result.Nearest = *after
+	}
+	result.Gap = absDuration(instant.Sub(result.Nearest.Time))
+	result.Lat, result.Lon = result.Nearest.Lat, result.Nearest.Lon
+
+	agreement := 1.0
+	if before != nil && after != nil {
+		agreement = 1 / (1 + haversineKm(before.Lat, before.Lon, after.Lat, after.Lon)/libraryMatchAgreementKm)
+
+		if span := after.Time.Sub(before.Time); interpolate && span > 0 {
+			fraction := float64(instant.Sub(before.Time)) / float64(span)
+			result.Lat = before.Lat + (after.Lat-before.Lat)*fraction
+			result.Lon = before.Lon + (after.Lon-before.Lon)*fraction
+			result.Interpolated = true
+		}
+	}
+
+	result.Confidence = math.Max(0, 1-float64(result.Gap)/float64(window)) * agreement
+	return result, true
+}
+
+// libraryMatchWindow returns the configured matching window
+func libraryMatchWindow() time.Duration {
+	minutes := GetAppConfig().LibraryMatchWindowMinutes
+	if minutes <= 0 {
+		minutes = libraryMatchDefaultWindowMinutes
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// matchFromLibrary looks a GPS-less file up in the library by its capture time and returns
+// the destination location (relative to destPath, like the date database) and the match
+func (idx *libraryIndex) matchFromLibrary(path, destPath string) (string, string, libraryMatch, bool) {
+	if idx == nil || len(idx.photos) == 0 {
+		return "", "", libraryMatch{}, false
+	}
+
+	taken, err := extractPhotoDate(path)
+	if err != nil {
+		return "", "", libraryMatch{}, false
+	}
+
+	config := GetAppConfig()
+	match, found := idx.match(taken.UTC(), libraryMatchWindow(), config.LibraryMatchInterpolate)
+	if !found || match.Confidence < config.LibraryMatchMinConfidence {
+		return "", "", libraryMatch{}, false
+	}
+
+	var country, city string
+	if place := GetPlaces().match(match.Lat, match.Lon); place != nil {
+		country, city = place.Country, place.City
+	} else {
+		location, err := getLocationFromCoordinates(match.Lat, match.Lon)
+		if err != nil {
+			return "", "", libraryMatch{}, false
+		}
+		if country, city, err = parseLocation(location); err != nil {
+			return "", "", libraryMatch{}, false
+		}
+	}
+
+	// The photo folder the path template gives, as process would file it
+	location := layoutFolder(destPath, newLayoutFields("", taken, country, city))
+	return location, taken.Format("2006-01-02"), match, true
+}
+
+// closestInFolder returns the photo filed in folder (or its RAW subfolder) taken closest to the
+// instant, if one was taken within the window of it
+func (idx *libraryIndex) closestInFolder(folder string, instant time.Time, window time.Duration) (libraryPhoto, bool) {
+	if idx == nil {
+		return libraryPhoto{}, false
+	}
+	if idx.folders == nil {
+		idx.folders = make(map[string][]libraryPhoto)
+		for _, photo := range idx.photos {
+			dir := filepath.Dir(photo.Path)
+			if filepath.Base(dir) == rawSubfolderName {
+				dir = filepath.Dir(dir)
+			}
+			idx.folders[dir] = append(idx.folders[dir], photo)
+		}
+	}
+
+	if absFolder, err := filepath.Abs(folder); err == nil {
+		folder = absFolder
+	}
+	var closest libraryPhoto
+	found := false
+	for _, photo := range idx.folders[folder] {
+		if absDuration(instant.Sub(photo.Time)) > window {
+			continue
+		}
+		if !found || absDuration(instant.Sub(photo.Time)) < absDuration(instant.Sub(closest.Time)) {
+			closest, found = photo, true
+		}
+	}
+	return closest, found
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// livePhotoMaxTimeGap is how far apart the capture times of a still and a video
+// with the same base name may be for them to count as one Live Photo
+const livePhotoMaxTimeGap = 5 * time.Second
+
+// Placements for the video half of a Live Photo (config live_photo_video)
+const (
+	livePhotoVideoWithPhoto  = "with_photo"
+	livePhotoVideoVideoFiles = "video_files"
+)
+
+// isLivePhotoStill checks for the still formats a Live Photo can be exported as
+func isLivePhotoStill(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".heic", ".heif", ".jpg", ".jpeg":
+		return true
+	}
+	return false
+}
+
+// isLivePhotoVideo checks for the video formats a Live Photo can be exported as
+func isLivePhotoVideo(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".mov" || ext == ".mp4"
+}
+
+// pairLivePhotos finds Live Photos among paths and registers each video as a companion
+// of its still. It returns paths without the paired videos
+func pairLivePhotos(paths []string) []string {
+	stillsByDir := make(map[string][]string)
+	videosByDir := make(map[string][]string)
+	for _, path := range paths {
+		dir := filepath.Dir(path)
+		if isLivePhotoStill(path) {
+			stillsByDir[dir] = append(stillsByDir[dir], path)
+		} else if isLivePhotoVideo(path) {
+			videosByDir[dir] = append(videosByDir[dir], path)
+		}
+	}
+
+	pairedVideos := make(map[string]bool)
+	for dir, videos := range videosByDir {
+		stills := stillsByDir[dir]
+		if len(stills) == 0 {
+			continue
+		}
+
+		for still, video := range matchLivePhotos(stills, videos) {
+			registerCompanion(still, video)
+			pairedVideos[video] = true
+		}
+	}
+
+	if len(pairedVideos) > 0 {
+		fmt.Printf("🔗 Paired %d Live Photo(s) - each video will follow its photo\n", len(pairedVideos))
+	}
+	return removePairedPaths(paths, pairedVideos)
+}
+
+// matchLivePhotos pairs stills and videos from one directory, first by the Apple
+// ContentIdentifier and then by base name with capture times within livePhotoMaxTimeGap
+func matchLivePhotos(stills, videos []string) map[string]string {
+	pairs := make(map[string]string)
+	pairedVideos := make(map[string]bool)
+
+	// 1. ContentIdentifier - only worth reading the stills if a video carries one
+	videoByID := make(map[string]string)
+	for _, video := range videos {
+		if metadata, err := readMetadata(video); err == nil && metadata.ContentIdentifier != "" {
+			videoByID[metadata.ContentIdentifier] = video
+		}
+	}
+	if len(videoByID) > 0 {
+		for _, still := range stills {
+			metadata, err := readMetadata(still)
+			if err != nil || metadata.ContentIdentifier == "" {
+				continue
+			}
+			if video, exists := videoByID[metadata.ContentIdentifier]; exists && !pairedVideos[video] {
+				pairs[still] = video
+				pairedVideos[video] = true
+			}
+		}
+	}
+
+	// 2. Same base name (IMG_1234.HEIC + IMG_1234.MOV) taken at the same moment
+	stillsByBase := make(map[string][]string)
+	for _, still := range stills {
+		if _, paired := pairs[still]; !paired {
+			stillsByBase[mediaBaseName(still)] = append(stillsByBase[mediaBaseName(still)], still)
+		}
+	}
+	for _, video := range videos {
+		if pairedVideos[video] {
+			continue
+		}
+		for _, still := range stillsByBase[mediaBaseName(video)] {
+			if _, paired := pairs[still]; paired {
+				continue
+			}
+			if captureTimesMatch(still, video, livePhotoMaxTimeGap) {
+				pairs[still] = video
+				pairedVideos[video] = true
+				break
+			}
+		}
+	}
+
+	return pairs
+}
+
+// livePhotoVideoTarget names the video after the still's final path, placing it next to
+// the still or in the matching VIDEO-FILES folder depending on live_photo_video
+func livePhotoVideoTarget(videoPath, stillFinalPath, destBasePath string) string {
+	dir := filepath.Dir(stillFinalPath)
+
+	if GetAppConfig().LivePhotoVideo == livePhotoVideoVideoFiles {
+		// The path template's video folder for the still's: DEST/2023/Spain/madrid -> DEST/VIDEO-FILES/2023/Spain/madrid
+		if rel, err := filepath.Rel(destBasePath, dir); err == nil && !strings.HasPrefix(rel, "..") {
+			if values, ok := GetPathTemplate().parseFolder(rel); ok {
+				dir = filepath.Join(destBasePath, GetPathTemplate().folderFromValues(values, true))
+			} else {
+				dir = filepath.Join(destBasePath, videoFolderName, rel)
+			}
+		}
+	}
+
+	stillName := filepath.Base(stillFinalPath)
+	base := strings.TrimSuffix(stillName, filepath.Ext(stillName))
+	return filepath.Join(dir, base+filepath.Ext(videoPath))
+}
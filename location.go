@@ -8,12 +8,15 @@ import (
 	"sync"
 )
 
-//go:embed multi-word-countries.txt
+//go:embed multi-word-countries.txt country-codes.txt
 var countryListFS embed.FS
 
 var (
 	multiWordCountries []string
 	countryListOnce    sync.Once
+	
+	countryNames     map[string]string // ISO 3166-1 alpha-2 code -> English name
+	countryNamesOnce sync.Once
 )
 
 // loadMultiWordCountries loads the list of multi-word countries from the embedded file
@@ -42,6 +45,46 @@ func loadMultiWordCountries() {
 	})
 }
 
+// countryNameForCode returns the English country name for an ISO 3166-1 alpha-2 code, or "" if unknown
+func countryNameForCode(code string) string {
+	countryNamesOnce.Do(func() {
+		countryNames = make(map[string]string)
+		
+		file, err := countryListFS.Open("country-codes.txt")
+		if err != nil {
+			fmt.Printf("Warning: Could not load country code list: %v\n", err)
+			return
+		}
+		defer file.Close()
+		
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			if fields := strings.SplitN(line, "\t", 2); len(fields) == 2 {
+				countryNames[strings.ToUpper(fields[0])] = strings.TrimSpace(fields[1])
+			}
+		}
+	})
+	
+	return countryNames[strings.ToUpper(code)]
+}
+
+// countryCodeForName returns the ISO 3166-1 alpha-2 code for a country name or folder name, or "" if unknown
+func countryCodeForName(name string) string {
+	countryNameForCode("") // Load the list
+	
+	slug := countrySlug(name)
+	for code, countryName := range countryNames {
+		if countrySlug(countryName) == slug {
+			return code
+		}
+	}
+	return ""
+}
+
 // parseLocation attempts to extract country and city from a location string
 func parseLocation(location string) (country, city string, err error) {
 	// Load the multi-word countries list
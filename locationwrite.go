@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Provenance recorded in XMP-photometa:LocationSource
+const (
+	locationSourceLibraryMatch  = "library-match"  // datetime: a library photo taken within the match window
+	locationSourceDatetimeMatch = "datetime-match" // datetime: the destination folder of the same day
+	locationSourceFilename      = "filename"       // organize: the place named in the filename
+	locationSourceUser          = "user"           // fallback: entered at the prompt
+)
+
+// inferredLocation is where a command worked out a file was taken
+type inferredLocation struct {
+	HasGPS bool    `json:"has_gps"`
+	Lat    float64 `json:"lat,omitempty"`
+	Lon    float64 `json:"lon,omitempty"`
+
+	City        string `json:"city,omitempty"`
+	State       string `json:"state,omitempty"`
+	Country     string `json:"country,omitempty"`
+	CountryCode string `json:"country_code,omitempty"` // ISO 3166-1 alpha-2
+	Source      string `json:"source"`
+}
+
+// locationWriter writes inferred locations into the filed files in batches (--write-metadata)
+type locationWriter struct {
+	updater *BatchMetadataUpdater
+	dryRun  bool
+	planned int
+}
+
+// activeLocationWriter is set by --write-metadata on datetime, organize and fallback
+var activeLocationWriter *locationWriter
+
+// newLocationWriter creates a writer; in dry-run mode it only reports what it would write
+func newLocationWriter(dryRun bool) *locationWriter {
+	return &locationWriter{updater: NewBatchMetadataUpdater(0), dryRun: dryRun}
+}
+
+// add queues the location for a file at its final path; a nil writer does nothing
+func (w *locationWriter) add(path string, location inferredLocation) {
+	if w == nil || path == "" {
+		return
+	}
+	if activePlan.writeLocation(path, location) {
+		// Written by apply, once the file is where the plan puts it
+		return
+	}
+	if GetAppConfig().TransferMode == transferSymlink {
+		// Writing through the link would change the source
+		fmt.Printf("⚠️  Not writing location into %s: it is a symlink to the source\n", filepath.Base(path))
+		return
+	}
+
+	update := &PhotoBatchInfo{
+		FilePath:  path,
+		HasGPS:    location.HasGPS,
+		Latitude:  location.Lat,
+		Longitude: location.Lon,
+		Sidecar:   locationSidecar(path),
+	}
+	target := path
+	if update.Sidecar != "" {
+		target = update.Sidecar
+	}
+	if update.HasGPS && len(gpsWriteArgs(target, location.Lat, location.Lon)) == 0 {
+		update.HasGPS = false
+	}
+	update.Tags = locationTags(target, location)
+	w.planned++
+
+	if w.dryRun {
+		fmt.Printf("✏️  [DRY RUN] Would write %s to %s\n", describeInferredLocation(location), filepath.Base(target))
+		return
+	}
+	w.updater.AddUpdate(update)
+}
+
+// finish writes the remaining queued locations and reports the totals
+func (w *locationWriter) finish() {
+	if w == nil || w.planned == 0 {
+		return
+	}
+	if w.dryRun {
+		fmt.Printf("✏️  [DRY RUN] Would write locations into %d file(s)\n", w.planned)
+		return
+	}
+
+	fmt.Printf("\n✏️  Writing locations into %d file(s)...\n", w.planned)
+	w.updater.Flush(context.Background()) // Failures are reported per batch and counted in the stats
+	w.updater.PrintStats()
+	w.updater.Close()
+}
+
+// locationSidecar returns the XMP sidecar to write instead of the file: an existing one, which
+// editors read in preference to the file, or a new one for video containers exiftool cannot
+// write. "" writes the file itself
+func locationSidecar(path string) string {
+	for _, sidecar := range findSidecars(path) {
+		if strings.EqualFold(filepath.Ext(sidecar), ".xmp") {
+			return sidecar
+		}
+	}
+	if isVideoFile(path) && !isQuickTimeFile(path) {
+		return strings.TrimSuffix(path, filepath.Ext(path)) + ".xmp"
+	}
+	return ""
+}
+
+// locationTags builds the XMP (and, for images, IPTC) location and provenance tags
+func locationTags(target string, location inferredLocation) map[string]string {
+	tags := map[string]string{"XMP-photometa:LocationSource": location.Source}
+	iptc := !strings.EqualFold(filepath.Ext(target), ".xmp") && !isVideoFile(target)
+	if iptc {
+		tags["IPTC:CodedCharacterSet"] = "UTF8"
+	}
+
+	set := func(xmpTag, iptcTag, value string) {
+		if value == "" {
+			return
+		}
+		tags[xmpTag] = value
+		if iptc {
+			tags[iptcTag] = value
+		}
+	}
+	set("XMP-photoshop:City", "IPTC:City", location.City)
+	set("XMP-photoshop:State", "IPTC:Province-State", location.State)
+	set("XMP-photoshop:Country", "IPTC:Country-PrimaryLocationName", location.Country)
+	set("XMP-iptcCore:CountryCode", "IPTC:Country-PrimaryLocationCode", location.CountryCode)
+	return tags
+}
+
+// describeInferredLocation formats a location for dry-run output
+func describeInferredLocation(location inferredLocation) string {
+	var parts []string
+	for _, name := range []string{location.City, location.State, location.Country} {
+		if name != "" {
+			parts = append(parts, name)
+		}
+	}
+	description := strings.Join(parts, ", ")
+	if location.HasGPS {
+		description += fmt.Sprintf(" (%.6f, %.6f)", location.Lat, location.Lon)
+	}
+	return fmt.Sprintf("%s [%s]", description, location.Source)
+}
+
+// coordinateLocation names the place at the coordinates: a user-defined place, else the geocoder's answer
+func coordinateLocation(lat, lon float64, source string) inferredLocation {
+	location := inferredLocation{HasGPS: true, Lat: lat, Lon: lon, Source: source}
+	if p := GetPlaces().match(lat, lon); p != nil {
+		named := folderLocation(filepath.Join(p.Country, p.City), source)
+		location.City, location.Country, location.CountryCode = named.City, named.Country, named.CountryCode
+		return location
+	}
+
+	result, err := GetGeocoder().ReverseGeocode(lat, lon)
+	if err != nil {
+		return location
+	}
+	location.City = placeName(*result, granularityLocality)
+	location.State = result.State
+	location.Country = result.Country
+	location.CountryCode = strings.ToUpper(result.CountryCode)
+	if location.CountryCode == "" {
+		location.CountryCode = countryCodeForName(result.Country)
+	}
+	return location
+}
+
+// folderLocation names the place from a COUNTRY/CITY folder; a city folder named after the
+// country (country granularity) gives no city
+func folderLocation(folder, source string) inferredLocation {
+	city, country := filepath.Base(folder), filepath.Base(filepath.Dir(folder))
+	location := inferredLocation{Country: slugTitle(country), CountryCode: countryCodeForName(country), Source: source}
+	if name := countryNameForCode(location.CountryCode); name != "" {
+		location.Country = name
+	}
+	if countrySlug(city) != countrySlug(country) {
+		location.City = slugTitle(city)
+	}
+	return location
+}
+
+// datetimeLocation is the location a day match implies: the position of the photo in the matched
+// folder taken closest to the file when the library index has one within the library match window,
+// else only the folder's names, since a photo hours away says nothing about where the file was taken
+func datetimeLocation(db *DateLocationDB, path, destPath, location string) inferredLocation {
+	if taken, err := extractPhotoDate(path); err == nil {
+		if photo, found := db.Library.closestInFolder(filepath.Join(destPath, location), taken.UTC(), libraryMatchWindow()); found {
+			return coordinateLocation(photo.Lat, photo.Lon, locationSourceDatetimeMatch)
+		}
+	}
+	return folderLocation(location, locationSourceDatetimeMatch)
+}
+
+// slugTitle turns a folder name such as new-york back into New York
+func slugTitle(slug string) string {
+	words := strings.FieldsFunc(slug, func(r rune) bool {
+		return r == '-' || r == '_' || unicode.IsSpace(r)
+	})
+	for i, word := range words {
+		first, size := utf8.DecodeRuneInString(word)
+		words[i] = string(unicode.ToUpper(first)) + word[size:]
+	}
+	return strings.Join(words, " ")
+}
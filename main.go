@@ -44,7 +44,9 @@ func confirmOperation(command string, sourcePath, destPath string, dryRun bool,
 	// Check if this is a read-only command
 	isReadOnly := command == "summary" || command == "report"
 	
-	if dryRun {
+	if activePlan != nil {
+		fmt.Printf("Mode:    📝 PLAN (Nothing is changed - the plan is written to %s)\n", activePlan.path)
+	} else if dryRun {
 		if dryRunSampleSize > 0 {
 			fmt.Printf("Mode:    🔍 DRY RUN (Sample: %d files per type per directory)\n", dryRunSampleSize)
 		} else {
@@ -67,13 +69,35 @@ func confirmOperation(command string, sourcePath, destPath string, dryRun bool,
 }
 
 func main() {
+	// Strip global flags (--config, --timezone) before command parsing
+	args, err := extractGlobalFlags(os.Args)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	os.Args = args
+	
 	if len(os.Args) < 2 {
 		showUsage()
 		return
 	}
 
+	// A plan runs the planned command as a preview that records its transfers
+	if os.Args[1] == "plan" {
+		if err := startPlan(); err != nil {
+			fmt.Println("Usage: ./photo-metadata-editor plan <process|takeout|geotag|datetime|organize|fallback|merge> /source/path /destination/path [OPTIONS] [--output FILE]")
+			log.Fatal(err)
+		}
+		defer activePlan.save()
+	}
+	
 	command := os.Args[1]
 	
+	// Shut down any pooled exiftool processes on the way out
+	defer CloseExiftoolPool()
+	// Save the geocode answers not yet written by the run
+	defer flushGeocodeCache()
+	
 	switch command {
 	case "process":
 		if len(os.Args) < 4 {
@@ -180,13 +204,121 @@ func main() {
 			log.Fatalf("Failed to create destination path: %v", err)
 		}
 		
+		// Record every file operation so the run can be undone
+		startJournal("process", destPath, dryRun)
+		defer activeJournal.close()
+		
 		// Process photos concurrently with progress persistence
 		if err := processPhotosWithProgress(sourcePath, destPath, workers, dryRun, dryRunSampleSize, showProgress, generateInfo, resumeFromFile); err != nil {
 			log.Fatal(err)
 		}
 		
 		// Clean up empty directories after processing
-		cleanupEmptyDirectoriesIfNeeded("process", sourcePath, dryRun, -1) // -1 means always run cleanup
+		cleanupSourceIfMoved("process", sourcePath, dryRun)
+		
+		// Generate info directory summary file if requested
+		if generateInfo && !dryRun {
+			fmt.Printf("\n📋 Generating PhotoXX-style directory summary...\n")
+			if err := generateInfoDirectorySummary(destPath, ""); err != nil {
+				fmt.Printf("⚠️  Warning: Failed to generate info directory summary: %v\n", err)
+			} else {
+				fmt.Printf("✅ Info directory summary generated successfully\n")
+			}
+		}
+		
+	case "takeout":
+		if len(os.Args) < 4 {
+			fmt.Println("Usage: ./photo-metadata-editor takeout /takeout/path /destination/path [--workers N] [--dry-run [N]] [--progress] [--info] [--write-metadata]")
+			os.Exit(1)
+		}
+		
+		sourcePath := os.Args[2]
+		destPath := os.Args[3]
+		
+		// Check for incorrectly formatted dry-run arguments
+		for i := 4; i < len(os.Args); i++ {
+			arg := strings.ToLower(os.Args[i])
+			if strings.Contains(arg, "dry") && strings.Contains(arg, "run") && arg != "--dry-run" {
+				fmt.Printf("Error: Invalid argument format '%s'\n", os.Args[i])
+				fmt.Println("Use '--dry-run [N]' instead")
+				os.Exit(1)
+			}
+		}
+		
+		// Parse optional flags
+		workers := 4 // Default worker count
+		dryRun := false
+		dryRunSampleSize := 0
+		showProgress := true // Default to showing progress
+		generateInfo := false // Generate info_ directory summary file
+		writeMetadata := false // Write Takeout GPS and dates into the files themselves
+		for i := 4; i < len(os.Args); i++ {
+			switch os.Args[i] {
+			case "--workers":
+				if i+1 < len(os.Args) {
+					if _, err := fmt.Sscanf(os.Args[i+1], "%d", &workers); err != nil {
+						log.Fatalf("Invalid worker count: %s", os.Args[i+1])
+					}
+					i++ // Skip the next argument since it's the worker count
+				}
+			case "--dry-run":
+				dryRun = true
+				dryRunSampleSize = 0 // Process all files for preview
+				// Check if next argument is a number
+				if i+1 < len(os.Args) && !strings.HasPrefix(os.Args[i+1], "--") {
+					if size, err := strconv.Atoi(os.Args[i+1]); err == nil && size > 0 {
+						dryRunSampleSize = size
+						i++ // Skip the next argument since it's the sample size
+					}
+				}
+			case "--progress":
+				showProgress = true
+			case "--no-progress":
+				showProgress = false
+			case "--info":
+				generateInfo = true
+			case "--write-metadata":
+				writeMetadata = true
+			}
+		}
+		
+		// Ask for user confirmation
+		if !confirmOperation("takeout", sourcePath, destPath, dryRun, dryRunSampleSize) {
+			fmt.Println("❌ Operation cancelled by user.")
+			os.Exit(0)
+		}
+		
+		// Check if source path exists
+		if _, err := os.Stat(sourcePath); os.IsNotExist(err) {
+			log.Fatalf("Source path does not exist: %s", sourcePath)
+		}
+		
+		// Create destination path if it doesn't exist
+		if err := os.MkdirAll(destPath, 0755); err != nil {
+			log.Fatalf("Failed to create destination path: %v", err)
+		}
+		
+		// Record every file operation so the run can be undone
+		startJournal("takeout", destPath, dryRun)
+		defer activeJournal.close()
+		
+		// Takeout strips GPS and dates from many files and keeps them in <name>.json instead
+		takeoutMetadataEnabled = true
+		
+		if writeMetadata {
+			fmt.Printf("✏️  Writing Takeout GPS and dates into the files...\n")
+			if err := writeTakeoutMetadata(sourcePath, dryRun); err != nil {
+				log.Fatal(err)
+			}
+		}
+		
+		// Run the normal process pipeline with the Takeout JSON as a metadata source
+		if err := processPhotosWithProgress(sourcePath, destPath, workers, dryRun, dryRunSampleSize, showProgress, generateInfo, ""); err != nil {
+			log.Fatal(err)
+		}
+		
+		// Clean up empty directories after processing
+		cleanupSourceIfMoved("process", sourcePath, dryRun)
 		
 		// Generate info directory summary file if requested
 		if generateInfo && !dryRun {
@@ -200,7 +332,7 @@ func main() {
 		
 	case "organize":
 		if len(os.Args) < 4 {
-			fmt.Println("Usage: ./photo-metadata-editor organize /source/path /destination/path [--workers N] [--dry-run [N]] [--progress] [--info]")
+			fmt.Println("Usage: ./photo-metadata-editor organize /source/path /destination/path [--workers N] [--dry-run [N]] [--progress] [--info] [--write-metadata]")
 			os.Exit(1)
 		}
 		
@@ -223,6 +355,7 @@ func main() {
 		dryRunSampleSize := 0
 		showProgress := true // Default to showing progress
 		generateInfo := false // Generate info_ directory summary file
+		writeMetadata := false // Write the inferred locations into the files
 		for i := 4; i < len(os.Args); i++ {
 			switch os.Args[i] {
 			case "--workers":
@@ -248,6 +381,8 @@ func main() {
 				showProgress = false
 			case "--info":
 				generateInfo = true
+			case "--write-metadata":
+				writeMetadata = true
 			}
 		}
 		
@@ -267,13 +402,22 @@ func main() {
 			log.Fatalf("Failed to create destination path: %v", err)
 		}
 		
+		// Record every file operation so the run can be undone
+		startJournal("organize", destPath, dryRun)
+		defer activeJournal.close()
+		
+		if writeMetadata {
+			activeLocationWriter = newLocationWriter(dryRun)
+		}
+		
 		// Process location-based organization
 		if err := processOrganizeByLocation(sourcePath, destPath, dryRun, dryRunSampleSize, showProgress); err != nil {
 			log.Fatal(err)
 		}
+		activeLocationWriter.finish()
 		
 		// Clean up empty directories after organize processing
-		cleanupEmptyDirectoriesIfNeeded("organize", sourcePath, dryRun, -1)
+		cleanupSourceIfMoved("organize", sourcePath, dryRun)
 		
 		// Generate info directory summary file if requested
 		if generateInfo && !dryRun {
@@ -287,7 +431,7 @@ func main() {
 		
 	case "fallback":
 		if len(os.Args) < 4 {
-			fmt.Println("Usage: ./photo-metadata-editor fallback /source/path /destination/path [--workers N] [--dry-run [N]] [--progress] [--info]")
+			fmt.Println("Usage: ./photo-metadata-editor fallback /source/path /destination/path [--workers N] [--dry-run [N]] [--progress] [--info] [--write-metadata]")
 			os.Exit(1)
 		}
 		
@@ -310,6 +454,7 @@ func main() {
 		dryRunSampleSize := 0
 		showProgress := true // Default to showing progress
 		generateInfo := false // Generate info_ directory summary file
+		writeMetadata := false // Write the inferred locations into the files
 		for i := 4; i < len(os.Args); i++ {
 			switch os.Args[i] {
 			case "--workers":
@@ -335,6 +480,8 @@ func main() {
 				showProgress = false
 			case "--info":
 				generateInfo = true
+			case "--write-metadata":
+				writeMetadata = true
 			}
 		}
 		
@@ -354,13 +501,22 @@ func main() {
 			log.Fatalf("Failed to create destination path: %v", err)
 		}
 		
+		// Record every file operation so the run can be undone
+		startJournal("fallback", destPath, dryRun)
+		defer activeJournal.close()
+		
+		if writeMetadata {
+			activeLocationWriter = newLocationWriter(dryRun)
+		}
+		
 		// Process fallback organization
 		if err := processFallbackOrganization(sourcePath, destPath, dryRun, dryRunSampleSize, showProgress); err != nil {
 			log.Fatal(err)
 		}
+		activeLocationWriter.finish()
 		
 		// Clean up empty directories after fallback processing
-		cleanupEmptyDirectoriesIfNeeded("fallback", sourcePath, dryRun, -1)
+		cleanupSourceIfMoved("fallback", sourcePath, dryRun)
 		
 		// Generate info directory summary file if requested
 		if generateInfo && !dryRun {
@@ -374,7 +530,7 @@ func main() {
 		
 	case "datetime":
 		if len(os.Args) < 4 {
-			fmt.Println("Usage: ./photo-metadata-editor datetime /source/path /destination/path [--workers N] [--dry-run [N]] [--progress] [--info] [--reset-db]")
+			fmt.Println("Usage: ./photo-metadata-editor datetime /source/path /destination/path [--workers N] [--dry-run [N]] [--progress] [--info] [--reset-db] [--match-window MIN] [--interpolate] [--no-library-match] [--write-metadata]")
 			os.Exit(1)
 		}
 		
@@ -397,6 +553,7 @@ func main() {
 		dryRunSampleSize := 0
 		showProgress := true // Default to showing progress
 		generateInfo := false // Generate info_ directory summary file
+		writeMetadata := false // Write the inferred locations into the files
 		resetDB := false // Reset GPS cache database
 		for i := 4; i < len(os.Args); i++ {
 			switch os.Args[i] {
@@ -425,6 +582,17 @@ func main() {
 				generateInfo = true
 			case "--reset-db":
 				resetDB = true
+			case "--match-window":
+				if i+1 < len(os.Args) {
+					configOverrides["library_match_window_minutes"] = os.Args[i+1]
+					i++ // Skip the next argument since it's the window in minutes
+				}
+			case "--interpolate":
+				configOverrides["library_match_interpolate"] = "true"
+			case "--no-library-match":
+				configOverrides["disable_library_match"] = "true"
+			case "--write-metadata":
+				writeMetadata = true
 			}
 		}
 		
@@ -444,6 +612,10 @@ func main() {
 			log.Fatalf("Destination path does not exist: %s", destPath)
 		}
 		
+		// Record every file operation so the run can be undone
+		startJournal("datetime", destPath, dryRun)
+		defer activeJournal.close()
+		
 		// Initialize GPS cache
 		if err := InitGPSCache(); err != nil {
 			log.Fatalf("Failed to initialize GPS cache: %v", err)
@@ -462,13 +634,18 @@ func main() {
 			}
 		}
 		
+		if writeMetadata {
+			activeLocationWriter = newLocationWriter(dryRun)
+		}
+		
 		// Process datetime matching
 		if err := processDateTimeMatching(sourcePath, destPath, dryRun, dryRunSampleSize, showProgress); err != nil {
 			log.Fatal(err)
 		}
+		activeLocationWriter.finish()
 		
 		// Clean up empty directories after datetime processing
-		cleanupEmptyDirectoriesIfNeeded("datetime", sourcePath, dryRun, -1)
+		cleanupSourceIfMoved("datetime", sourcePath, dryRun)
 		
 		// Generate info directory summary file if requested
 		if generateInfo && !dryRun {
@@ -544,6 +721,10 @@ func main() {
 			os.Exit(0)
 		}
 		
+		// Record every file operation so the run can be undone
+		startJournal("clean", targetPath, dryRun)
+		defer activeJournal.close()
+		
 		// Process clean (duplicate removal)
 		if err := processClean(targetPath, dryRun, dryRunSampleSize, verbose, workers, showProgress); err != nil {
 			log.Fatal(err)
@@ -618,11 +799,50 @@ func main() {
 			log.Fatalf("Target path does not exist: %s", targetPath)
 		}
 		
+		// Record every file operation so the run can be undone
+		startJournal("merge", targetPath, dryRun)
+		defer activeJournal.close()
+		
 		// Process merge
 		if err := processMerge(sourcePath, targetPath, workers, dryRun, dryRunSampleSize, showProgress); err != nil {
 			log.Fatal(err)
 		}
 		
+	case "geotag":
+		// Place photos without GPS on GPX/KML/GeoJSON track logs
+		if err := runGeotagCommand(os.Args[2:]); err != nil {
+			fmt.Println("Usage: ./photo-metadata-editor geotag /source/path [/destination/path] --track FILE [--track FILE ...] [--max-gap 15m] [--clock-offset +2m30s] [--camera-timezone ZONE] [--write-metadata] [--dry-run [N]] [--workers N]")
+			log.Fatal(err)
+		}
+		
+	case "timeshift":
+		// Correct one camera's capture times by a fixed or estimated clock offset
+		if err := runTimeshiftCommand(os.Args[2:]); err != nil {
+			fmt.Println("Usage: ./photo-metadata-editor timeshift /target/path (--offset D | --estimate /phone/path | --pair CAMERA PHONE) [--make MAKE] [--model MODEL] [--serial SERIAL] [--from YYYY-MM-DD] [--to YYYY-MM-DD] [--no-rename] [--dry-run [N]]")
+			log.Fatal(err)
+		}
+		
+	case "apply":
+		// Carry out a plan file written by plan
+		if err := runApplyCommand(os.Args[2:]); err != nil {
+			fmt.Println("Usage: ./photo-metadata-editor apply PLAN-FILE [--dry-run]")
+			log.Fatal(err)
+		}
+		
+	case "undo":
+		// List the journaled runs of a destination or reverse one of them
+		if err := runUndoCommand(os.Args[2:]); err != nil {
+			fmt.Println("Usage: ./photo-metadata-editor undo /destination/path [RUN-ID] [--only PATH] [--last N] [--dry-run]")
+			log.Fatal(err)
+		}
+		
+	case "geocache":
+		// Show or invalidate the persistent reverse geocoding cache
+		if err := runGeocacheCommand(os.Args[2:]); err != nil {
+			fmt.Printf("Usage: ./photo-metadata-editor geocache [stats | clear [LAT LON]]\n")
+			log.Fatal(err)
+		}
+		
 	case "summary":
 		if len(os.Args) < 3 {
 			fmt.Println("Usage: ./photo-metadata-editor summary /source/path")
@@ -775,6 +995,10 @@ func main() {
 			os.Exit(0)
 		}
 
+		// Record every file operation so the run can be undone
+		startJournal("tiff", targetPath, dryRun)
+		defer activeJournal.close()
+
 		// Process tiff timestamp fixes
 		if err := processTiffTimestampFix(targetPath, workers, dryRun, dryRunSampleSize, showProgress); err != nil {
 			log.Fatal(err)
@@ -818,6 +1042,10 @@ func main() {
 			os.Exit(0)
 		}
 		
+		// Record every file operation so the run can be undone
+		startJournal("cleanup", targetPath, dryRun)
+		defer activeJournal.close()
+		
 		// Run cleanup
 		fmt.Printf("🧹 Standalone Empty Directory Cleanup\n")
 		fmt.Printf("🔍 Target: %s\n", targetPath)
@@ -1118,14 +1346,21 @@ func showUsage() {
 	fmt.Println()
 	fmt.Println("Commands:")
 	fmt.Println("  ./photo-metadata-editor process /source/path /destination/path [--workers N] [--dry-run [N]] [--progress] [--info] [--resume FILE]")
-	fmt.Println("  ./photo-metadata-editor organize /source/path /destination/path [--workers N] [--dry-run [N]] [--progress] [--info]")
-	fmt.Println("  ./photo-metadata-editor datetime /source/path /destination/path [--workers N] [--dry-run [N]] [--progress] [--info] [--reset-db]")
-	fmt.Println("  ./photo-metadata-editor fallback /source/path /destination/path [--workers N] [--dry-run [N]] [--progress] [--info]")
+	fmt.Println("  ./photo-metadata-editor takeout /takeout/path /destination/path [--workers N] [--dry-run [N]] [--progress] [--info] [--write-metadata]")
+	fmt.Println("  ./photo-metadata-editor organize /source/path /destination/path [--workers N] [--dry-run [N]] [--progress] [--info] [--write-metadata]")
+	fmt.Println("  ./photo-metadata-editor datetime /source/path /destination/path [--workers N] [--dry-run [N]] [--progress] [--info] [--reset-db] [--match-window MIN] [--interpolate] [--no-library-match] [--write-metadata]")
+	fmt.Println("  ./photo-metadata-editor fallback /source/path /destination/path [--workers N] [--dry-run [N]] [--progress] [--info] [--write-metadata]")
 	fmt.Println("  ./photo-metadata-editor tiff /target/path [--dry-run [N]] [--workers N] [--progress]")
 	fmt.Println("  ./photo-metadata-editor clean /target/path [--dry-run [N]] [--verbose] [--workers N] [--progress]")
 	fmt.Println("  ./photo-metadata-editor cleanup /target/path [--dry-run [N]]")
 	fmt.Println("  ./photo-metadata-editor merge /source/path /target/path [--workers N] [--dry-run [N]] [--progress]")
 	fmt.Println("  ./photo-metadata-editor summary /source/path")
+	fmt.Println("  ./photo-metadata-editor geotag /source/path [/destination/path] --track FILE [--max-gap 15m] [--clock-offset +2m30s] [--camera-timezone ZONE] [--write-metadata] [--dry-run [N]]")
+	fmt.Println("  ./photo-metadata-editor timeshift /target/path (--offset D | --estimate /phone/path | --pair CAMERA PHONE) --make MAKE [--model MODEL] [--serial SERIAL] [--from DATE] [--to DATE] [--dry-run [N]]")
+	fmt.Println("  ./photo-metadata-editor plan <command> /source/path /destination/path [OPTIONS] [--output FILE]")
+	fmt.Println("  ./photo-metadata-editor apply PLAN-FILE [--dry-run]")
+	fmt.Println("  ./photo-metadata-editor undo /destination/path [RUN-ID] [--only PATH] [--last N] [--dry-run]")
+	fmt.Println("  ./photo-metadata-editor geocache [stats | clear [LAT LON]]")
 	fmt.Println("  ./photo-metadata-editor report <type> /source/path [--save] [--progress] [--verbose]")
 	fmt.Println()
 	fmt.Println("Report Types:")
@@ -1142,6 +1377,42 @@ func showUsage() {
 	fmt.Println("  --info         Generate PhotoXX-style info_ directory summary file")
 	fmt.Println("  --resume FILE  Resume from a previous interrupted operation")
 	fmt.Println("  --reset-db     Clear the GPS cache database (for datetime command)")
+	fmt.Println("  --match-window MIN  Use GPS photos in the destination taken within MIN minutes (default: 30, for datetime command)")
+	fmt.Println("  --interpolate       Place files between the library photos before and after them (for datetime command)")
+	fmt.Println("  --no-library-match  Match by day only (for datetime command)")
+	fmt.Println("  --write-metadata  Write Takeout JSON GPS and dates into the files (for takeout command)")
+	fmt.Println("                    or track positions (for geotag command)")
+	fmt.Println("                    or the inferred GPS, location names and their source (for datetime, organize and fallback)")
+	fmt.Println("  --track FILE      GPX, KML or GeoJSON track log, repeatable (for geotag command)")
+	fmt.Println("  --max-gap DUR     Furthest a photo may be from a track point in time (default: 15m, for geotag command)")
+	fmt.Println("  --clock-offset D  How far the camera clock was ahead, e.g. +2m30s or -01:00:00 (for geotag command)")
+	fmt.Println("  --camera-timezone ZONE  Zone the camera clock was set to (default: --timezone, for geotag command)")
+	fmt.Println("  --offset D        Add D to the camera's times, e.g. +1h, -9h or +1d2h (for timeshift command)")
+	fmt.Println("  --estimate PATH   Estimate the offset from phone photos of the same scenes (for timeshift command)")
+	fmt.Println("  --pair CAM PHONE  Take the offset from a camera photo and a phone photo of the same moment (for timeshift command)")
+	fmt.Println("  --make/--model/--serial  Camera to shift, matched as case-insensitive text (for timeshift command)")
+	fmt.Println("  --from/--to DATE  Only shift files the camera dated within YYYY-MM-DD..YYYY-MM-DD (for timeshift command)")
+	fmt.Println("  --no-rename       Keep filenames unchanged (for timeshift command)")
+	fmt.Println("  --only PATH       Only undo operations on files in PATH, repeatable (for undo command)")
+	fmt.Println("  --last N          Only undo the run's last N operations (for undo command)")
+	fmt.Println("  --output FILE     Plan file to write (default: photo-meta-plan-DATE-TIME.json, for plan command)")
+	fmt.Println()
+	fmt.Println("Global Options (any command):")
+	fmt.Println("  --config FILE            Settings file (default: photo-meta-config.json)")
+	fmt.Println("  --timezone ZONE          Default zone for capture times without offset or GPS time (e.g. Europe/Madrid)")
+	fmt.Println("  --live-photo-video MODE  Live Photo MOV placement: with_photo (default) or video_files")
+	fmt.Println("  --raw-pair POLICY        RAW+JPEG pairs: together (default), raw_subfolder or separate")
+	fmt.Println("  --path-template T        Library layout, e.g. {year}/{country}/{city}/{date}[-{time}]-{city}{ext}")
+	fmt.Println("  --transfer MODE          move, copy, hardlink, symlink or reflink (default: move, copy for merge)")
+	fmt.Println("  --duplicates ACTION      Files already in the library: skip (leave in the source, default) or delete")
+	fmt.Println("  --no-journal             Do not record the run in the destination's journal (it cannot be undone)")
+	fmt.Println("  --places FILE            User-defined places filed under a fixed country/city before geocoding")
+	fmt.Println("  --granularity LEVEL      Name folders after the locality (default), county, state or country")
+	fmt.Println("  --merge-small-places N   File a locality under its county when fewer than N photos land there")
+	fmt.Println("  --geocoder LIST          Reverse geocoders to try in order: offline, gazetteer, geonames, nominatim (default: offline,nominatim)")
+	fmt.Println("  --geonames FILE          GeoNames dump for offline reverse geocoding (e.g. cities1000.txt)")
+	fmt.Println("  --country-boundaries F   GeoJSON country polygons that decide the country before the city")
+	fmt.Println("  --nominatim-url URL      Nominatim server (default: https://nominatim.openstreetmap.org)")
 	fmt.Println()
 	fmt.Println("Process Features:")
 	fmt.Println("  - 🚀 Concurrent processing with configurable worker pools")
@@ -1293,6 +1564,9 @@ func processPhotosConcurrently(sourcePath, destPath string, workers int, dryRun
 		return err
 	}
 	
+	// RAW+JPEG pairs and Live Photo videos are moved with their primary file rather than as separate jobs
+	jobs = pairCompanionJobs(jobs)
+	
 	if len(jobs) == 0 {
 		fmt.Println("📭 No media files found to process")
 		return nil
@@ -1310,6 +1584,11 @@ func processPhotosConcurrently(sourcePath, destPath string, workers int, dryRun
 	
 	fmt.Printf("📝 Found %d media files to process (%d photos, %d videos)\n", len(jobs), photoCount, videoCount)
 	
+	// Small places can only be told apart once every file has been counted, so samples are not rolled up
+	if dryRunSampleSize == 0 {
+		countSmallPlaces(jobs, workers)
+	}
+	
 	// Process jobs concurrently
 	return processJobsConcurrentlyWithProgress(jobs, workers, showProgress)
 }
@@ -1426,25 +1705,38 @@ func processMediaFileInternal(mediaPath, destBasePath string, dryRun bool) error
 		return &NoGPSError{File: mediaPath, Err: err}
 	}
 	
+	// A user-defined place wins over any geocoder lookup
+	place := GetPlaces().match(lat, lon)
+	
 	// Get location from coordinates
-	location, err := getLocationFromCoordinates(lat, lon)
-	if err != nil {
-		return fmt.Errorf("failed to get location for %s: %v", filepath.Base(mediaPath), err)
+	var location string
+	if place != nil {
+		location = place.location()
+		fmt.Printf("🏠 Place: %s → %s/%s (%.6f, %.6f)\n", place.Name, place.Country, place.City, lat, lon)
+	} else {
+		location, err = getLocationFromCoordinates(lat, lon)
+		if err != nil {
+			return fmt.Errorf("failed to get location for %s: %v", filepath.Base(mediaPath), err)
+		}
+		
+		fmt.Printf("📍 Location: %s (%.6f, %.6f)\n", location, lat, lon)
 	}
 	
-	fmt.Printf("📍 Location: %s (%.6f, %.6f)\n", location, lat, lon)
-	
 	// Extract date from media file
 	date, err := extractPhotoDate(mediaPath)
 	if err != nil {
 		return fmt.Errorf("failed to extract date from %s: %v", filepath.Base(mediaPath), err)
 	}
 	
-	// Parse location into country and city
-	country, city, err := parseLocation(location)
-	if err != nil {
+	// Parse location into country and city; a place names both directly
+	var country, city string
+	reason := fmt.Sprintf("GPS %.6f, %.6f is in %s", lat, lon, location)
+	if place != nil {
+		country, city = place.Country, place.City
+		reason = fmt.Sprintf("GPS %.6f, %.6f is in place %s", lat, lon, place.Name)
+	} else if country, city, err = parseLocation(location); err != nil {
 		// Skip prompting in dry run mode
-		if dryRun {
+		if !promptsAllowed(dryRun) {
 			country = "unknown-country"
 			city = "unknown-city"
 		} else {
@@ -1453,71 +1745,40 @@ func processMediaFileInternal(mediaPath, destBasePath string, dryRun bool) error
 			if err != nil {
 				return fmt.Errorf("failed to get location information: %v", err)
 			}
+			reason = fmt.Sprintf("GPS %.6f, %.6f, location entered at the prompt", lat, lon)
 		}
 	}
 	
-	// Generate new filename and directory structure using destination base path
-	// Use helper function to preserve existing hour+minute if present
-	newFilename := generateFilenameWithTime(mediaPath, date, city)
-	
-	// Smart directory structure - check if destination already ends with the year
-	year := date.Format("2006")
-	var newDir string
+	// The path template gives the folder and name (keeping an existing hour+minute); a destination
+	// that already ends with the year (e.g., "/tmp/2025") gets no year folder
+	fields := newLayoutFields(mediaPath, date, country, city)
+	fields.Reason = reason
+	newDir := filepath.Join(destBasePath, layoutFolder(destBasePath, fields))
+	mode := transferModeFor(transferMove)
 	
-	// Check if destination path already ends with the year
-	destBase := filepath.Base(destBasePath)
-	
-	// For video files, place in VIDEO-FILES subdirectory
-	if isVideoFile(mediaPath) {
-		if destBase == year {
-			// Destination already ends with year (e.g., "/tmp/2025"), so add VIDEO-FILES/country/city
-			newDir = filepath.Join(destBasePath, "VIDEO-FILES", country, city)
-		} else {
-			// Destination doesn't end with year, so add VIDEO-FILES/year/country/city
-			newDir = filepath.Join(destBasePath, "VIDEO-FILES", year, country, city)
-		}
-	} else {
-		// For photo files, use regular structure
-		if destBase == year {
-			// Destination already ends with year (e.g., "/tmp/2025"), so just add country/city
-			newDir = filepath.Join(destBasePath, country, city)
-		} else {
-			// Destination doesn't end with year, so add full structure
-			newDir = filepath.Join(destBasePath, year, country, city)
-		}
-	}
-	
-	newPath := filepath.Join(newDir, newFilename)
+	sidecars := findSidecars(mediaPath)
 	
 	// In dry run mode, just show what would happen
 	if dryRun {
 		// Create directory structure if it doesn't exist (for simulation)
 		return WithBatchLocks([]string{newDir}, func() error {
 			// Simulate duplicate handling
-			finalPath := newPath
-			counter := 1
-			for {
-				if _, err := os.Stat(finalPath); os.IsNotExist(err) {
-					break // File doesn't exist, we can use this path
-				}
-				
-				// File exists, create a new name with counter
-				ext := filepath.Ext(newFilename)
-				base := strings.TrimSuffix(newFilename, ext)
-				duplicateFilename := fmt.Sprintf("%s-%d%s", base, counter, ext)
-				finalPath = filepath.Join(newDir, duplicateFilename)
-				counter++
-				
-				if counter > 1000 {
-					return fmt.Errorf("too many duplicate filenames, stopping at counter %d", counter)
-				}
+			finalPath, err := layoutDestination(destBasePath, fields)
+			if dup, ok := err.(*DuplicateContentError); ok {
+				importedDuplicate(dup, destBasePath, true)
+				return nil
+			}
+			if err != nil {
+				return err
 			}
 			
 			if isVideoFile(mediaPath) {
-				fmt.Printf("✅ [DRY RUN] Video would be moved to: %s\n", finalPath)
+				fmt.Printf("✅ [DRY RUN] Video would be %s to: %s\n", transferVerb(mode), finalPath)
 			} else {
-				fmt.Printf("✅ [DRY RUN] Photo would be moved to: %s\n", finalPath)
+				fmt.Printf("✅ [DRY RUN] Photo would be %s to: %s\n", transferVerb(mode), finalPath)
 			}
+			activePlan.addFiled(mediaPath, finalPath, mode, fields)
+			transferCompanions(sidecars, mediaPath, finalPath, destBasePath, true, mode)
 			return nil
 		})
 	}
@@ -1528,40 +1789,31 @@ func processMediaFileInternal(mediaPath, destBasePath string, dryRun bool) error
 			return fmt.Errorf("failed to create directory %s: %v", newDir, err)
 		}
 		
-		// Handle duplicate filenames by adding a counter
-		finalPath := newPath
-		counter := 1
-		for {
-			if _, err := os.Stat(finalPath); os.IsNotExist(err) {
-				break // File doesn't exist, we can use this path
-			}
-			
-			// File exists, create a new name with counter
-			ext := filepath.Ext(newFilename)
-			base := strings.TrimSuffix(newFilename, ext)
-			duplicateFilename := fmt.Sprintf("%s-%d%s", base, counter, ext)
-			finalPath = filepath.Join(newDir, duplicateFilename)
-			counter++
-			
-			if counter > 1000 {
-				return fmt.Errorf("too many duplicate filenames, stopping at counter %d", counter)
-			}
+		// Handle duplicate filenames: skip identical content, give a different file a hash suffix
+		finalPath, err := layoutDestination(destBasePath, fields)
+		if dup, ok := err.(*DuplicateContentError); ok {
+			importedDuplicate(dup, destBasePath, false)
+			return nil
+		}
+		if err != nil {
+			return err
 		}
 		
-		// Move/rename the file with enhanced permission handling
-		if err := safeFileMove(mediaPath, finalPath); err != nil {
+		// Move/rename (or copy or link) the file with enhanced permission handling
+		if err := transferFile(mediaPath, finalPath, mode); err != nil {
 			if permErr, ok := err.(*PermissionError); ok {
 				handlePermissionError(permErr, true)
 				return fmt.Errorf("permission error moving file from %s to %s", mediaPath, finalPath)
 			}
-			return fmt.Errorf("failed to move file from %s to %s: %v", mediaPath, finalPath, err)
+			return fmt.Errorf("failed to %s file from %s to %s: %v", mode, mediaPath, finalPath, err)
 		}
 		
 		if isVideoFile(mediaPath) {
-			fmt.Printf("✅ Video moved to: %s\n", finalPath)
+			fmt.Printf("✅ Video %s to: %s\n", transferVerb(mode), finalPath)
 		} else {
-			fmt.Printf("✅ Photo moved to: %s\n", finalPath)
+			fmt.Printf("✅ Photo %s to: %s\n", transferVerb(mode), finalPath)
 		}
+		transferCompanions(sidecars, mediaPath, finalPath, destBasePath, false, mode)
 		return nil
 	})
 }
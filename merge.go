@@ -64,6 +64,9 @@ func processMerge(sourcePath, targetPath string, workers int, dryRun bool, dryRu
 		return err
 	}
 
+	// RAW+JPEG pairs and Live Photo videos are merged with their primary file rather than as separate jobs
+	jobs = pairCompanionJobs(jobs)
+
 	if len(jobs) == 0 {
 		fmt.Println("📭 No media files found to merge")
 		return nil
@@ -81,6 +84,11 @@ func processMerge(sourcePath, targetPath string, workers int, dryRun bool, dryRu
 
 	fmt.Printf("📝 Found %d media files to merge (%d photos, %d videos)\n", len(jobs), photoCount, videoCount)
 
+	// Small places can only be told apart once every file has been counted, so samples are not rolled up
+	if dryRunSampleSize == 0 {
+		countSmallPlaces(jobs, workers)
+	}
+
 	// Process jobs concurrently
 	return processJobsConcurrentlyWithProgress(jobs, workers, showProgress)
 }
@@ -226,13 +234,22 @@ func processMergeFile(sourcePath, targetPath string, dryRun bool) error {
 		return processMergeFileWithoutGPS(sourcePath, targetPath, dryRun)
 	}
 
+	// A user-defined place wins over any geocoder lookup
+	place := GetPlaces().match(lat, lon)
+
 	// Get location from coordinates
-	location, err := getLocationFromCoordinates(lat, lon)
-	if err != nil {
-		return fmt.Errorf("failed to get location for %s: %v", filepath.Base(sourcePath), err)
-	}
+	var location string
+	if place != nil {
+		location = place.location()
+		fmt.Printf("🏠 Place: %s → %s/%s (%.6f, %.6f)\n", place.Name, place.Country, place.City, lat, lon)
+	} else {
+		location, err = getLocationFromCoordinates(lat, lon)
+		if err != nil {
+			return fmt.Errorf("failed to get location for %s: %v", filepath.Base(sourcePath), err)
+		}
 
-	fmt.Printf("📍 Location: %s (%.6f, %.6f)\n", location, lat, lon)
+		fmt.Printf("📍 Location: %s (%.6f, %.6f)\n", location, lat, lon)
+	}
 
 	// Extract date from media file
 	date, err := extractPhotoDate(sourcePath)
@@ -240,11 +257,15 @@ func processMergeFile(sourcePath, targetPath string, dryRun bool) error {
 		return fmt.Errorf("failed to extract date from %s: %v", filepath.Base(sourcePath), err)
 	}
 
-	// Parse location into country and city
-	country, city, err := parseLocation(location)
-	if err != nil {
+	// Parse location into country and city; a place names both directly
+	var country, city string
+	reason := fmt.Sprintf("GPS %.6f, %.6f is in %s", lat, lon, location)
+	if place != nil {
+		country, city = place.Country, place.City
+		reason = fmt.Sprintf("GPS %.6f, %.6f is in place %s", lat, lon, place.Name)
+	} else if country, city, err = parseLocation(location); err != nil {
 		// Skip prompting in dry run mode
-		if dryRun {
+		if !promptsAllowed(dryRun) {
 			country = "unknown-country"
 			city = "unknown-city"
 		} else {
@@ -253,11 +274,12 @@ func processMergeFile(sourcePath, targetPath string, dryRun bool) error {
 			if err != nil {
 				return fmt.Errorf("failed to get location information: %v", err)
 			}
+			reason = fmt.Sprintf("GPS %.6f, %.6f, location entered at the prompt", lat, lon)
 		}
 	}
 
 	// Generate target path using YEAR/COUNTRY/CITY structure
-	return moveToTargetStructure(sourcePath, targetPath, date, country, city, dryRun)
+	return moveToTargetStructure(sourcePath, targetPath, date, country, city, reason, dryRun)
 }
 
 // processMergeFileWithoutGPS handles files without GPS data by trying to infer location from target structure
@@ -280,11 +302,12 @@ func processMergeFileWithoutGPS(sourcePath, targetPath string, dryRun bool) erro
 		} else {
 			fmt.Printf("📍 Using fallback location: unknown-unknown\n")
 		}
-		return moveToTargetStructure(sourcePath, targetPath, date, "unknown-country", "unknown-city", dryRun)
+		return moveToTargetStructure(sourcePath, targetPath, date, "unknown-country", "unknown-city", "no GPS and no target photos of the same day", dryRun)
 	}
 
 	fmt.Printf("📍 Inferred location from target: %s/%s\n", inferredLocation.Country, inferredLocation.City)
-	return moveToTargetStructure(sourcePath, targetPath, date, inferredLocation.Country, inferredLocation.City, dryRun)
+	reason := fmt.Sprintf("no GPS; target photos of %s are in %s/%s", date.Format("2006-01-02"), inferredLocation.Country, inferredLocation.City)
+	return moveToTargetStructure(sourcePath, targetPath, date, inferredLocation.Country, inferredLocation.City, reason, dryRun)
 }
 
 // LocationInfo holds country and city information
@@ -389,96 +412,67 @@ func findExistingFileInTarget(sourcePath, targetPath string) (string, bool, erro
 	return foundPath, found, nil
 }
 
-// moveToTargetStructure moves/copies file to target with YEAR/COUNTRY/CITY structure
-func moveToTargetStructure(sourcePath, targetPath string, date time.Time, country, city string, dryRun bool) error {
-	// Generate new filename and directory structure
-	year := date.Format("2006")
-	newFilename := fmt.Sprintf("%s-%s%s", 
-		date.Format("2006-01-02"), 
-		city, 
-		filepath.Ext(sourcePath))
+// moveToTargetStructure copies (or moves or links, with --transfer) file to target in the path template's layout
+func moveToTargetStructure(sourcePath, targetPath string, date time.Time, country, city, reason string, dryRun bool) error {
+	fields := newLayoutFields(sourcePath, date, country, city)
+	fields.Reason = reason
+	mode := transferModeFor(transferCopy)
 	
-	var newDir string
-	
-	// For video files, place in VIDEO-FILES subdirectory
-	if isVideoFile(sourcePath) {
-		newDir = filepath.Join(targetPath, "VIDEO-FILES", year, country, city)
-	} else {
-		// For photo files, use regular structure
-		newDir = filepath.Join(targetPath, year, country, city)
-	}
-	
-	newPath := filepath.Join(newDir, newFilename)
+	sidecars := findSidecars(sourcePath)
 	
 	// In dry run mode, just show what would happen
 	if dryRun {
 		// Handle duplicates simulation
-		finalPath := newPath
-		counter := 1
-		for {
-			if _, err := os.Stat(finalPath); os.IsNotExist(err) {
-				break // File doesn't exist, we can use this path
-			}
-			
-			// File exists, create a new name with counter
-			ext := filepath.Ext(newFilename)
-			base := strings.TrimSuffix(newFilename, ext)
-			duplicateFilename := fmt.Sprintf("%s-%d%s", base, counter, ext)
-			finalPath = filepath.Join(newDir, duplicateFilename)
-			counter++
-			
-			if counter > 1000 {
-				return fmt.Errorf("too many duplicate filenames, stopping at counter %d", counter)
-			}
+		finalPath, err := layoutDestination(targetPath, fields)
+		if dup, ok := err.(*DuplicateContentError); ok {
+			importedDuplicate(dup, targetPath, true)
+			return nil
+		}
+		if err != nil {
+			return err
 		}
 		
 		if isVideoFile(sourcePath) {
-			fmt.Printf("✅ [DRY RUN] Video would be merged to: %s\n", finalPath)
+			fmt.Printf("✅ [DRY RUN] Video would be merged (%s) to: %s\n", transferVerb(mode), finalPath)
 		} else {
-			fmt.Printf("✅ [DRY RUN] Photo would be merged to: %s\n", finalPath)
+			fmt.Printf("✅ [DRY RUN] Photo would be merged (%s) to: %s\n", transferVerb(mode), finalPath)
 		}
+		activePlan.addFiled(sourcePath, finalPath, mode, fields)
+		transferCompanions(sidecars, sourcePath, finalPath, targetPath, true, mode)
 		return nil
 	}
 	
+	// Handle duplicate filenames: skip identical content, give a different file a hash suffix
+	finalPath, err := layoutDestination(targetPath, fields)
+	if dup, ok := err.(*DuplicateContentError); ok {
+		importedDuplicate(dup, targetPath, false)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	
 	// Create directory structure if it doesn't exist
+	newDir := filepath.Dir(finalPath)
 	if err := os.MkdirAll(newDir, 0755); err != nil {
 		return fmt.Errorf("failed to create directory %s: %v", newDir, err)
 	}
 	
-	// Handle duplicate filenames by adding a counter
-	finalPath := newPath
-	counter := 1
-	for {
-		if _, err := os.Stat(finalPath); os.IsNotExist(err) {
-			break // File doesn't exist, we can use this path
-		}
-		
-		// File exists, create a new name with counter
-		ext := filepath.Ext(newFilename)
-		base := strings.TrimSuffix(newFilename, ext)
-		duplicateFilename := fmt.Sprintf("%s-%d%s", base, counter, ext)
-		finalPath = filepath.Join(newDir, duplicateFilename)
-		counter++
-		
-		if counter > 1000 {
-			return fmt.Errorf("too many duplicate filenames, stopping at counter %d", counter)
-		}
-	}
-	
-	// Copy the file (preserve original in source)
-	if err := copyFile(sourcePath, finalPath); err != nil {
-		return fmt.Errorf("failed to copy file from %s to %s: %v", sourcePath, finalPath, err)
+	// Copy the file (preserve original in source unless --transfer move)
+	if err := transferFile(sourcePath, finalPath, mode); err != nil {
+		return fmt.Errorf("failed to %s file from %s to %s: %v", mode, sourcePath, finalPath, err)
 	}
 	
 	if isVideoFile(sourcePath) {
-		fmt.Printf("✅ Video merged to: %s\n", finalPath)
+		fmt.Printf("✅ Video merged (%s) to: %s\n", transferVerb(mode), finalPath)
 	} else {
-		fmt.Printf("✅ Photo merged to: %s\n", finalPath)
+		fmt.Printf("✅ Photo merged (%s) to: %s\n", transferVerb(mode), finalPath)
 	}
+	transferCompanions(sidecars, sourcePath, finalPath, targetPath, false, mode)
 	return nil
 }
 
-// copyFile copies a file from src to dst
+// copyFile copies a file from src to dst, which must not exist yet
 func copyFile(src, dst string) error {
 	sourceFile, err := os.Open(src)
 	if err != nil {
@@ -486,7 +480,7 @@ func copyFile(src, dst string) error {
 	}
 	defer sourceFile.Close()
 	
-	destFile, err := os.Create(dst)
+	destFile, err := os.OpenFile(dst, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
 	if err != nil {
 		return err
 	}
@@ -494,6 +488,7 @@ func copyFile(src, dst string) error {
 	
 	_, err = io.Copy(destFile, sourceFile)
 	if err != nil {
+		os.Remove(dst)
 		return err
 	}
 	
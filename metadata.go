@@ -0,0 +1,297 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// errUnsupportedFormat is returned by a native reader when the file layout is not one it can parse
+var errUnsupportedFormat = errors.New("unsupported metadata format")
+
+// MediaMetadata holds the metadata fields photo-meta cares about, regardless of which reader produced them
+type MediaMetadata struct {
+	Source string // Reader that produced the metadata ("exif", "exiftool", ...)
+
+	HasGPS    bool
+	Latitude  float64
+	Longitude float64
+
+	// Raw EXIF-style date strings ("2006:01:02 15:04:05"), empty when absent
+	DateTimeOriginal   string
+	CreateDate         string
+	DateTime           string
+	ModifyDate         string
+	OffsetTimeOriginal string
+	OffsetTime         string
+	GPSDateStamp       string
+	GPSTimeStamp       string
+
+	// CreateDateIsUTC is set when CreateDate is UTC (QuickTime) rather than local wall-clock time
+	CreateDateIsUTC bool
+
+	Make             string
+	Model            string
+	SerialNumber     string
+	ImageDescription string
+
+	// ContentIdentifier links the HEIC/JPEG and MOV halves of an Apple Live Photo
+	ContentIdentifier string
+}
+
+// MetadataReader extracts metadata from media files of the formats it supports
+type MetadataReader interface {
+	// Name identifies the reader in log output
+	Name() string
+	// Supports reports whether the reader should be tried for the given path
+	Supports(path string) bool
+	// Read extracts metadata, returning errUnsupportedFormat if the file turns out not to be parseable
+	Read(path string) (*MediaMetadata, error)
+}
+
+// nativeMetadataReaders are tried in order before falling back to exiftool
+var nativeMetadataReaders = []MetadataReader{
+	&exifMetadataReader{},
+	&heifMetadataReader{},
+	&quicktimeMetadataReader{},
+}
+
+// fallbackMetadataReader handles every format the native readers cannot
+var fallbackMetadataReader MetadataReader = &exiftoolMetadataReader{}
+
+// metadataCacheLimit bounds the per-file metadata cache; it is reset when full
+const metadataCacheLimit = 4096
+
+// metadataCacheEntry remembers metadata along with the file state it was read from
+type metadataCacheEntry struct {
+	metadata *MediaMetadata
+	size     int64
+	modTime  time.Time
+}
+
+// metadataCache lets the GPS, date and description lookups for one file share a single read
+var metadataCache = struct {
+	sync.Mutex
+	entries map[string]metadataCacheEntry
+}{entries: make(map[string]metadataCacheEntry)}
+
+// readMetadata returns the metadata for a file, reading it at most once while the file is unchanged
+func readMetadata(path string) (*MediaMetadata, error) {
+	info, statErr := os.Stat(path)
+
+	if statErr == nil {
+		metadataCache.Lock()
+		entry, exists := metadataCache.entries[path]
+		metadataCache.Unlock()
+		if exists && entry.size == info.Size() && entry.modTime.Equal(info.ModTime()) {
+			return withCompanionMetadata(path, entry.metadata), nil
+		}
+	}
+
+	metadata, err := readMetadataUncached(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if statErr == nil {
+		metadataCache.Lock()
+		if len(metadataCache.entries) >= metadataCacheLimit {
+			metadataCache.entries = make(map[string]metadataCacheEntry)
+		}
+		metadataCache.entries[path] = metadataCacheEntry{metadata: metadata, size: info.Size(), modTime: info.ModTime()}
+		metadataCache.Unlock()
+	}
+
+	return withCompanionMetadata(path, metadata), nil
+}
+
+// invalidateMetadataCache drops any cached metadata for a file after it has been written
+func invalidateMetadataCache(path string) {
+	metadataCache.Lock()
+	delete(metadataCache.entries, path)
+	metadataCache.Unlock()
+}
+
+// clearMetadataCache drops every cached read, for when what readMetadata merges into files changes
+func clearMetadataCache() {
+	metadataCache.Lock()
+	metadataCache.entries = make(map[string]metadataCacheEntry)
+	metadataCache.Unlock()
+}
+
+// readMetadataUncached reads a file's own metadata and fills any missing GPS or dates from
+// its sidecars (and its Takeout JSON when importing a Takeout archive, or the track when geotagging)
+func readMetadataUncached(path string) (*MediaMetadata, error) {
+	metadata, err := readEmbeddedMetadata(path)
+	if err != nil {
+		// A sidecar may still know where and when the file was taken
+		metadata = nil
+	}
+
+	metadata = mergeSidecarMetadata(path, metadata)
+	if takeoutMetadataEnabled {
+		metadata = mergeTakeoutMetadata(path, metadata)
+	}
+	if activeGeotagger != nil {
+		metadata = activeGeotagger.mergeTrackMetadata(metadata)
+	}
+
+	if metadata == nil {
+		return nil, err
+	}
+	return metadata, nil
+}
+
+// readEmbeddedMetadata extracts metadata using the first native reader that can parse the file,
+// falling back to exiftool only for formats no native reader understands
+func readEmbeddedMetadata(path string) (*MediaMetadata, error) {
+	for _, reader := range nativeMetadataReaders {
+		if !reader.Supports(path) {
+			continue
+		}
+
+		metadata, err := reader.Read(path)
+		if err == nil {
+			return metadata, nil
+		}
+		if !errors.Is(err, errUnsupportedFormat) {
+			// Corrupt or truncated structure - let exiftool have a go at it
+			fmt.Printf("⚠️  %s reader failed for %s: %v (falling back to exiftool)\n", reader.Name(), filepath.Base(path), err)
+		}
+	}
+
+	return fallbackMetadataReader.Read(path)
+}
+
+// exiftoolMetadataReader reads metadata by running exiftool
+type exiftoolMetadataReader struct{}
+
+// exiftoolMetadataTags lists every tag requested from exiftool in a single call
+var exiftoolMetadataTags = []string{
+	"-DateTimeOriginal",
+	"-CreateDate",
+	"-CreationDate",
+	"-DateTime",
+	"-ModifyDate",
+	"-OffsetTimeOriginal",
+	"-OffsetTime",
+	"-GPSLatitude",
+	"-GPSLongitude",
+	"-GPSDateStamp",
+	"-GPSTimeStamp",
+	"-Make",
+	"-Model",
+	"-SerialNumber",
+	"-ImageDescription",
+	"-ContentIdentifier",
+}
+
+func (r *exiftoolMetadataReader) Name() string { return "exiftool" }
+
+func (r *exiftoolMetadataReader) Supports(path string) bool { return true }
+
+// Read fetches every tag in one -json round trip on a pooled exiftool process
+func (r *exiftoolMetadataReader) Read(path string) (*MediaMetadata, error) {
+	args := append([]string{"-json", "-n"}, exiftoolMetadataTags...)
+	args = append(args, path)
+
+	stdout, stderr, err := GetExiftoolPool().Execute(args...)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(stdout) == "" {
+		return nil, fmt.Errorf("exiftool failed: %s", strings.TrimSpace(stderr))
+	}
+
+	metadata, err := parseExiftoolJSON([]byte(stdout))
+	if err != nil {
+		return nil, err
+	}
+
+	// QuickTime CreateDate is stored in UTC
+	metadata.CreateDateIsUTC = isQuickTimeFile(path)
+	return metadata, nil
+}
+
+// parseExiftoolJSON converts exiftool -json -n output for a single file into MediaMetadata
+func parseExiftoolJSON(output []byte) (*MediaMetadata, error) {
+	var records []map[string]interface{}
+	if err := json.Unmarshal(output, &records); err != nil {
+		return nil, fmt.Errorf("failed to parse exiftool output: %v", err)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("exiftool returned no metadata")
+	}
+	record := records[0]
+
+	metadata := &MediaMetadata{
+		Source:             "exiftool",
+		DateTimeOriginal:   exiftoolString(record, "DateTimeOriginal"),
+		CreateDate:         exiftoolString(record, "CreateDate"),
+		DateTime:           exiftoolString(record, "DateTime"),
+		ModifyDate:         exiftoolString(record, "ModifyDate"),
+		OffsetTimeOriginal: exiftoolString(record, "OffsetTimeOriginal"),
+		OffsetTime:         exiftoolString(record, "OffsetTime"),
+		GPSDateStamp:       exiftoolString(record, "GPSDateStamp"),
+		GPSTimeStamp:       exiftoolString(record, "GPSTimeStamp"),
+		Make:               exiftoolString(record, "Make"),
+		Model:              exiftoolString(record, "Model"),
+		SerialNumber:       exiftoolString(record, "SerialNumber"),
+		ImageDescription:   exiftoolString(record, "ImageDescription"),
+		ContentIdentifier:  exiftoolString(record, "ContentIdentifier"),
+	}
+
+	// Apple's Keys:CreationDate carries the local time and offset ("2023:05:01 12:34:56+02:00")
+	if creationDate := exiftoolString(record, "CreationDate"); creationDate != "" && metadata.DateTimeOriginal == "" {
+		if len(creationDate) > 19 {
+			metadata.DateTimeOriginal = creationDate[:19]
+			metadata.OffsetTimeOriginal = creationDate[19:]
+		} else {
+			metadata.DateTimeOriginal = creationDate
+		}
+	}
+
+	latStr := exiftoolString(record, "GPSLatitude")
+	lonStr := exiftoolString(record, "GPSLongitude")
+	if latStr != "" && lonStr != "" {
+		lat, latErr := strconv.ParseFloat(latStr, 64)
+		lon, lonErr := strconv.ParseFloat(lonStr, 64)
+		if latErr == nil && lonErr == nil {
+			metadata.HasGPS = true
+			metadata.Latitude = lat
+			metadata.Longitude = lon
+		}
+	}
+
+	return metadata, nil
+}
+
+// exiftoolString returns a JSON field as a trimmed string, treating exiftool's "-" placeholder as empty
+func exiftoolString(record map[string]interface{}, key string) string {
+	value, exists := record[key]
+	if !exists || value == nil {
+		return ""
+	}
+
+	var str string
+	switch v := value.(type) {
+	case string:
+		str = v
+	case float64:
+		str = strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		str = fmt.Sprintf("%v", v)
+	}
+
+	str = strings.TrimSpace(str)
+	if str == "-" {
+		return ""
+	}
+	return str
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// nominatimDefaultURL is the public OpenStreetMap Nominatim server
+const nominatimDefaultURL = "https://nominatim.openstreetmap.org"
+
+// nominatimDefaultUserAgent identifies photo-meta, as the Nominatim usage policy requires
+const nominatimDefaultUserAgent = "photo-meta/1.0 (+https://github.com/billysbar/photo-meta)"
+
+// nominatimDefaultRequestsPerSecond is the public server's limit
+const nominatimDefaultRequestsPerSecond = 1.0
+
+// nominatimDefaultMaxRetries is how often a 429, 5xx or network error is retried
+const nominatimDefaultMaxRetries = 3
+
+// Backoff between retries: base * 2^attempt plus up to base of jitter, at most nominatimMaxBackoff
+const (
+	nominatimBaseBackoff = time.Second
+	nominatimMaxBackoff  = 30 * time.Second
+)
+
+// Circuit breaker: after nominatimBreakerThreshold failed lookups in a row, Nominatim
+// is skipped for nominatimBreakerCooldown and the other providers answer alone
+const (
+	nominatimBreakerThreshold = 3
+	nominatimBreakerCooldown  = 2 * time.Minute
+)
+
+// errGeocoderUnavailable is returned while a provider's circuit breaker is open
+var errGeocoderUnavailable = errors.New("geocoder temporarily unavailable")
+
+// retryableStatusError is an HTTP status worth retrying, with the server's Retry-After if any
+type retryableStatusError struct {
+	status     int
+	retryAfter time.Duration
+}
+
+func (e *retryableStatusError) Error() string {
+	return fmt.Sprintf("geocoding API returned status: %d", e.status)
+}
+
+// requestLimiter spaces requests evenly; every worker shares one, so the whole run
+// stays under the server's rate limit
+type requestLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+// wait blocks until the caller's request slot
+func (l *requestLimiter) wait() {
+	l.mu.Lock()
+	now := time.Now()
+	slot := l.next
+	if slot.Before(now) {
+		slot = now
+	}
+	l.next = slot.Add(l.interval)
+	l.mu.Unlock()
+
+	time.Sleep(slot.Sub(now))
+}
+
+// pause holds back every request until the given time, e.g. for a server's Retry-After
+func (l *requestLimiter) pause(until time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if until.After(l.next) {
+		l.next = until
+	}
+}
+
+// circuitBreaker stops calling a failing service for a while instead of failing every job slowly
+type circuitBreaker struct {
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+}
+
+// allow reports whether a request may be sent; after the cooldown requests are tried again
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return time.Now().After(b.openUntil)
+}
+
+// success closes the breaker
+func (b *circuitBreaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	b.openUntil = time.Time{}
+}
+
+// failure counts a failed request, opening the breaker at the threshold. It returns true when it opened
+func (b *circuitBreaker) failure() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	if b.failures < nominatimBreakerThreshold {
+		return false
+	}
+	b.openUntil = time.Now().Add(nominatimBreakerCooldown)
+	b.failures = nominatimBreakerThreshold - 1 // A failed trial request reopens it straight away
+	return true
+}
+
+// nominatimGeocoder reverse geocodes with a Nominatim server, following its usage policy:
+// an identifying User-Agent, a shared rate limit, backoff on errors and a circuit breaker
+type nominatimGeocoder struct {
+	baseURL    string
+	userAgent  string
+	email      string
+	maxRetries int
+	client     *http.Client
+	limiter    *requestLimiter
+	breaker    *circuitBreaker
+}
+
+// newNominatimGeocoder creates a Nominatim provider from the nominatim_* settings
+func newNominatimGeocoder(config *AppConfig) *nominatimGeocoder {
+	baseURL := config.NominatimURL
+	if baseURL == "" {
+		baseURL = nominatimDefaultURL
+	}
+	userAgent := config.NominatimUserAgent
+	if userAgent == "" {
+		userAgent = nominatimDefaultUserAgent
+	}
+	requestsPerSecond := config.NominatimRequestsPerSecond
+	if requestsPerSecond <= 0 {
+		requestsPerSecond = nominatimDefaultRequestsPerSecond
+	}
+	maxRetries := config.NominatimMaxRetries
+	if maxRetries <= 0 {
+		maxRetries = nominatimDefaultMaxRetries
+	}
+
+	return &nominatimGeocoder{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		userAgent:  userAgent,
+		email:      config.NominatimEmail,
+		maxRetries: maxRetries,
+		client:     &http.Client{Timeout: 10 * time.Second},
+		limiter:    &requestLimiter{interval: time.Duration(float64(time.Second) / requestsPerSecond)},
+		breaker:    &circuitBreaker{},
+	}
+}
+
+// Name identifies the provider
+func (n *nominatimGeocoder) Name() string {
+	return geocoderNominatim
+}
+
+// fetch GETs url within the rate limit, retrying 429, 5xx and network errors with
+// exponential backoff. Repeated failures open the circuit breaker
+func (n *nominatimGeocoder) fetch(url string) ([]byte, error) {
+	if !n.breaker.allow() {
+		return nil, fmt.Errorf("nominatim: %w", errGeocoderUnavailable)
+	}
+
+	var lastErr error
+	for attempt := 0; attempt <= n.maxRetries; attempt++ {
+		if attempt > 0 {
+			delay := backoffDelay(attempt)
+			var statusErr *retryableStatusError
+			if errors.As(lastErr, &statusErr) && statusErr.retryAfter > delay {
+				if statusErr.retryAfter > nominatimMaxBackoff {
+					break // Asked to stay away longer than is worth blocking the run for
+				}
+				delay = statusErr.retryAfter
+			}
+			// Hold back every worker, not just this one
+			n.limiter.pause(time.Now().Add(delay))
+		}
+		n.limiter.wait()
+
+		body, err := n.get(url)
+		if err == nil {
+			n.breaker.success()
+			return body, nil
+		}
+		lastErr = err
+
+		var statusErr *retryableStatusError
+		if !errors.As(err, &statusErr) && !isNetworkError(err) {
+			break // 403, 400 ... will not get better by retrying
+		}
+	}
+
+	if n.breaker.failure() {
+		fmt.Printf("⚠️  Nominatim failed %d times in a row (%v) - using offline geocoders only for the next %v\n",
+			nominatimBreakerThreshold, lastErr, nominatimBreakerCooldown)
+	}
+	return nil, lastErr
+}
+
+// get sends one request
+func (n *nominatimGeocoder) get(url string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("geocoding request failed: %v", err)
+	}
+	req.Header.Set("User-Agent", n.userAgent)
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return nil, &networkError{err: err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+		return nil, &retryableStatusError{status: resp.StatusCode, retryAfter: parseRetryAfter(resp.Header.Get("Retry-After"))}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("geocoding API returned status: %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, &networkError{err: err}
+	}
+	return body, nil
+}
+
+// networkError is a transport failure (timeout, refused connection, reset)
+type networkError struct {
+	err error
+}
+
+func (e *networkError) Error() string {
+	return fmt.Sprintf("geocoding request failed: %v", e.err)
+}
+
+// isNetworkError checks for a transport failure
+func isNetworkError(err error) bool {
+	var netErr *networkError
+	return errors.As(err, &netErr)
+}
+
+// backoffDelay is the wait before retry number attempt (1, 2, ...)
+func backoffDelay(attempt int) time.Duration {
+	delay := nominatimBaseBackoff << uint(attempt-1)
+	if delay > nominatimMaxBackoff {
+		delay = nominatimMaxBackoff
+	}
+	return delay + time.Duration(rand.Int63n(int64(nominatimBaseBackoff)))
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an HTTP date
+func parseRetryAfter(value string) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if when, err := http.ParseTime(value); err == nil {
+		if delay := time.Until(when); delay > 0 {
+			return delay
+		}
+	}
+	return 0
+}
@@ -199,6 +199,9 @@ func processFilesWithLocationInFilename(sourcePath, destPath string, locationDB
 		}
 	}
 
+	// RAW+JPEG pairs and Live Photo videos are moved with their primary file rather than on their own
+	filesToProcess = pairCompanionFiles(filesToProcess)
+
 	// Process the collected files
 	for _, path := range filesToProcess {
 		filename := filepath.Base(path)
@@ -233,7 +236,8 @@ func processFilesWithLocationInFilename(sourcePath, destPath string, locationDB
 		// Check if we can determine the country for this city
 		finalCountry, finalCity, needsPrompt := validateLocationWithDB(locationDB, country, city, filename)
 
-		if needsPrompt && !dryRun {
+		reason := fmt.Sprintf("date and place %s in the filename", city)
+		if needsPrompt && promptsAllowed(dryRun) {
 			// Prompt immediately for this file
 			fmt.Printf("\nFile: %s\n", filename)
 			fmt.Printf("Detected location: %s\n", city)
@@ -262,21 +266,22 @@ func processFilesWithLocationInFilename(sourcePath, destPath string, locationDB
 			// Use the prompted values
 			finalCountry = promptedCountry
 			finalCity = promptedCity
-		} else if needsPrompt && dryRun {
+			reason = fmt.Sprintf("date in the filename, place %s entered at the prompt", city)
+		} else if needsPrompt {
 			// In dry run mode, just show what would be prompted
 			fmt.Printf("🤔 [DRY RUN] Would prompt for location: %s (detected city: %s)\n", filename, city)
 			unmatchedFiles = append(unmatchedFiles, path)
 			continue
 		}
 
-		// Create location path: YYYY/COUNTRY/CITY
-		location := fmt.Sprintf("%s/%s/%s", year, finalCountry, finalCity)
 		fmt.Printf("📍 File: %s -> Date: %s -> Location: %s/%s\n", filename, date, finalCountry, finalCity)
 
 		// Move file to location-based structure
-		if err := moveFileToLocationStructure(path, destPath, location, date, finalCity, dryRun); err != nil {
+		finalPath, err := moveFileToLocationStructure(path, destPath, date, finalCountry, finalCity, reason, dryRun)
+		if err != nil {
 			return fmt.Errorf("failed to move %s: %v", filename, err)
 		}
+		activeLocationWriter.add(finalPath, folderLocation(filepath.Join(finalCountry, finalCity), locationSourceFilename))
 
 		processedCount++
 		if isVideoFile(path) {
@@ -500,82 +505,13 @@ func promptUserForLocation(detectedCity, filename string) (country, city string,
 	return country, city, nil
 }
 
-// moveFileToLocationStructure moves file to the location-based directory structure
-func moveFileToLocationStructure(sourcePath, destBasePath, location, date, city string, dryRun bool) error {
+// moveFileToLocationStructure moves file to the location-based directory structure and returns its final path
+func moveFileToLocationStructure(sourcePath, destBasePath, date, country, city, reason string, dryRun bool) (string, error) {
 	// Generate new filename using date-city format, preserving existing hour+minute if present
 	dateTime, _ := time.Parse("2006-01-02", date) // Convert date string back to time for helper
-	newFilename := generateFilenameWithTime(sourcePath, dateTime, city)
-
-	var destDir string
-	var fileType string
-
-	// Check if this is a video file
-	if isVideoFile(sourcePath) {
-		// For video files, place in VIDEO-FILES/YYYY/COUNTRY/CITY structure
-		fileType = "video"
-		destDir = filepath.Join(destBasePath, "VIDEO-FILES", location)
-		if dryRun {
-			fmt.Printf("🎥 [DRY RUN] Processing video file: %s\n", filepath.Base(sourcePath))
-		} else {
-			fmt.Printf("🎥 Processing video file: %s\n", filepath.Base(sourcePath))
-		}
-	} else {
-		// For photo files, use the regular location structure
-		fileType = "photo"
-		destDir = filepath.Join(destBasePath, location)
-		if dryRun {
-			fmt.Printf("📷 [DRY RUN] Processing photo file: %s\n", filepath.Base(sourcePath))
-		} else {
-			fmt.Printf("📷 Processing photo file: %s\n", filepath.Base(sourcePath))
-		}
-	}
-
-	destPath := filepath.Join(destDir, newFilename)
-
-	// Handle duplicates
-	finalPath := destPath
-	counter := 1
-	for {
-		if _, err := os.Stat(finalPath); os.IsNotExist(err) {
-			break
-		}
-
-		base := strings.TrimSuffix(newFilename, ext)
-		duplicateFilename := fmt.Sprintf("%s-%d%s", base, counter, ext)
-		finalPath = filepath.Join(destDir, duplicateFilename)
-		counter++
-
-		if counter > 1000 {
-			return fmt.Errorf("too many duplicate filenames")
-		}
-	}
-
-	if dryRun {
-		// Dry run mode - just show what would happen
-		if fileType == "video" {
-			fmt.Printf("✅ [DRY RUN] Video would be moved to: %s\n", finalPath)
-		} else {
-			fmt.Printf("✅ [DRY RUN] Photo would be moved to: %s\n", finalPath)
-		}
-		return nil
-	}
-
-	// Create directory structure if it doesn't exist
-	if err := os.MkdirAll(destDir, 0755); err != nil {
-		return fmt.Errorf("failed to create directory %s: %v", destDir, err)
-	}
-
-	// Move the file
-	if err := os.Rename(sourcePath, finalPath); err != nil {
-		return err
-	}
-
-	if fileType == "video" {
-		fmt.Printf("✅ Video moved to: %s\n", finalPath)
-	} else {
-		fmt.Printf("✅ Photo moved to: %s\n", finalPath)
-	}
-	return nil
+	fields := newLayoutFields(sourcePath, dateTime, country, city)
+	fields.Reason = reason
+	return moveIntoLayout(sourcePath, destBasePath, fields, dryRun)
 }
 
 // collectSampleFilesForOrganize collects sample files for organize dry-run mode
@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -122,28 +123,36 @@ func checkFilePermissions(sourcePath, destPath string) error {
 	return nil
 }
 
-// safeFileMove attempts to move a file with enhanced permission error handling
+// safeFileMove attempts to move a file with enhanced permission error handling. It fails rather
+// than replace a file already at destPath
 func safeFileMove(sourcePath, destPath string) error {
 	// Pre-check permissions
 	if err := checkFilePermissions(sourcePath, destPath); err != nil {
 		return err
 	}
 	
-	// Attempt the move
-	if err := os.Rename(sourcePath, destPath); err != nil {
-		if isPermissionError(err) {
-			return &PermissionError{
-				Path:      sourcePath,
-				Operation: "move",
-				Err:       err,
+	// Link the new name and drop the old one: unlike a rename, this never replaces a file already at destPath
+	err := os.Link(sourcePath, destPath)
+	if err == nil {
+		if err := os.Remove(sourcePath); err != nil {
+			os.Remove(destPath)
+			if isPermissionError(err) {
+				return &PermissionError{
+					Path:      sourcePath,
+					Operation: "move",
+					Err:       err,
+				}
 			}
+			return fmt.Errorf("failed to remove source file: %v", err)
 		}
-		
-		// If rename fails (possibly cross-device), try copy+delete
-		return safeCopyAndDelete(sourcePath, destPath)
+		return nil
+	}
+	if os.IsExist(err) {
+		return fmt.Errorf("destination %s already exists", destPath)
 	}
 	
-	return nil
+	// Cross-device or a filesystem without hard links - copy+delete
+	return safeCopyAndDelete(sourcePath, destPath)
 }
 
 // safeCopyAndDelete copies a file and deletes the original with permission checks
@@ -169,8 +178,11 @@ func safeCopyAndDelete(sourcePath, destPath string) error {
 	}
 	
 	// Create destination file
-	destFile, err := os.OpenFile(destPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, sourceInfo.Mode())
+	destFile, err := os.OpenFile(destPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, sourceInfo.Mode())
 	if err != nil {
+		if os.IsExist(err) {
+			return fmt.Errorf("destination %s already exists", destPath)
+		}
 		if isPermissionError(err) {
 			return &PermissionError{
 				Path:      destPath,
@@ -183,7 +195,7 @@ func safeCopyAndDelete(sourcePath, destPath string) error {
 	defer destFile.Close()
 	
 	// Copy data
-	_, err = sourceFile.WriteTo(destFile)
+	_, err = io.Copy(destFile, sourceFile)
 	if err != nil {
 		if isPermissionError(err) {
 			// Clean up partial file
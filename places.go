@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// place is a user-defined area, a circle or a polygon, that is always filed under the same folder
+type place struct {
+	Name    string
+	Country string // Folder names, already slugged (united-kingdom)
+	City    string
+
+	lat, lon float64      // Circle centre
+	radiusKm float64      // Circle radius; 0 for a polygon
+	ring     [][2]float64 // Polygon as [longitude, latitude] points
+	bounds   boundingBox
+}
+
+// contains reports whether the coordinates are inside the place
+func (p *place) contains(lat, lon float64) bool {
+	if p.ring != nil {
+		return p.bounds.contains(lat, lon) && ringContains(p.ring, lat, lon)
+	}
+	return haversineKm(p.lat, p.lon, lat, lon) <= p.radiusKm
+}
+
+// location returns the place in the city-country form used by the geocoders
+func (p *place) location() string {
+	return fmt.Sprintf("%s-%s", p.City, p.Country)
+}
+
+// placeSet is the places file, in file order
+type placeSet struct {
+	places []*place
+}
+
+// match returns the first place containing the coordinates, or nil
+func (ps *placeSet) match(lat, lon float64) *place {
+	if ps == nil {
+		return nil
+	}
+	for _, p := range ps.places {
+		if p.contains(lat, lon) {
+			return p
+		}
+	}
+	return nil
+}
+
+// loadPlaces reads a places file. Each line names a circle or a polygon and its folder:
+//
+//	home: 51.5072, -0.1276, 300m -> united-kingdom/home
+//	flat: 39.570, 2.640; 39.570, 2.660; 39.580, 2.660; 39.580, 2.640 -> spain/holiday-flat
+//
+// Blank lines and lines starting with # are ignored
+func loadPlaces(path string) (*placeSet, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open places file: %v", err)
+	}
+	defer file.Close()
+
+	set := &placeSet{}
+	scanner := bufio.NewScanner(file)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		p, err := parsePlaceLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s line %d: %v", path, lineNumber, err)
+		}
+		set.places = append(set.places, p)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read places file: %v", err)
+	}
+
+	return set, nil
+}
+
+// parsePlaceLine parses "name: area -> country/city"
+func parsePlaceLine(line string) (*place, error) {
+	arrow := strings.LastIndex(line, "->")
+	if arrow < 0 {
+		return nil, fmt.Errorf("missing '-> country/city'")
+	}
+	definition, target := strings.TrimSpace(line[:arrow]), strings.TrimSpace(line[arrow+2:])
+
+	colon := strings.Index(definition, ":")
+	if colon <= 0 {
+		return nil, fmt.Errorf("missing place name before ':'")
+	}
+	p := &place{Name: strings.TrimSpace(definition[:colon])}
+
+	country, city, found := strings.Cut(target, "/")
+	p.Country, p.City = placeSlug(country), placeSlug(city)
+	if !found || !isPlaceFolderName(p.Country) || !isPlaceFolderName(p.City) {
+		return nil, fmt.Errorf("folder '%s' must be country/city, each a single folder name", target)
+	}
+
+	points := strings.Split(definition[colon+1:], ";")
+	switch {
+	case len(points) == 1:
+		fields := strings.Split(points[0], ",")
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("a circle is 'lat, lon, radius' (e.g. 51.5072, -0.1276, 300m)")
+		}
+		lat, lon, err := parsePlacePoint(fields[0], fields[1])
+		if err != nil {
+			return nil, err
+		}
+		radiusKm, err := parsePlaceRadius(fields[2])
+		if err != nil {
+			return nil, err
+		}
+		p.lat, p.lon, p.radiusKm = lat, lon, radiusKm
+	case len(points) >= 3:
+		for _, point := range points {
+			fields := strings.Split(point, ",")
+			if len(fields) != 2 {
+				return nil, fmt.Errorf("polygon point '%s' must be 'lat, lon'", strings.TrimSpace(point))
+			}
+			lat, lon, err := parsePlacePoint(fields[0], fields[1])
+			if err != nil {
+				return nil, err
+			}
+			p.ring = append(p.ring, [2]float64{lon, lat})
+		}
+		p.bounds = ringBounds(p.ring)
+	default:
+		return nil, fmt.Errorf("a polygon needs at least 3 points separated by ';'")
+	}
+
+	return p, nil
+}
+
+// parsePlacePoint parses and checks a latitude and longitude
+func parsePlacePoint(latText, lonText string) (float64, float64, error) {
+	lat, err := strconv.ParseFloat(strings.TrimSpace(latText), 64)
+	if err != nil || lat < -90 || lat > 90 {
+		return 0, 0, fmt.Errorf("invalid latitude '%s'", strings.TrimSpace(latText))
+	}
+	lon, err := strconv.ParseFloat(strings.TrimSpace(lonText), 64)
+	if err != nil || lon < -180 || lon > 180 {
+		return 0, 0, fmt.Errorf("invalid longitude '%s'", strings.TrimSpace(lonText))
+	}
+	return lat, lon, nil
+}
+
+// parsePlaceRadius parses a radius such as 300m, 1.5km or 300 (metres) into kilometres
+func parsePlaceRadius(text string) (float64, error) {
+	text = strings.ToLower(strings.TrimSpace(text))
+	scale := 0.001
+	if strings.HasSuffix(text, "km") {
+		text, scale = strings.TrimSuffix(text, "km"), 1
+	} else {
+		text = strings.TrimSuffix(text, "m")
+	}
+
+	radius, err := strconv.ParseFloat(strings.TrimSpace(text), 64)
+	if err != nil || radius <= 0 || math.IsInf(radius, 0) {
+		return 0, fmt.Errorf("invalid radius '%s' (e.g. 300m or 1.5km)", text)
+	}
+	return radius * scale, nil
+}
+
+// placeSlug turns a folder name into the lower-case, dash-separated form
+func placeSlug(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), "-"))
+}
+
+// isPlaceFolderName reports whether a country or city slug names one folder inside the destination
+func isPlaceFolderName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, `/\`)
+}
+
+var (
+	places     *placeSet
+	placesOnce sync.Once
+)
+
+// GetPlaces loads places_file once; nil when none is configured
+func GetPlaces() *placeSet {
+	placesOnce.Do(func() {
+		path := GetAppConfig().PlacesFile
+		if path == "" {
+			return
+		}
+
+		loaded, err := loadPlaces(path)
+		if err != nil {
+			fmt.Printf("⚠️  Warning: Places not used: %v\n", err)
+			return
+		}
+		fmt.Printf("🏠 Loaded %d places from %s\n", len(loaded.places), path)
+		places = loaded
+	})
+
+	return places
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParsePlaceLine(t *testing.T) {
+	tests := []struct {
+		line        string
+		wantCountry string
+		wantCity    string
+		wantErr     string
+	}{
+		{"home: 51.5072, -0.1276, 300m -> United Kingdom/Home", "united-kingdom", "home", ""},
+		{"flat: 39.57, 2.64; 39.57, 2.66; 39.58, 2.66 -> spain/holiday flat", "spain", "holiday-flat", ""},
+		{"x: 1, 2, 3 -> a/b/c", "", "", "must be country/city"},
+		{"x: 1, 2, 3 -> ../x", "", "", "must be country/city"},
+		{"x: 1, 2, 3 -> spain/..", "", "", "must be country/city"},
+		{"x: 1, 2, 3 -> ./madrid", "", "", "must be country/city"},
+		{`x: 1, 2, 3 -> spain/a\b`, "", "", "must be country/city"},
+		{"x: 1, 2, 3 -> spain/", "", "", "must be country/city"},
+		{"x: 1, 2, 3 -> spain", "", "", "must be country/city"},
+		{"x: 1, 2 -> spain/madrid", "", "", "a circle is"},
+	}
+
+	for _, tt := range tests {
+		p, err := parsePlaceLine(tt.line)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("parsePlaceLine(%q) error = %v, want one containing %q", tt.line, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parsePlaceLine(%q) failed: %v", tt.line, err)
+			continue
+		}
+		if p.Country != tt.wantCountry || p.City != tt.wantCity {
+			t.Errorf("parsePlaceLine(%q) = %s/%s, want %s/%s", tt.line, p.Country, p.City, tt.wantCountry, tt.wantCity)
+		}
+	}
+}
+
+func TestLoadPlacesReportsLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "places.txt")
+	content := "# places\nhome: 51.5072, -0.1276, 300m -> united-kingdom/home\n\nescape: 1, 2, 3 -> ../outside\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadPlaces(path); err == nil || !strings.Contains(err.Error(), "line 4") {
+		t.Errorf("loadPlaces error = %v, want one naming line 4", err)
+	}
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// planVersion is the plan file format written by plan and understood by apply
+const planVersion = 1
+
+// planKindDuplicate is the kind of a plan entry for a file the library already has
+const planKindDuplicate = "duplicate"
+
+// plannableCommands are the filing commands plan can run; their source and destination are the first two arguments
+var plannableCommands = map[string]bool{
+	"process":  true,
+	"takeout":  true,
+	"geotag":   true,
+	"datetime": true,
+	"organize": true,
+	"fallback": true,
+	"merge":    true,
+}
+
+// planEntry is one file the planned run would transfer, with what decided where it goes
+type planEntry struct {
+	Source  string    `json:"source"`
+	Target  string    `json:"target"`
+	Mode    string    `json:"mode,omitempty"`   // Transfer mode (move, copy, hardlink, symlink, reflink)
+	Kind    string    `json:"kind"`             // photo, video, raw, live-photo-video, sidecar or duplicate
+	Action  string    `json:"action,omitempty"` // For a duplicate: delete, or skip to leave it in place
+	Of      string    `json:"of,omitempty"`     // Source of the file a companion or sidecar travels with
+	Date    string    `json:"date,omitempty"`   // Capture date the file is filed under
+	Country string    `json:"country,omitempty"`
+	City    string    `json:"city,omitempty"`
+	Reason  string    `json:"reason,omitempty"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	Hash    string    `json:"hash"` // SHA-256 of the source when planned
+
+	// Location is written into the file after the transfer (--write-metadata)
+	Location *inferredLocation `json:"write_location,omitempty"`
+}
+
+// executionPlan is a plan file: the planned command and the transfers it decided on, in order
+type executionPlan struct {
+	Version     int         `json:"version"`
+	Created     time.Time   `json:"created"`
+	Command     string      `json:"command"`
+	CommandLine string      `json:"command_line"`
+	Source      string      `json:"source"`
+	Destination string      `json:"destination"`
+	Entries     []planEntry `json:"entries"`
+
+	mu       sync.Mutex
+	path     string
+	reserved map[string]string // Source planned for each target, so no two files are given the same one
+}
+
+// activePlan collects the transfers of a plan run; nil otherwise
+var activePlan *executionPlan
+
+// startPlan turns "plan COMMAND ARGS [--output FILE]" in os.Args into a dry run of COMMAND whose
+// transfers are collected into activePlan
+func startPlan() error {
+	args := os.Args[2:]
+	output := fmt.Sprintf("photo-meta-plan-%s.json", time.Now().Format("20060102-150405"))
+	var commandArgs []string
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--output":
+			if i+1 >= len(args) {
+				return fmt.Errorf("--output requires a file")
+			}
+			i++
+			output = args[i]
+		case "--dry-run":
+			return fmt.Errorf("a plan always covers every file; leave out --dry-run")
+		default:
+			commandArgs = append(commandArgs, args[i])
+		}
+	}
+
+	if len(commandArgs) < 3 || !plannableCommands[commandArgs[0]] {
+		return fmt.Errorf("usage: plan <process|takeout|geotag|datetime|organize|fallback|merge> /source/path /destination/path [OPTIONS] [--output FILE]")
+	}
+	command := commandArgs[0]
+	source, destination := commandArgs[1], commandArgs[2]
+	if strings.HasPrefix(source, "--") || strings.HasPrefix(destination, "--") {
+		return fmt.Errorf("%s needs a source and a destination to plan", command)
+	}
+	for _, arg := range commandArgs {
+		if arg == "--write-metadata" && (command == "takeout" || command == "geotag") {
+			// These write into the source before filing, which apply would not repeat
+			return fmt.Errorf("%s --write-metadata changes the source files and cannot be planned; run it without a plan", command)
+		}
+	}
+
+	activePlan = &executionPlan{
+		Version:     planVersion,
+		Created:     time.Now(),
+		Command:     command,
+		CommandLine: strings.Join(commandArgs, " "),
+		Source:      absPath(source),
+		Destination: absPath(destination),
+		path:        output,
+		reserved:    make(map[string]string),
+	}
+	os.Args = append(append([]string{os.Args[0]}, commandArgs...), "--dry-run")
+	return nil
+}
+
+// promptsAllowed reports whether a run may ask the user for a location: live runs and plans,
+// whose answers are saved in the plan, but not dry runs
+func promptsAllowed(dryRun bool) bool {
+	return !dryRun || activePlan != nil
+}
+
+// reserve claims target for source, reporting false when another planned file has it.
+// Without a plan every free path is available
+func (p *executionPlan) reserve(target, source string) bool {
+	if p == nil {
+		return true
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, taken := p.reserved[target]; taken {
+		return false
+	}
+	p.reserved[target] = source
+	return true
+}
+
+// plannedSource returns the file the plan already sends to target; "" when none or without a plan
+func (p *executionPlan) plannedSource(target string) string {
+	if p == nil {
+		return ""
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.reserved[target]
+}
+
+// add records a planned transfer of source to target, with the source's size, time and hash
+// so apply can tell whether it changed
+func (p *executionPlan) add(entry planEntry) {
+	if p == nil {
+		return
+	}
+	info, err := os.Stat(entry.Source)
+	if err != nil {
+		fmt.Printf("⚠️  Not planning %s: %v\n", filepath.Base(entry.Source), err)
+		return
+	}
+	hash, err := calculateFileHash(entry.Source)
+	if err != nil {
+		fmt.Printf("⚠️  Not planning %s: %v\n", filepath.Base(entry.Source), err)
+		return
+	}
+	entry.Source, entry.Target, entry.Of = absPath(entry.Source), absPath(entry.Target), absPath(entry.Of)
+	entry.Size, entry.ModTime, entry.Hash = info.Size(), info.ModTime(), hash
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.Entries = append(p.Entries, entry)
+}
+
+// addFiled records a media file filed at target by the path template
+func (p *executionPlan) addFiled(source, target, mode string, fields layoutFields) {
+	kind := "photo"
+	if isVideoFile(source) {
+		kind = "video"
+	}
+	dateLayout := "2006-01-02"
+	if fields.HasTime {
+		dateLayout = "2006-01-02 15:04"
+	}
+	p.add(planEntry{
+		Source:  source,
+		Target:  target,
+		Mode:    mode,
+		Kind:    kind,
+		Date:    fields.Date.Format(dateLayout),
+		Country: fields.Country,
+		City:    fields.City,
+		Reason:  fields.Reason,
+	})
+}
+
+// addDuplicate records a file whose content the library already has at target. Applying it
+// journals the import and, with action delete, removes the file
+func (p *executionPlan) addDuplicate(source, target, action string) {
+	p.add(planEntry{
+		Source: source,
+		Target: target,
+		Kind:   planKindDuplicate,
+		Action: action,
+		Reason: "already in the library",
+	})
+}
+
+// writeLocation attaches a location to write to the planned file at target, reporting false
+// when no plan is being made
+func (p *executionPlan) writeLocation(target string, location inferredLocation) bool {
+	if p == nil {
+		return false
+	}
+	target = absPath(target)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for i := len(p.Entries) - 1; i >= 0; i-- {
+		if p.Entries[i].Target == target {
+			p.Entries[i].Location = &location
+			break
+		}
+	}
+	return true
+}
+
+// save writes the plan file and says how to apply it
+func (p *executionPlan) save() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err == nil {
+		err = os.WriteFile(p.path, data, 0644)
+	}
+	if err != nil {
+		fmt.Printf("❌ Failed to write the plan to %s: %v\n", p.path, err)
+		return
+	}
+	fmt.Printf("\n📝 Plan: %d transfer(s) written to %s - review or edit it, then: ./photo-meta apply %s\n",
+		len(p.Entries), p.path, p.path)
+}
+
+// readPlan loads a plan file
+func readPlan(path string) (*executionPlan, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var p executionPlan
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("invalid plan file: %v", err)
+	}
+	if p.Version != planVersion {
+		return nil, fmt.Errorf("plan file version %d is not supported (expected %d)", p.Version, planVersion)
+	}
+	return &p, nil
+}
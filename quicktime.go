@@ -0,0 +1,363 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// QuickTime metadata keys read from moov/meta/keys
+const (
+	quicktimeKeyLocation     = "com.apple.quicktime.location.ISO6709"
+	quicktimeKeyCreationDate = "com.apple.quicktime.creationdate"
+	quicktimeKeyMake         = "com.apple.quicktime.make"
+	quicktimeKeyModel        = "com.apple.quicktime.model"
+	quicktimeKeyContentID    = "com.apple.quicktime.content.identifier"
+)
+
+// maxQuickTimeValueSize caps how much of a single metadata value is read into memory
+const maxQuickTimeValueSize = 1 << 20
+
+// quicktimeEpoch is the reference time for mvhd timestamps (seconds since 1904-01-01 UTC)
+var quicktimeEpoch = time.Date(1904, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// iso6709Pattern matches the leading latitude/longitude pair of an ISO 6709 string like "+39.5750+002.6500+050.000/"
+var iso6709Pattern = regexp.MustCompile(`^([+-]\d+(?:\.\d+)?)([+-]\d+(?:\.\d+)?)`)
+
+// quicktimeDateLayouts are the formats used by creationdate and ©day values
+var quicktimeDateLayouts = []string{
+	"2006-01-02T15:04:05-0700",
+	"2006-01-02T15:04:05Z07:00",
+	"2006-01-02T15:04:05.000-0700",
+	"2006-01-02T15:04:05.000Z07:00",
+}
+
+// quicktimeMetadataReader reads location and creation dates from QuickTime/MP4 containers
+type quicktimeMetadataReader struct{}
+
+func (r *quicktimeMetadataReader) Name() string { return "quicktime" }
+
+// Supports reports whether the file extension is a QuickTime/ISO MP4 container
+func (r *quicktimeMetadataReader) Supports(path string) bool {
+	return isQuickTimeFile(path)
+}
+
+// isQuickTimeFile checks for the QuickTime-family container extensions
+func isQuickTimeFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".mov", ".qt", ".mp4", ".m4v", ".3gp", ".3g2":
+		return true
+	}
+	return false
+}
+
+// Read walks moov for mvhd, the Apple keys/ilst metadata and udta/©xyz
+func (r *quicktimeMetadataReader) Read(path string) (*MediaMetadata, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %v", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat file: %v", err)
+	}
+
+	topLevel, err := readBMFFBoxes(file, 0, info.Size())
+	if err != nil || len(topLevel) == 0 {
+		return nil, errUnsupportedFormat
+	}
+	moov, found := findBMFFBox(topLevel, "moov")
+	if !found {
+		return nil, errUnsupportedFormat
+	}
+
+	children, err := readBMFFBoxes(file, moov.offset, moov.offset+moov.size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse moov: %v", err)
+	}
+
+	metadata := &MediaMetadata{Source: "quicktime"}
+
+	for _, child := range children {
+		switch child.boxType {
+		case "mvhd":
+			parseQuickTimeMvhd(file, child, metadata)
+		case "meta":
+			parseQuickTimeMeta(file, child, metadata)
+		case "udta":
+			parseQuickTimeUdta(file, child, metadata)
+		}
+	}
+
+	return metadata, nil
+}
+
+// parseQuickTimeMvhd records the movie creation time, which QuickTime stores in UTC
+func parseQuickTimeMvhd(r io.ReaderAt, box bmffBox, metadata *MediaMetadata) {
+	payload, err := readBMFFPayload(r, box, 1024)
+	if err != nil {
+		return
+	}
+
+	cursor := &bmffCursor{data: payload}
+	version := cursor.uint8()
+	cursor.bytes(3) // flags
+
+	var seconds uint64
+	if version == 1 {
+		seconds = cursor.uint64()
+	} else {
+		seconds = uint64(cursor.uint32())
+	}
+	if cursor.err != nil || seconds == 0 {
+		// Zero means the writer never set it
+		return
+	}
+
+	created := quicktimeEpoch.Add(time.Duration(seconds) * time.Second)
+	if created.Year() < 1970 {
+		return
+	}
+
+	metadata.CreateDate = created.Format("2006:01:02 15:04:05")
+	metadata.CreateDateIsUTC = true
+}
+
+// parseQuickTimeMeta reads the Apple mdta keys and their ilst values
+func parseQuickTimeMeta(r io.ReaderAt, box bmffBox, metadata *MediaMetadata) {
+	start := box.offset
+	end := box.offset + box.size
+
+	// QuickTime meta boxes have no version/flags, ISO (MP4) ones do - peek for the hdlr/keys child
+	peek := make([]byte, 8)
+	if box.size >= 8 {
+		if _, err := r.ReadAt(peek, start); err == nil {
+			childType := string(peek[4:8])
+			if childType != "hdlr" && childType != "keys" && childType != "ilst" {
+				start += 4
+			}
+		}
+	}
+
+	children, err := readBMFFBoxes(r, start, end)
+	if err != nil {
+		return
+	}
+
+	keysBox, hasKeys := findBMFFBox(children, "keys")
+	ilstBox, hasIlst := findBMFFBox(children, "ilst")
+	if !hasKeys || !hasIlst {
+		return
+	}
+
+	keys := parseQuickTimeKeys(r, keysBox)
+	if len(keys) == 0 {
+		return
+	}
+
+	items, err := readBMFFBoxes(r, ilstBox.offset, ilstBox.offset+ilstBox.size)
+	if err != nil {
+		return
+	}
+
+	for _, item := range items {
+		// ilst item types are 1-based indexes into the keys table
+		index := int(binary.BigEndian.Uint32([]byte(item.boxType)))
+		if index < 1 || index > len(keys) {
+			continue
+		}
+
+		value, ok := readQuickTimeDataValue(r, item)
+		if !ok {
+			continue
+		}
+		applyQuickTimeValue(keys[index-1], value, metadata)
+	}
+}
+
+// parseQuickTimeKeys decodes the keys box into a list of key names
+func parseQuickTimeKeys(r io.ReaderAt, box bmffBox) []string {
+	payload, err := readBMFFPayload(r, box, maxQuickTimeValueSize)
+	if err != nil {
+		return nil
+	}
+
+	cursor := &bmffCursor{data: payload}
+	cursor.bytes(4) // version and flags
+	count := cursor.uint32()
+
+	var keys []string
+	for i := uint32(0); i < count && cursor.err == nil; i++ {
+		size := int(cursor.uint32())
+		cursor.bytes(4) // namespace, normally "mdta"
+		if size < 8 {
+			break
+		}
+		name := cursor.bytes(size - 8)
+		if cursor.err != nil {
+			break
+		}
+		keys = append(keys, string(name))
+	}
+	return keys
+}
+
+// readQuickTimeDataValue returns the payload of the data box inside an ilst item
+func readQuickTimeDataValue(r io.ReaderAt, item bmffBox) (string, bool) {
+	children, err := readBMFFBoxes(r, item.offset, item.offset+item.size)
+	if err != nil {
+		return "", false
+	}
+	dataBox, found := findBMFFBox(children, "data")
+	if !found {
+		return "", false
+	}
+
+	payload, err := readBMFFPayload(r, dataBox, maxQuickTimeValueSize)
+	if err != nil || len(payload) < 8 {
+		return "", false
+	}
+
+	// Skip the type indicator and locale - the keys we read are all UTF-8 strings
+	return strings.TrimSpace(strings.TrimRight(string(payload[8:]), "\x00")), true
+}
+
+// applyQuickTimeValue stores a keys/ilst value in the matching metadata field
+func applyQuickTimeValue(key, value string, metadata *MediaMetadata) {
+	switch key {
+	case quicktimeKeyLocation:
+		if lat, lon, ok := parseISO6709(value); ok {
+			metadata.HasGPS = true
+			metadata.Latitude = lat
+			metadata.Longitude = lon
+		}
+	case quicktimeKeyCreationDate:
+		applyQuickTimeCreationDate(value, metadata)
+	case quicktimeKeyMake:
+		metadata.Make = value
+	case quicktimeKeyModel:
+		metadata.Model = value
+	case quicktimeKeyContentID:
+		metadata.ContentIdentifier = value
+	}
+}
+
+// applyQuickTimeCreationDate keeps the local wall-clock time and its offset from an ISO 8601 date
+func applyQuickTimeCreationDate(value string, metadata *MediaMetadata) {
+	for _, layout := range quicktimeDateLayouts {
+		if created, err := time.Parse(layout, value); err == nil {
+			metadata.DateTimeOriginal = created.Format("2006:01:02 15:04:05")
+			metadata.OffsetTimeOriginal = created.Format("-07:00")
+			return
+		}
+	}
+}
+
+// parseQuickTimeUdta reads the legacy ©xyz location and ©day date atoms
+func parseQuickTimeUdta(r io.ReaderAt, box bmffBox, metadata *MediaMetadata) {
+	children, err := readBMFFBoxes(r, box.offset, box.offset+box.size)
+	if err != nil {
+		return
+	}
+
+	for _, child := range children {
+		if child.boxType != "\xa9xyz" && child.boxType != "\xa9day" {
+			continue
+		}
+
+		payload, err := readBMFFPayload(r, child, 1024)
+		if err != nil || len(payload) < 4 {
+			continue
+		}
+
+		// Classic user data text: 16-bit length, 16-bit language code, then the text
+		length := int(binary.BigEndian.Uint16(payload[0:2]))
+		text := payload[4:]
+		if length < len(text) {
+			text = text[:length]
+		}
+		value := strings.TrimSpace(string(bytes.TrimRight(text, "\x00")))
+
+		switch child.boxType {
+		case "\xa9xyz":
+			if metadata.HasGPS {
+				continue
+			}
+			if lat, lon, ok := parseISO6709(value); ok {
+				metadata.HasGPS = true
+				metadata.Latitude = lat
+				metadata.Longitude = lon
+			}
+		case "\xa9day":
+			if metadata.DateTimeOriginal == "" {
+				applyQuickTimeCreationDate(value, metadata)
+			}
+		}
+	}
+}
+
+// parseISO6709 parses the latitude and longitude of an ISO 6709 location string
+// Degrees may be written as D.D, DDMM.M or DDMMSS.S
+func parseISO6709(value string) (float64, float64, bool) {
+	match := iso6709Pattern.FindStringSubmatch(strings.TrimSpace(value))
+	if match == nil {
+		return 0, 0, false
+	}
+
+	lat, latOK := parseISO6709Component(match[1], 2)
+	lon, lonOK := parseISO6709Component(match[2], 3)
+	if !latOK || !lonOK {
+		return 0, 0, false
+	}
+	if lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+		return 0, 0, false
+	}
+	return lat, lon, true
+}
+
+// parseISO6709Component converts one signed ISO 6709 component with the given number of degree digits
+func parseISO6709Component(component string, degreeDigits int) (float64, bool) {
+	sign := 1.0
+	if component[0] == '-' {
+		sign = -1.0
+	}
+	digits := component[1:]
+
+	integerPart := digits
+	if dot := strings.Index(digits, "."); dot >= 0 {
+		integerPart = digits[:dot]
+	}
+
+	value, err := strconv.ParseFloat(digits, 64)
+	if err != nil {
+		return 0, false
+	}
+
+	switch len(integerPart) {
+	case degreeDigits:
+		// Decimal degrees
+	case degreeDigits + 2:
+		// Degrees and decimal minutes
+		degrees := float64(int(value / 100))
+		value = degrees + (value-degrees*100)/60
+	case degreeDigits + 4:
+		// Degrees, minutes and decimal seconds
+		degrees := float64(int(value / 10000))
+		minutes := float64(int((value - degrees*10000) / 100))
+		seconds := value - degrees*10000 - minutes*100
+		value = degrees + minutes/60 + seconds/3600
+	default:
+		return 0, false
+	}
+
+	return sign * value, true
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestQuickTimeMetadataReaderRead(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		want MediaMetadata
+	}{
+		{
+			name: "Apple keys metadata",
+			path: "testdata/quicktime/keys.mov",
+			want: MediaMetadata{
+				Source:             "quicktime",
+				HasGPS:             true,
+				Latitude:           48.8582,
+				Longitude:          2.2945,
+				DateTimeOriginal:   "2023:07:14 18:30:05",
+				OffsetTimeOriginal: "+02:00",
+				CreateDate:         "2023:07:14 16:30:05",
+				CreateDateIsUTC:    true,
+				Make:               "Apple",
+				Model:              "iPhone 13",
+			},
+		},
+		{
+			name: "udta location after mdat",
+			path: "testdata/quicktime/udta.mp4",
+			want: MediaMetadata{
+				Source:          "quicktime",
+				HasGPS:          true,
+				Latitude:        40.7128,
+				Longitude:       -74.006,
+				CreateDate:      "2023:07:14 16:30:05",
+				CreateDateIsUTC: true,
+			},
+		},
+		{
+			name: "unset creation time",
+			path: "testdata/quicktime/no-metadata.mp4",
+			want: MediaMetadata{Source: "quicktime"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := (&quicktimeMetadataReader{}).Read(tt.path)
+			if err != nil {
+				t.Fatalf("Read(%s) failed: %v", tt.path, err)
+			}
+			assertMetadata(t, got, tt.want)
+		})
+	}
+}
+
+func TestParseISO6709(t *testing.T) {
+	tests := []struct {
+		value    string
+		lat, lon float64
+		ok       bool
+	}{
+		{"+48.8582+002.2945+035.000/", 48.8582, 2.2945, true},
+		{"-33.8688+151.2093/", -33.8688, 151.2093, true},
+		{"+4042.7680-07400.3600/", 40.7128, -74.006, true},
+		{"+404246.08-0740021.6/", 40.712800, -74.006, true},
+		{"+91.0000+000.0000/", 0, 0, false},
+		{"+48.8582/", 0, 0, false},
+		{"", 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		lat, lon, ok := parseISO6709(tt.value)
+		if ok != tt.ok || math.Abs(lat-tt.lat) > 1e-6 || math.Abs(lon-tt.lon) > 1e-6 {
+			t.Errorf("parseISO6709(%q) = %f, %f, %v, want %f, %f, %v", tt.value, lat, lon, ok, tt.lat, tt.lon, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// rawJPEGMaxTimeGap is how far apart the capture times of a RAW and a JPEG with the same
+// base name may be for them to count as one shot
+const rawJPEGMaxTimeGap = 2 * time.Second
+
+// Policies for RAW+JPEG pairs (config raw_jpeg_pair)
+const (
+	rawPairTogether     = "together"      // RAW next to the JPEG under the same name (default)
+	rawPairRawSubfolder = "raw_subfolder" // RAW in a RAW/ folder below the JPEG's folder
+	rawPairSeparate     = "separate"      // No pairing - each file is placed on its own
+)
+
+// rawSubfolderName is the folder RAW files go to under the raw_subfolder policy
+const rawSubfolderName = "RAW"
+
+// rawExtensions are the camera RAW formats that can be paired with a JPEG
+var rawExtensions = map[string]bool{
+	".dng": true, ".cr2": true, ".cr3": true, ".nef": true, ".arw": true,
+	".orf": true, ".rw2": true, ".raf": true, ".srw": true, ".pef": true,
+	".3fr": true, ".fff": true, ".iiq": true, ".k25": true, ".kdc": true,
+	".dcr": true, ".mrw": true, ".raw": true,
+}
+
+// isRawFile checks whether a file is a camera RAW
+func isRawFile(path string) bool {
+	return rawExtensions[strings.ToLower(filepath.Ext(path))]
+}
+
+// isRawPairPartner checks for the processed formats cameras write alongside RAW
+func isRawPairPartner(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jpg", ".jpeg", ".heic", ".heif", ".hif":
+		return true
+	}
+	return false
+}
+
+// pairRawJPEG finds RAW+JPEG pairs among paths and registers each RAW as a companion of
+// its JPEG. It returns paths without the paired RAW files
+func pairRawJPEG(paths []string) []string {
+	if GetAppConfig().RawJPEGPair == rawPairSeparate {
+		return paths
+	}
+
+	// Group candidates by directory and lower-case base name
+	type pairKey struct{ dir, base string }
+	partners := make(map[pairKey][]string)
+	raws := make(map[pairKey][]string)
+	for _, path := range paths {
+		key := pairKey{filepath.Dir(path), mediaBaseName(path)}
+		if isRawFile(path) {
+			raws[key] = append(raws[key], path)
+		} else if isRawPairPartner(path) {
+			partners[key] = append(partners[key], path)
+		}
+	}
+
+	pairedRaws := make(map[string]bool)
+	for key, rawFiles := range raws {
+		candidates := partners[key]
+		for _, raw := range rawFiles {
+			for i, partner := range candidates {
+				if partner == "" || !captureTimesMatch(raw, partner, rawJPEGMaxTimeGap) {
+					continue
+				}
+				registerCompanion(partner, raw)
+				pairedRaws[raw] = true
+				candidates[i] = "" // One RAW per JPEG
+				break
+			}
+		}
+	}
+
+	if len(pairedRaws) > 0 {
+		fmt.Printf("🔗 Paired %d RAW+JPEG shot(s) - each RAW will follow its JPEG\n", len(pairedRaws))
+	}
+	return removePairedPaths(paths, pairedRaws)
+}
+
+// rawCompanionTarget names a RAW after its JPEG's final path, in the same folder or in
+// a RAW/ subfolder depending on raw_jpeg_pair
+func rawCompanionTarget(rawPath, jpegFinalPath string) string {
+	dir := filepath.Dir(jpegFinalPath)
+	if GetAppConfig().RawJPEGPair == rawPairRawSubfolder {
+		dir = filepath.Join(dir, rawSubfolderName)
+	}
+
+	jpegName := filepath.Base(jpegFinalPath)
+	base := strings.TrimSuffix(jpegName, filepath.Ext(jpegName))
+	return filepath.Join(dir, base+filepath.Ext(rawPath))
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sidecarExtensions are the companion files that travel with a media file
+// (.xmp edits/metadata, Apple .aae adjustments, .thm video thumbnails)
+var sidecarExtensions = map[string]bool{
+	".xmp": true,
+	".aae": true,
+	".thm": true,
+}
+
+// maxXMPSidecarSize caps how much of an XMP sidecar is read into memory
+const maxXMPSidecarSize = 4 << 20
+
+// xmpDateLayouts are the ISO 8601 forms XMP dates are written in
+var xmpDateLayouts = []string{
+	"2006-01-02T15:04:05Z07:00",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
+
+// xmpGPSPattern matches XMP GPS coordinates like "41,24.5N" or "41,24,30.2N"
+var xmpGPSPattern = regexp.MustCompile(`^(\d+),(\d+(?:\.\d+)?)(?:,(\d+(?:\.\d+)?))?([NSEW])$`)
+
+// isSidecarFile checks whether a file is a sidecar rather than media
+func isSidecarFile(path string) bool {
+	return sidecarExtensions[strings.ToLower(filepath.Ext(path))]
+}
+
+// sidecarDirCacheLimit bounds the per-directory sidecar listing cache; it is reset when full
+const sidecarDirCacheLimit = 1024
+
+// sidecarDirEntry remembers the sidecar names in a directory along with its modification time
+type sidecarDirEntry struct {
+	names   []string
+	modTime time.Time
+}
+
+// sidecarDirCache avoids listing a large directory again for every media file in it
+var sidecarDirCache = struct {
+	sync.Mutex
+	entries map[string]sidecarDirEntry
+}{entries: make(map[string]sidecarDirEntry)}
+
+// findSidecars returns the sidecars stored next to a media file, matching both
+// IMG_1234.xmp and IMG_1234.CR2.xmp naming (case-insensitive)
+func findSidecars(mediaPath string) []string {
+	dir := filepath.Dir(mediaPath)
+	name := strings.ToLower(filepath.Base(mediaPath))
+	base := strings.TrimSuffix(name, filepath.Ext(name))
+
+	var sidecars []string
+	for _, entryName := range listSidecarNames(dir) {
+		lowerName := strings.ToLower(entryName)
+		stem := strings.TrimSuffix(lowerName, filepath.Ext(lowerName))
+		if stem == base || stem == name {
+			sidecars = append(sidecars, filepath.Join(dir, entryName))
+		}
+	}
+	return sidecars
+}
+
+// listSidecarNames returns the names of the sidecar files in a directory, re-listing it only when it changes
+func listSidecarNames(dir string) []string {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil
+	}
+
+	sidecarDirCache.Lock()
+	entry, exists := sidecarDirCache.entries[dir]
+	sidecarDirCache.Unlock()
+	if exists && entry.modTime.Equal(info.ModTime()) {
+		return entry.names
+	}
+
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, dirEntry := range dirEntries {
+		if !dirEntry.IsDir() && isSidecarFile(dirEntry.Name()) {
+			names = append(names, dirEntry.Name())
+		}
+	}
+
+	sidecarDirCache.Lock()
+	if len(sidecarDirCache.entries) >= sidecarDirCacheLimit {
+		sidecarDirCache.entries = make(map[string]sidecarDirEntry)
+	}
+	sidecarDirCache.entries[dir] = sidecarDirEntry{names: names, modTime: info.ModTime()}
+	sidecarDirCache.Unlock()
+
+	return names
+}
+
+// sidecarTargetPath names a sidecar after the media file's final path, keeping
+// the sidecar's own naming style and extension
+func sidecarTargetPath(sidecarPath, mediaSourcePath, mediaFinalPath string) string {
+	sidecarName := filepath.Base(sidecarPath)
+	sidecarExt := filepath.Ext(sidecarName)
+	stem := strings.TrimSuffix(sidecarName, sidecarExt)
+
+	finalName := filepath.Base(mediaFinalPath)
+	if strings.EqualFold(stem, filepath.Base(mediaSourcePath)) {
+		// IMG_1234.CR2.xmp -> 2023-05-01-madrid.CR2.xmp
+		return filepath.Join(filepath.Dir(mediaFinalPath), finalName+sidecarExt)
+	}
+	// IMG_1234.xmp -> 2023-05-01-madrid.xmp
+	finalBase := strings.TrimSuffix(finalName, filepath.Ext(finalName))
+	return filepath.Join(filepath.Dir(mediaFinalPath), finalBase+sidecarExt)
+}
+
+// transferSidecars moves, copies or links each sidecar as mode says, never overwriting an existing file
+func transferSidecars(sidecars []string, mediaSourcePath, mediaFinalPath string, dryRun bool, mode string) {
+	for _, sidecar := range sidecars {
+		target := sidecarTargetPath(sidecar, mediaSourcePath, mediaFinalPath)
+
+		if dryRun {
+			fmt.Printf("📎 [DRY RUN] Sidecar would be %s to: %s\n", transferVerb(mode), target)
+			activePlan.add(planEntry{Source: sidecar, Target: target, Mode: mode, Kind: "sidecar", Of: mediaSourcePath, Reason: "sidecar of " + filepath.Base(mediaSourcePath)})
+			continue
+		}
+
+		if _, err := os.Stat(target); err == nil {
+			fmt.Printf("⚠️  Sidecar %s not %s: %s already exists\n", filepath.Base(sidecar), transferVerb(mode), target)
+			continue
+		}
+
+		if err := transferFile(sidecar, target, mode); err != nil {
+			fmt.Printf("⚠️  Sidecar %s not %s: %v\n", filepath.Base(sidecar), transferVerb(mode), err)
+			continue
+		}
+		fmt.Printf("📎 Sidecar %s to: %s\n", transferVerb(mode), target)
+	}
+}
+
+// mergeSidecarMetadata fills GPS and capture dates missing from a media file's
+// metadata with the values from its XMP or THM sidecars
+func mergeSidecarMetadata(mediaPath string, metadata *MediaMetadata) *MediaMetadata {
+	if metadata != nil && metadata.HasGPS && metadata.DateTimeOriginal != "" {
+		return metadata
+	}
+
+	for _, sidecar := range findSidecars(mediaPath) {
+		var sidecarMetadata *MediaMetadata
+		var err error
+
+		switch strings.ToLower(filepath.Ext(sidecar)) {
+		case ".xmp":
+			sidecarMetadata, err = readXMPSidecar(sidecar)
+		case ".thm":
+			// THM thumbnails are small JPEGs carrying the video's EXIF
+			sidecarMetadata, err = (&exifMetadataReader{}).Read(sidecar)
+		default:
+			continue
+		}
+		if err != nil || sidecarMetadata == nil {
+			continue
+		}
+
+		if metadata == nil {
+			metadata = &MediaMetadata{Source: "sidecar"}
+		}
+		fillMissingMetadata(metadata, sidecarMetadata)
+	}
+
+	return metadata
+}
+
+// fillMissingMetadata copies GPS, dates and description from other into the fields metadata is missing
+func fillMissingMetadata(metadata, other *MediaMetadata) {
+	if !metadata.HasGPS && other.HasGPS {
+		metadata.HasGPS = true
+		metadata.Latitude = other.Latitude
+		metadata.Longitude = other.Longitude
+		if metadata.GPSDateStamp == "" {
+			metadata.GPSDateStamp = other.GPSDateStamp
+			metadata.GPSTimeStamp = other.GPSTimeStamp
+		}
+	}
+	if metadata.DateTimeOriginal == "" && other.DateTimeOriginal != "" {
+		metadata.DateTimeOriginal = other.DateTimeOriginal
+		metadata.OffsetTimeOriginal = other.OffsetTimeOriginal
+	}
+	if metadata.CreateDate == "" && other.CreateDate != "" {
+		metadata.CreateDate = other.CreateDate
+		metadata.CreateDateIsUTC = other.CreateDateIsUTC
+	}
+	if metadata.ImageDescription == "" {
+		metadata.ImageDescription = other.ImageDescription
+	}
+}
+
+// readXMPSidecar extracts GPS and dates from an XMP packet
+func readXMPSidecar(path string) (*MediaMetadata, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() > maxXMPSidecarSize {
+		return nil, fmt.Errorf("XMP sidecar too large (%d bytes)", info.Size())
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read XMP sidecar: %v", err)
+	}
+	return parseXMP(string(data)), nil
+}
+
+// parseXMP reads the exif:/xmp:/photoshop: properties photo-meta uses from an XMP packet
+func parseXMP(packet string) *MediaMetadata {
+	metadata := &MediaMetadata{Source: "xmp"}
+
+	lat, latOK := parseXMPCoordinate(xmpProperty(packet, "exif:GPSLatitude"))
+	lon, lonOK := parseXMPCoordinate(xmpProperty(packet, "exif:GPSLongitude"))
+	if latOK && lonOK && lat >= -90 && lat <= 90 && lon >= -180 && lon <= 180 {
+		metadata.HasGPS = true
+		metadata.Latitude = lat
+		metadata.Longitude = lon
+	}
+
+	for _, property := range []string{"exif:DateTimeOriginal", "photoshop:DateCreated"} {
+		if date, hasZone, ok := parseXMPDate(xmpProperty(packet, property)); ok {
+			metadata.DateTimeOriginal = date.Format("2006:01:02 15:04:05")
+			if hasZone {
+				metadata.OffsetTimeOriginal = date.Format("-07:00")
+			}
+			break
+		}
+	}
+	if date, _, ok := parseXMPDate(xmpProperty(packet, "xmp:CreateDate")); ok {
+		metadata.CreateDate = date.Format("2006:01:02 15:04:05")
+	}
+	if date, hasZone, ok := parseXMPDate(xmpProperty(packet, "exif:GPSTimeStamp")); ok && hasZone {
+		// exif:GPSTimeStamp is a full date-time in XMP; the EXIF fields hold it in UTC
+		metadata.GPSDateStamp = date.UTC().Format("2006:01:02")
+		metadata.GPSTimeStamp = date.UTC().Format("15:04:05")
+	}
+
+	return metadata
+}
+
+// xmpPropertyPattern is the pair of expressions that find one property: as an attribute and as an element
+type xmpPropertyPattern struct {
+	attribute *regexp.Regexp
+	element   *regexp.Regexp
+}
+
+// xmpPropertyPatterns caches the compiled xmpPropertyPattern of each property name
+var xmpPropertyPatterns sync.Map
+
+// xmpProperty returns a simple property written either as an attribute or as an element
+func xmpProperty(packet, name string) string {
+	cached, ok := xmpPropertyPatterns.Load(name)
+	if !ok {
+		cached, _ = xmpPropertyPatterns.LoadOrStore(name, &xmpPropertyPattern{
+			attribute: regexp.MustCompile(regexp.QuoteMeta(name) + `\s*=\s*["']([^"']*)["']`),
+			element:   regexp.MustCompile(`<` + regexp.QuoteMeta(name) + `>([^<]*)</`),
+		})
+	}
+	pattern := cached.(*xmpPropertyPattern)
+
+	if match := pattern.attribute.FindStringSubmatch(packet); match != nil {
+		return strings.TrimSpace(match[1])
+	}
+	if match := pattern.element.FindStringSubmatch(packet); match != nil {
+		return strings.TrimSpace(match[1])
+	}
+	return ""
+}
+
+// parseXMPCoordinate parses "DDD,MM.mmk", "DDD,MM,SSk" or plain decimal degrees
+func parseXMPCoordinate(value string) (float64, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if decimal, err := strconv.ParseFloat(value, 64); err == nil {
+		return decimal, true
+	}
+
+	match := xmpGPSPattern.FindStringSubmatch(strings.ToUpper(value))
+	if match == nil {
+		return 0, false
+	}
+	degrees, _ := strconv.ParseFloat(match[1], 64)
+	minutes, _ := strconv.ParseFloat(match[2], 64)
+	seconds := 0.0
+	if match[3] != "" {
+		seconds, _ = strconv.ParseFloat(match[3], 64)
+	}
+
+	coordinate := degrees + minutes/60 + seconds/3600
+	if match[4] == "S" || match[4] == "W" {
+		coordinate = -coordinate
+	}
+	return coordinate, true
+}
+
+// parseXMPDate parses an XMP date, reporting whether it carried a UTC offset
+func parseXMPDate(value string) (time.Time, bool, bool) {
+	if value == "" {
+		return time.Time{}, false, false
+	}
+
+	// Drop fractional seconds, which the layouts below do not cover
+	if dot := strings.Index(value, "."); dot > 0 {
+		end := dot + 1
+		for end < len(value) && value[end] >= '0' && value[end] <= '9' {
+			end++
+		}
+		value = value[:dot] + value[end:]
+	}
+
+	for _, layout := range xmpDateLayouts {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			return parsed, strings.HasSuffix(layout, "Z07:00"), true
+		}
+	}
+	return time.Time{}, false, false
+}
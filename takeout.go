@@ -0,0 +1,331 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// takeoutMaxJSONNameLength is the longest filename Google Takeout writes; longer
+// sidecar names are truncated before ".json" is appended
+const takeoutMaxJSONNameLength = 51
+
+// takeoutSupplementalSuffix is inserted before ".json" by newer Takeout exports
+const takeoutSupplementalSuffix = ".supplemental-metadata"
+
+// takeoutMetadataEnabled makes readMetadata consult Takeout JSON sidecars; set by the takeout command
+var takeoutMetadataEnabled bool
+
+// takeoutDuplicatePattern matches the "(1)" counter Takeout adds to repeated filenames
+var takeoutDuplicatePattern = regexp.MustCompile(`\(\d+\)$`)
+
+// takeoutSidecar is the part of a Takeout <name>.json file photo-meta uses
+type takeoutSidecar struct {
+	Title          string           `json:"title"`
+	PhotoTakenTime takeoutTimestamp `json:"photoTakenTime"`
+	CreationTime   takeoutTimestamp `json:"creationTime"`
+	GeoData        takeoutGeoData   `json:"geoData"`
+	GeoDataExif    takeoutGeoData   `json:"geoDataExif"`
+}
+
+// takeoutTimestamp is a Unix time in seconds, stored as a string
+type takeoutTimestamp struct {
+	Timestamp string `json:"timestamp"`
+}
+
+// takeoutGeoData is a Takeout location; 0,0 means none was recorded
+type takeoutGeoData struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// valid reports whether the location was actually recorded
+func (g takeoutGeoData) valid() bool {
+	return (g.Latitude != 0 || g.Longitude != 0) &&
+		math.Abs(g.Latitude) <= 90 && math.Abs(g.Longitude) <= 180
+}
+
+// time converts the timestamp, returning false when it is missing
+func (t takeoutTimestamp) time() (time.Time, bool) {
+	seconds, err := strconv.ParseInt(strings.TrimSpace(t.Timestamp), 10, 64)
+	if err != nil || seconds <= 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(seconds, 0).UTC(), true
+}
+
+// takeoutJSONDirCacheLimit bounds the per-directory JSON listing cache; it is reset when full
+const takeoutJSONDirCacheLimit = 1024
+
+// takeoutJSONDirCache holds the .json names of each album folder, listed once per run. Unlike
+// the sidecar cache it ignores the folder's modification time: filing the media changes it, but
+// the run never adds or removes a .json file
+var takeoutJSONDirCache = struct {
+	sync.Mutex
+	names map[string][]string
+}{names: make(map[string][]string)}
+
+// listTakeoutJSONNames returns the names of the .json files in a directory
+func listTakeoutJSONNames(dir string) ([]string, bool) {
+	takeoutJSONDirCache.Lock()
+	names, exists := takeoutJSONDirCache.names[dir]
+	takeoutJSONDirCache.Unlock()
+	if exists {
+		return names, true
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, false
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.EqualFold(filepath.Ext(entry.Name()), ".json") {
+			names = append(names, entry.Name())
+		}
+	}
+
+	takeoutJSONDirCache.Lock()
+	if len(takeoutJSONDirCache.names) >= takeoutJSONDirCacheLimit {
+		takeoutJSONDirCache.names = make(map[string][]string)
+	}
+	takeoutJSONDirCache.names[dir] = names
+	takeoutJSONDirCache.Unlock()
+
+	return names, true
+}
+
+// findTakeoutJSON locates the Takeout sidecar for a media file, allowing for the
+// truncated names, "(1)" duplicate counters, "-edited" copies and supplemental-metadata naming
+func findTakeoutJSON(mediaPath string) (string, bool) {
+	dir := filepath.Dir(mediaPath)
+	jsonNames, ok := listTakeoutJSONNames(dir)
+	if !ok {
+		return "", false
+	}
+
+	name := filepath.Base(mediaPath)
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+
+	// IMG_1234(1).JPG -> IMG_1234.JPG with counter "(1)"; IMG_1234-edited.JPG uses IMG_1234.JPG's sidecar
+	counter := takeoutDuplicatePattern.FindString(base)
+	base = strings.TrimSuffix(base, counter)
+	base = strings.TrimSuffix(base, "-edited")
+	original := base + ext
+
+	bestScore := 0
+	bestPath := ""
+	for _, jsonName := range jsonNames {
+		score := takeoutJSONMatchScore(jsonName, original, base, counter, isVideoFile(mediaPath))
+		if score > bestScore {
+			bestScore = score
+			bestPath = filepath.Join(dir, jsonName)
+		}
+	}
+
+	return bestPath, bestScore > 0
+}
+
+// takeoutJSONMatchScore rates how well a JSON filename matches a media file (0 = no match)
+func takeoutJSONMatchScore(jsonName, original, base, counter string, isVideo bool) int {
+	stem := jsonName[:len(jsonName)-len(".json")]
+	truncated := len(jsonName) >= takeoutMaxJSONNameLength
+
+	// The counter sits right before ".json": IMG_1234.JPG(1).json, IMG_1234.JPG.supplemental-metadata(1).json
+	jsonCounter := takeoutDuplicatePattern.FindString(stem)
+	if jsonCounter != counter {
+		return 0
+	}
+	stem = strings.TrimSuffix(stem, jsonCounter)
+	lowerStem := strings.ToLower(stem)
+	lowerOriginal := strings.ToLower(original)
+
+	switch {
+	case lowerStem == lowerOriginal:
+		return 6
+	case lowerStem == lowerOriginal+takeoutSupplementalSuffix:
+		return 5
+	case truncated && len(stem) > len(original) && strings.HasPrefix(lowerOriginal+takeoutSupplementalSuffix, lowerStem):
+		// IMG_1234.JPG.supplemental-me.json
+		return 4
+	case truncated && strings.HasPrefix(lowerOriginal, lowerStem):
+		// A long filename cut down to fit the 51 character limit
+		return 3
+	case lowerStem == strings.ToLower(base):
+		return 2
+	case isVideo && strings.EqualFold(strings.TrimSuffix(stem, filepath.Ext(stem)), base):
+		// The MP4 half of a Live Photo shares the still's sidecar: IMG_1234.MP4 -> IMG_1234.HEIC.json
+		return 1
+	}
+	return 0
+}
+
+// readTakeoutJSON parses a Takeout sidecar into MediaMetadata
+func readTakeoutJSON(path string) (*MediaMetadata, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Takeout sidecar: %v", err)
+	}
+
+	var sidecar takeoutSidecar
+	if err := json.Unmarshal(data, &sidecar); err != nil {
+		return nil, fmt.Errorf("failed to parse Takeout sidecar %s: %v", filepath.Base(path), err)
+	}
+
+	metadata := &MediaMetadata{Source: "takeout"}
+
+	geo := sidecar.GeoData
+	if !geo.valid() {
+		geo = sidecar.GeoDataExif
+	}
+	if geo.valid() {
+		metadata.HasGPS = true
+		metadata.Latitude = geo.Latitude
+		metadata.Longitude = geo.Longitude
+	}
+
+	// photoTakenTime is UTC, so it is stored like a QuickTime CreateDate and localised from the coordinates
+	if taken, ok := sidecar.PhotoTakenTime.time(); ok {
+		metadata.CreateDate = taken.Format("2006:01:02 15:04:05")
+		metadata.CreateDateIsUTC = true
+	}
+
+	return metadata, nil
+}
+
+// mergeTakeoutMetadata fills GPS and capture dates missing from a media file with its Takeout sidecar
+func mergeTakeoutMetadata(mediaPath string, metadata *MediaMetadata) *MediaMetadata {
+	if metadata != nil && metadata.HasGPS && (metadata.DateTimeOriginal != "" || metadata.CreateDate != "") {
+		return metadata
+	}
+
+	jsonPath, found := findTakeoutJSON(mediaPath)
+	if !found {
+		return metadata
+	}
+	takeout, err := readTakeoutJSON(jsonPath)
+	if err != nil {
+		fmt.Printf("⚠️  %v\n", err)
+		return metadata
+	}
+
+	if metadata == nil {
+		return takeout
+	}
+	fillMissingMetadata(metadata, takeout)
+	return metadata
+}
+
+// writeTakeoutMetadata writes the GPS and capture date from each file's Takeout sidecar into
+// the file itself, so the values survive once the file leaves the Takeout folder
+func writeTakeoutMetadata(sourcePath string, dryRun bool) error {
+	written, skipped, failed := 0, 0, 0
+
+	err := filepath.Walk(sourcePath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !isMediaFile(path) {
+			return nil
+		}
+
+		jsonPath, found := findTakeoutJSON(path)
+		if !found {
+			return nil
+		}
+		takeout, err := readTakeoutJSON(jsonPath)
+		if err != nil {
+			fmt.Printf("⚠️  %v\n", err)
+			failed++
+			return nil
+		}
+
+		// Only write what the file is missing
+		embedded, err := readEmbeddedMetadata(path)
+		if err != nil {
+			embedded = &MediaMetadata{}
+		}
+		args := takeoutWriteArgs(path, embedded, takeout)
+		if len(args) == 0 {
+			skipped++
+			return nil
+		}
+
+		if dryRun {
+			fmt.Printf("✏️  [DRY RUN] Would write Takeout metadata to %s: %s\n", filepath.Base(path), strings.Join(args, " "))
+			written++
+			return nil
+		}
+
+		args = append([]string{"-overwrite_original"}, args...)
+		args = append(args, path)
+		err = activeJournal.edit(path, func() error {
+			stdout, stderr, err := GetExiftoolPool().Execute(args...)
+			if err == nil {
+				err = exiftoolWriteError(stdout, stderr)
+			}
+			return err
+		})
+		invalidateMetadataCache(path)
+		if err != nil {
+			fmt.Printf("❌ Failed to write Takeout metadata to %s: %v\n", filepath.Base(path), err)
+			failed++
+			return nil
+		}
+
+		fmt.Printf("✏️  Wrote Takeout metadata to %s\n", filepath.Base(path))
+		written++
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("📊 Takeout metadata: %d written, %d already complete, %d failed\n", written, skipped, failed)
+	return nil
+}
+
+// takeoutWriteArgs builds the exiftool assignments for the values the file lacks
+func takeoutWriteArgs(path string, embedded, takeout *MediaMetadata) []string {
+	var args []string
+
+	needsGPS := takeout.HasGPS && !embedded.HasGPS
+	needsDate := takeout.CreateDate != "" && embedded.DateTimeOriginal == "" && embedded.CreateDate == ""
+
+	if isQuickTimeFile(path) {
+		if needsDate {
+			// QuickTime dates are UTC
+			args = append(args, "-api", "QuickTimeUTC=1", fmt.Sprintf("-QuickTime:CreateDate=%s+00:00", takeout.CreateDate))
+		}
+		if needsGPS {
+			args = append(args, gpsWriteArgs(path, takeout.Latitude, takeout.Longitude)...)
+		}
+		return args
+	}
+	if isVideoFile(path) {
+		// Other video containers have no metadata exiftool can write
+		return nil
+	}
+
+	if needsDate {
+		merged := *embedded
+		fillMissingMetadata(&merged, takeout)
+		if local, err := dateFromMetadata(&merged); err == nil {
+			args = append(args,
+				fmt.Sprintf("-DateTimeOriginal=%s", local.Format("2006:01:02 15:04:05")),
+				fmt.Sprintf("-OffsetTimeOriginal=%s", local.Format("-07:00")),
+				fmt.Sprintf("-CreateDate=%s", local.Format("2006:01:02 15:04:05")))
+		}
+	}
+	if needsGPS {
+		args = append(args, gpsWriteArgs(path, takeout.Latitude, takeout.Longitude)...)
+	}
+	return args
+}
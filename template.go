@@ -0,0 +1,805 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// videoFolderName is the folder {video-folder} gives videos
+const videoFolderName = "VIDEO-FILES"
+
+// defaultPathTemplate is the library layout: [VIDEO-FILES/]YEAR/COUNTRY/CITY/YYYY-MM-DD[-HHMM]-city.ext
+const defaultPathTemplate = "{video-folder}/{year}/{country}/{city}/{date:2006-01-02}[-{time:1504}]-{city}{ext}"
+
+// templateToken describes a token: the pattern its values match when a path is read back,
+// whether it can render empty, and the layout it takes when it is a date or time
+type templateToken struct {
+	pattern       string
+	optional      bool
+	defaultLayout string
+}
+
+// templateTokens are the tokens a path_template may use
+var templateTokens = map[string]templateToken{
+	"year":         {pattern: `\d{4}`},
+	"month":        {pattern: `\d{2}`},
+	"day":          {pattern: `\d{2}`},
+	"month-name":   {pattern: `[A-Za-z]+`},
+	"date":         {defaultLayout: "2006-01-02"},
+	"time":         {optional: true, defaultLayout: "1504"}, // Only known when the name already carries it
+	"country":      {pattern: `[^/]+?`},
+	"city":         {pattern: `[^/]+?`},
+	"camera":       {pattern: `[^/]+?`, optional: true},
+	"kind":         {pattern: `photo|video`},
+	"video-folder": {pattern: regexp.QuoteMeta(videoFolderName), optional: true},
+	"name":         {pattern: `[^/]+?`},
+	"ext":          {pattern: `\.[^./]+`},
+	"seq":          {pattern: seqPattern, optional: true},
+}
+
+// seqPattern matches a duplicate suffix: a short content hash, numbered in the unlikely case two
+// files share one, or the plain number earlier versions gave duplicates
+const seqPattern = `[0-9a-f]{8}(?:-\d+)?|\d+`
+
+// templatePart is literal text, a token, or an optional [...] group of both
+type templatePart struct {
+	literal string
+	token   string
+	layout  string
+	group   []templatePart
+}
+
+// key identifies a token together with its layout, e.g. date:2006-01-02
+func (p templatePart) key() string {
+	if p.layout == "" {
+		return p.token
+	}
+	return p.token + ":" + p.layout
+}
+
+// pathTemplate is a parsed path_template: folder segments followed by the file name
+type pathTemplate struct {
+	source   string
+	segments [][]templatePart
+	hasSeq   bool
+
+	pattern    *regexp.Regexp // Matches a whole path relative to the destination
+	dirPattern *regexp.Regexp // Matches its folder, with a trailing slash
+	keys       []string       // Token key of each pattern group
+}
+
+var (
+	activePathTemplate     *pathTemplate
+	activePathTemplateOnce sync.Once
+)
+
+// GetPathTemplate parses path_template once, falling back to the default layout when it is invalid
+func GetPathTemplate() *pathTemplate {
+	activePathTemplateOnce.Do(func() {
+		source := GetAppConfig().PathTemplate
+		if source == "" {
+			source = defaultPathTemplate
+		}
+		t, err := parsePathTemplate(source)
+		if err != nil {
+			fmt.Printf("⚠️  Warning: Invalid path_template '%s': %v, using the default layout\n", source, err)
+			t, _ = parsePathTemplate(defaultPathTemplate)
+		}
+		activePathTemplate = t
+	})
+	return activePathTemplate
+}
+
+// parsePathTemplate parses a template such as {year}/{country}/{city}/{date}[-{time}]-{city}{ext}
+func parsePathTemplate(source string) (*pathTemplate, error) {
+	t := &pathTemplate{source: source}
+	for _, segment := range strings.Split(source, "/") {
+		parts, err := parseTemplateSegment(segment)
+		if err != nil {
+			return nil, err
+		}
+		if len(parts) == 0 {
+			return nil, fmt.Errorf("empty path segment")
+		}
+		t.segments = append(t.segments, parts)
+	}
+
+	name := t.segments[len(t.segments)-1]
+	if last := name[len(name)-1]; last.token != "ext" {
+		return nil, fmt.Errorf("the file name must end with {ext}")
+	}
+	for i, segment := range t.segments {
+		for _, token := range segmentTokens(segment) {
+			if token == "seq" {
+				if i != len(t.segments)-1 {
+					return nil, fmt.Errorf("{seq} may only appear in the file name")
+				}
+				t.hasSeq = true
+			}
+		}
+	}
+
+	t.compilePatterns()
+	return t, nil
+}
+
+// parseTemplateSegment parses one path segment into literals, tokens and optional groups
+func parseTemplateSegment(segment string) ([]templatePart, error) {
+	var parts []templatePart
+	var group *[]templatePart
+	current := &parts
+
+	for i := 0; i < len(segment); i++ {
+		switch segment[i] {
+		case '{':
+			end := strings.IndexByte(segment[i:], '}')
+			if end < 0 {
+				return nil, fmt.Errorf("unclosed { in %q", segment)
+			}
+			part, err := parseTemplateToken(segment[i+1 : i+end])
+			if err != nil {
+				return nil, err
+			}
+			*current = append(*current, part)
+			i += end
+		case '}':
+			return nil, fmt.Errorf("unexpected } in %q", segment)
+		case '[':
+			if group != nil {
+				return nil, fmt.Errorf("optional groups cannot be nested in %q", segment)
+			}
+			group = &[]templatePart{}
+			current = group
+		case ']':
+			if group == nil {
+				return nil, fmt.Errorf("unexpected ] in %q", segment)
+			}
+			parts = append(parts, templatePart{group: *group})
+			group = nil
+			current = &parts
+		default:
+			if n := len(*current); n > 0 && (*current)[n-1].token == "" && (*current)[n-1].group == nil {
+				(*current)[n-1].literal += segment[i : i+1]
+			} else {
+				*current = append(*current, templatePart{literal: segment[i : i+1]})
+			}
+		}
+	}
+	if group != nil {
+		return nil, fmt.Errorf("unclosed [ in %q", segment)
+	}
+	return parts, nil
+}
+
+// parseTemplateToken parses the inside of {token} or {token:layout}
+func parseTemplateToken(text string) (templatePart, error) {
+	name, layout, hasLayout := strings.Cut(text, ":")
+	token, known := templateTokens[name]
+	if !known {
+		return templatePart{}, fmt.Errorf("unknown token {%s}", text)
+	}
+	if hasLayout && token.defaultLayout == "" {
+		return templatePart{}, fmt.Errorf("{%s} does not take a layout", name)
+	}
+	if layout == "" {
+		layout = token.defaultLayout
+	}
+	return templatePart{token: name, layout: layout}, nil
+}
+
+// segmentTokens lists the tokens of a segment, including those in optional groups
+func segmentTokens(parts []templatePart) []string {
+	var tokens []string
+	for _, part := range parts {
+		if part.token != "" {
+			tokens = append(tokens, part.token)
+		}
+		tokens = append(tokens, segmentTokens(part.group)...)
+	}
+	return tokens
+}
+
+// isYearFolder reports whether a folder segment is just {year}
+func isYearFolder(parts []templatePart) bool {
+	return len(parts) == 1 && parts[0].token == "year"
+}
+
+// canRenderEmpty reports whether a folder segment can come out empty and so be left out
+func canRenderEmpty(parts []templatePart) bool {
+	if isYearFolder(parts) {
+		return true // Left out when the destination is already a year folder
+	}
+	for _, part := range parts {
+		if part.group == nil && (part.token == "" || !templateTokens[part.token].optional) {
+			return false
+		}
+	}
+	return true
+}
+
+// compilePatterns builds the expressions that read values back out of rendered paths
+func (t *pathTemplate) compilePatterns() {
+	var dirs strings.Builder
+	for _, segment := range t.segments[:len(t.segments)-1] {
+		pattern := "(?:" + t.partsPattern(segment) + "/)"
+		if canRenderEmpty(segment) {
+			pattern += "?"
+		}
+		dirs.WriteString(pattern)
+	}
+	t.dirPattern = regexp.MustCompile("^" + dirs.String() + "$")
+
+	name := t.partsPattern(t.segments[len(t.segments)-1])
+	if !t.hasSeq {
+		// Duplicates are numbered before the extension
+		extGroup := "(" + templateTokens["ext"].pattern + ")"
+		name = strings.TrimSuffix(name, extGroup) + `(?:-(?:` + seqPattern + `))?` + extGroup
+	}
+	t.pattern = regexp.MustCompile("^" + dirs.String() + name + "$")
+}
+
+// partsPattern turns parts into an expression with one group per token, recording the token keys
+func (t *pathTemplate) partsPattern(parts []templatePart) string {
+	var pattern strings.Builder
+	for _, part := range parts {
+		switch {
+		case part.group != nil:
+			pattern.WriteString("(?:" + t.partsPattern(part.group) + ")?")
+		case part.token != "":
+			expression := templateTokens[part.token].pattern
+			if part.layout != "" {
+				expression = layoutPattern(part.layout)
+			}
+			pattern.WriteString("(" + expression + ")")
+			t.keys = append(t.keys, part.key())
+		default:
+			pattern.WriteString(regexp.QuoteMeta(part.literal))
+		}
+	}
+	return pattern.String()
+}
+
+// layoutPattern matches the text a Go time layout produces: digit runs stay digits of the
+// same width and letter runs become words
+func layoutPattern(layout string) string {
+	var pattern strings.Builder
+	runes := []rune(layout)
+	for i := 0; i < len(runes); {
+		j := i
+		switch {
+		case unicode.IsDigit(runes[i]):
+			for j < len(runes) && unicode.IsDigit(runes[j]) {
+				j++
+			}
+			pattern.WriteString(fmt.Sprintf(`\d{%d}`, j-i))
+		case unicode.IsLetter(runes[i]):
+			for j < len(runes) && unicode.IsLetter(runes[j]) {
+				j++
+			}
+			pattern.WriteString(`[A-Za-z]+`)
+		default:
+			j++
+			pattern.WriteString(regexp.QuoteMeta(string(runes[i])))
+		}
+		i = j
+	}
+	return pattern.String()
+}
+
+// render builds the folder and file name from token values. Empty folders are left out, as is
+// a {year} folder when skipYear is set
+func (t *pathTemplate) render(value func(token, layout string) string, skipYear bool) (string, string) {
+	var dirs []string
+	for _, segment := range t.segments[:len(t.segments)-1] {
+		if skipYear && isYearFolder(segment) {
+			continue
+		}
+		if dir := renderParts(segment, value); dir != "" {
+			dirs = append(dirs, dir)
+		}
+	}
+	return filepath.Join(dirs...), renderParts(t.segments[len(t.segments)-1], value)
+}
+
+// renderParts renders one segment; an optional group is dropped when any of its tokens is empty
+func renderParts(parts []templatePart, value func(token, layout string) string) string {
+	var rendered strings.Builder
+	for _, part := range parts {
+		switch {
+		case part.group != nil:
+			complete := true
+			for _, token := range part.group {
+				if token.token != "" && value(token.token, token.layout) == "" {
+					complete = false
+				}
+			}
+			if complete {
+				rendered.WriteString(renderParts(part.group, value))
+			}
+		case part.token != "":
+			rendered.WriteString(value(part.token, part.layout))
+		default:
+			rendered.WriteString(part.literal)
+		}
+	}
+	return rendered.String()
+}
+
+// parse reads the token values back out of a path relative to the destination; false when
+// the path does not follow the template
+func (t *pathTemplate) parse(relPath string) (map[string]string, bool) {
+	return t.match(t.pattern, filepath.ToSlash(relPath))
+}
+
+// parseFolder reads the token values out of a folder relative to the destination
+func (t *pathTemplate) parseFolder(relDir string) (map[string]string, bool) {
+	relDir = filepath.ToSlash(relDir)
+	if relDir == "." {
+		relDir = ""
+	} else {
+		relDir += "/"
+	}
+	return t.match(t.dirPattern, relDir)
+}
+
+// match collects the groups of a match by token key, keeping the first non-empty value. The
+// folder pattern's groups come first in keys, so it shares them
+func (t *pathTemplate) match(pattern *regexp.Regexp, text string) (map[string]string, bool) {
+	groups := pattern.FindStringSubmatch(text)
+	if groups == nil {
+		return nil, false
+	}
+	values := make(map[string]string)
+	for i, key := range t.keys {
+		if i+1 < len(groups) && values[key] == "" {
+			values[key] = groups[i+1]
+		}
+	}
+	return values, true
+}
+
+// folderFromValues renders the folder for parsed values, as a photo or a video
+func (t *pathTemplate) folderFromValues(values map[string]string, video bool) string {
+	dir, _ := t.render(func(token, layout string) string {
+		switch token {
+		case "video-folder":
+			if video {
+				return videoFolderName
+			}
+			return ""
+		case "kind":
+			if video {
+				return "video"
+			}
+			return "photo"
+		}
+		if layout != "" {
+			return values[token+":"+layout]
+		}
+		return values[token]
+	}, false)
+	return dir
+}
+
+// dateFromValues works out the capture day from parsed values: {date}, else {year}, {month} and {day}
+func dateFromValues(values map[string]string) (time.Time, bool) {
+	for key, value := range values {
+		if layout, isDate := strings.CutPrefix(key, "date:"); isDate && value != "" {
+			if date, err := time.Parse(layout, value); err == nil {
+				return date, true
+			}
+		}
+	}
+	date, err := time.Parse("2006-01-02", values["year"]+"-"+values["month"]+"-"+values["day"])
+	return date, err == nil
+}
+
+// nameTimePattern finds the hour and minute in a name such as 2023-05-01-1430-madrid.jpg
+var nameTimePattern = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}-(\d{4})`)
+
+// layoutFields are what a file is filed under
+type layoutFields struct {
+	Source        string    // The file being filed; gives its kind, original name, extension and camera
+	Date          time.Time // Capture day, with the time of day when HasTime is set
+	HasTime       bool
+	Country, City string
+	Seq           string // Duplicate suffix, "" for the first file
+	Reason        string // What decided the date and place, recorded in plans
+}
+
+// newLayoutFields builds the fields for a file, keeping the hour and minute of a name already in the
+// YYYY-MM-DD-HHMM form
+func newLayoutFields(source string, date time.Time, country, city string) layoutFields {
+	fields := layoutFields{
+		Source:  source,
+		Date:    time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location()),
+		Country: country,
+		City:    city,
+	}
+	if matches := nameTimePattern.FindStringSubmatch(filepath.Base(source)); matches != nil {
+		if clock, err := time.Parse("1504", matches[1]); err == nil {
+			fields.Date = fields.Date.Add(time.Duration(clock.Hour())*time.Hour + time.Duration(clock.Minute())*time.Minute)
+			fields.HasTime = true
+		}
+	}
+	return fields
+}
+
+// value renders one token for the file
+func (f layoutFields) value(token, layout string) string {
+	switch token {
+	case "year":
+		return f.Date.Format("2006")
+	case "month":
+		return f.Date.Format("01")
+	case "day":
+		return f.Date.Format("02")
+	case "month-name":
+		return f.Date.Format("January")
+	case "date":
+		return f.Date.Format(layout)
+	case "time":
+		if !f.HasTime {
+			return ""
+		}
+		return f.Date.Format(layout)
+	case "country":
+		return f.Country
+	case "city":
+		return f.City
+	case "camera":
+		return cameraSlug(f.Source)
+	case "kind":
+		if isVideoFile(f.Source) {
+			return "video"
+		}
+		return "photo"
+	case "video-folder":
+		if isVideoFile(f.Source) {
+			return videoFolderName
+		}
+		return ""
+	case "name":
+		return strings.TrimSuffix(filepath.Base(f.Source), filepath.Ext(f.Source))
+	case "ext":
+		return filepath.Ext(f.Source)
+	case "seq":
+		return f.Seq
+	}
+	return ""
+}
+
+// cameraSlug names the camera that took a file, e.g. canon-eos-r5; "" when unknown
+func cameraSlug(path string) string {
+	if path == "" {
+		return ""
+	}
+	metadata, err := readMetadata(path)
+	if err != nil {
+		return ""
+	}
+	camera := strings.TrimSpace(metadata.Model)
+	// "Canon EOS R5" already starts with the make; "iPhone 12" does not
+	if maker := strings.Fields(metadata.Make); len(maker) > 0 && !strings.HasPrefix(strings.ToLower(camera), strings.ToLower(maker[0])) {
+		camera = maker[0] + " " + camera
+	}
+	words := strings.FieldsFunc(strings.ToLower(camera), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return strings.Join(words, "-")
+}
+
+// layoutFolder returns the folder, relative to destBasePath, the template files a file in
+func layoutFolder(destBasePath string, fields layoutFields) string {
+	dir, _ := GetPathTemplate().render(fields.value, filepath.Base(destBasePath) == fields.Date.Format("2006"))
+	return dir
+}
+
+// What --duplicates does with a file whose content is already in the library
+const (
+	duplicatesSkip   = "skip"   // Leave it where it is (default)
+	duplicatesDelete = "delete" // Remove it from the source
+)
+
+// DuplicateContentError means a file is already in the library: a name it would be filed under
+// holds the same content
+type DuplicateContentError struct {
+	Source   string
+	Existing string
+	SameFile bool // Existing is the source itself, through a symlink or hard link
+}
+
+func (e *DuplicateContentError) Error() string {
+	return fmt.Sprintf("%s is already in the library as %s", filepath.Base(e.Source), e.Existing)
+}
+
+// layoutDestination returns the path the template files a file at. A name holding the same content
+// gives a DuplicateContentError. A name taken by a different file (or by the companions it would
+// displace) gets a suffix of the first 8 hex digits of the file's SHA-256, so re-runs give the same
+// name whatever order files come in. Without {seq} the suffix goes before the extension
+func layoutDestination(destBasePath string, fields layoutFields) (string, error) {
+	t := GetPathTemplate()
+	skipYear := filepath.Base(destBasePath) == fields.Date.Format("2006")
+
+	pathWith := func(seq string) string {
+		fields.Seq = seq
+		dir, name := t.render(fields.value, skipYear)
+		if seq != "" && !t.hasSeq {
+			ext := filepath.Ext(name)
+			name = strings.TrimSuffix(name, ext) + "-" + seq + ext
+		}
+		return filepath.Join(destBasePath, dir, name)
+	}
+
+	// The source is only hashed once a name is taken
+	var sourceHash string
+	hashSource := func() error {
+		if sourceHash != "" {
+			return nil
+		}
+		hash, err := calculateFileHash(fields.Source)
+		if err != nil {
+			return fmt.Errorf("failed to hash %s: %v", filepath.Base(fields.Source), err)
+		}
+		sourceHash = hash
+		return nil
+	}
+	identical := func(other string) bool {
+		hash, err := calculateFileHash(other)
+		return err == nil && hashSource() == nil && hash == sourceHash
+	}
+
+	// claim returns path with seq when it is free, and a DuplicateContentError when it holds the
+	// same content; "" means taken. Targets already given to other files in this run count as taken
+	claim := func(seq string) (string, error) {
+		path := pathWith(seq)
+		holder := path
+		if _, err := os.Lstat(path); os.IsNotExist(err) {
+			holder = claimedSource(path)
+		}
+		switch {
+		case holder != "" && identical(holder):
+			return "", &DuplicateContentError{Source: fields.Source, Existing: path, SameFile: sameFileAs(path, fields.Source)}
+		case holder == "" && companionPathsFree(fields.Source, path, destBasePath) && reserveTarget(path, fields.Source):
+			return path, nil
+		}
+		return "", nil
+	}
+
+	if path, err := claim(""); path != "" || err != nil {
+		return path, err
+	}
+
+	// Earlier versions numbered duplicates -1, -2, ...; the file may be one of them
+	for n := 1; n <= 1000; n++ {
+		numbered := pathWith(strconv.Itoa(n))
+		if _, err := os.Lstat(numbered); err != nil {
+			break
+		}
+		if identical(numbered) {
+			return "", &DuplicateContentError{Source: fields.Source, Existing: numbered, SameFile: sameFileAs(numbered, fields.Source)}
+		}
+	}
+
+	if err := hashSource(); err != nil {
+		return "", err
+	}
+	for n := 1; n <= 1000; n++ {
+		seq := sourceHash[:8]
+		if n > 1 {
+			// Only for a different file with the same short hash
+			seq = fmt.Sprintf("%s-%d", seq, n)
+		}
+		if path, err := claim(seq); path != "" || err != nil {
+			return path, err
+		}
+	}
+	return "", fmt.Errorf("too many duplicate filenames")
+}
+
+var (
+	// claimedTargets maps each target a live run has given a file to that file's source, so two
+	// workers never pick the same free name before either has created it
+	claimedTargets   = make(map[string]string)
+	claimedTargetsMu sync.Mutex
+)
+
+// reserveTarget claims target for source, in the plan when one is being made, reporting false
+// when another file of this run has it
+func reserveTarget(target, source string) bool {
+	if activePlan != nil {
+		return activePlan.reserve(target, source)
+	}
+	claimedTargetsMu.Lock()
+	defer claimedTargetsMu.Unlock()
+
+	if _, taken := claimedTargets[target]; taken {
+		return false
+	}
+	claimedTargets[target] = source
+	return true
+}
+
+// claimedSource returns the file this run already gave target to; "" when none
+func claimedSource(target string) string {
+	if activePlan != nil {
+		return activePlan.plannedSource(target)
+	}
+	claimedTargetsMu.Lock()
+	defer claimedTargetsMu.Unlock()
+	return claimedTargets[target]
+}
+
+// sameFileAs reports whether path is source itself: a symlink that resolves to it or a hard link to
+// its inode, as a symlink or hardlink run leaves in the library
+func sameFileAs(path, source string) bool {
+	sourceInfo, err := os.Stat(source)
+	if err != nil {
+		return false
+	}
+	info, err := os.Stat(path)
+	return err == nil && os.SameFile(info, sourceInfo)
+}
+
+// locationFields reads the country and city out of a location folder relative to the destination,
+// falling back to its last two folders
+func locationFields(location string) (string, string) {
+	if values, ok := GetPathTemplate().parseFolder(location); ok && values["city"] != "" {
+		return values["country"], values["city"]
+	}
+	parts := strings.Split(filepath.ToSlash(location), "/")
+	city := parts[len(parts)-1]
+	country := ""
+	if len(parts) > 1 {
+		country = parts[len(parts)-2]
+	}
+	return country, city
+}
+
+// relocateFolder returns a location folder with its country and city replaced, in the template's layout
+func relocateFolder(location, country, city string) string {
+	t := GetPathTemplate()
+	if values, ok := t.parseFolder(location); ok && values["city"] != "" {
+		values["country"], values["city"] = country, city
+		return t.folderFromValues(values, false)
+	}
+	return filepath.Join(filepath.Dir(filepath.Dir(location)), country, city)
+}
+
+// moveIntoLayout moves (or copies or links, with --transfer) a file and its companions to where the
+// template files it and returns its final path
+func moveIntoLayout(sourcePath, destBasePath string, fields layoutFields, dryRun bool) (string, error) {
+	mode := transferModeFor(transferMove)
+	fileType := "photo"
+	if isVideoFile(sourcePath) {
+		fileType = "video"
+		if dryRun {
+			fmt.Printf("🎥 [DRY RUN] Processing video file: %s\n", filepath.Base(sourcePath))
+		} else {
+			fmt.Printf("🎥 Processing video file: %s\n", filepath.Base(sourcePath))
+		}
+	} else if dryRun {
+		fmt.Printf("📷 [DRY RUN] Processing photo file: %s\n", filepath.Base(sourcePath))
+	} else {
+		fmt.Printf("📷 Processing photo file: %s\n", filepath.Base(sourcePath))
+	}
+
+	finalPath, err := layoutDestination(destBasePath, fields)
+	if dup, ok := err.(*DuplicateContentError); ok {
+		importedDuplicate(dup, destBasePath, dryRun)
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	sidecars := findSidecars(sourcePath)
+
+	if dryRun {
+		// Dry run mode - just show what would happen
+		if fileType == "video" {
+			fmt.Printf("✅ [DRY RUN] Video would be %s to: %s\n", transferVerb(mode), finalPath)
+		} else {
+			fmt.Printf("✅ [DRY RUN] Photo would be %s to: %s\n", transferVerb(mode), finalPath)
+		}
+		activePlan.addFiled(sourcePath, finalPath, mode, fields)
+		transferCompanions(sidecars, sourcePath, finalPath, destBasePath, true, mode)
+		return finalPath, nil
+	}
+
+	// Create directory structure if it doesn't exist
+	destDir := filepath.Dir(finalPath)
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create directory %s: %v", destDir, err)
+	}
+
+	// Move the file
+	if err := transferFile(sourcePath, finalPath, mode); err != nil {
+		return "", err
+	}
+
+	if fileType == "video" {
+		fmt.Printf("✅ Video %s to: %s\n", transferVerb(mode), finalPath)
+	} else {
+		fmt.Printf("✅ Photo %s to: %s\n", transferVerb(mode), finalPath)
+	}
+	transferCompanions(sidecars, sourcePath, finalPath, destBasePath, false, mode)
+	return finalPath, nil
+}
+
+// importedDuplicate handles a file that is already in the library: it is recorded and left in place,
+// or with --duplicates delete removed along with the companions and sidecars the library also has.
+// A source the library links to is never deleted: it is the library's copy
+func importedDuplicate(dup *DuplicateContentError, destBasePath string, dryRun bool) {
+	remove := GetAppConfig().Duplicates == duplicatesDelete && !dup.SameFile
+	if dup.SameFile && GetAppConfig().Duplicates == duplicatesDelete {
+		fmt.Printf("📎 Keeping %s: the library links to it\n", filepath.Base(dup.Source))
+	}
+	if dryRun {
+		action := "left in place"
+		if remove {
+			action = "deleted"
+		}
+		fmt.Printf("♻️  [DRY RUN] %s is already in the library as %s - would be %s\n", filepath.Base(dup.Source), dup.Existing, action)
+		planAction := duplicatesSkip
+		if remove {
+			planAction = duplicatesDelete
+		}
+		activePlan.addDuplicate(dup.Source, dup.Existing, planAction)
+		return
+	}
+	fmt.Printf("♻️  %s is already in the library as %s\n", filepath.Base(dup.Source), dup.Existing)
+	settleDuplicate(dup, destBasePath, remove)
+}
+
+// settleDuplicate journals a file already in the library and, when remove is set, deletes it along
+// with the companions and sidecars the library holds identical copies of
+func settleDuplicate(dup *DuplicateContentError, destBasePath string, remove bool) {
+	activeJournal.imported(dup.Source, dup.Existing)
+	if !remove {
+		return
+	}
+
+	// Companions and sidecars go only when the library holds the same content for them too
+	removable := []string{dup.Source}
+	keepUnlessIdentical := func(source, target string) {
+		if sameFileAs(target, source) {
+			fmt.Printf("📎 Keeping %s: the library links to it\n", filepath.Base(source))
+			return
+		}
+		sourceHash, err := calculateFileHash(source)
+		if err == nil {
+			if targetHash, err := calculateFileHash(target); err == nil && targetHash == sourceHash {
+				removable = append(removable, source)
+				return
+			}
+		}
+		fmt.Printf("📎 Keeping %s: the library has no identical copy\n", filepath.Base(source))
+	}
+	sidecars := findSidecars(dup.Source)
+	for _, assignment := range assignSidecars(sidecars, dup.Source, dup.Existing, destBasePath) {
+		keepUnlessIdentical(assignment.sidecar, sidecarTargetPath(assignment.sidecar, assignment.ownerSource, assignment.ownerFinal))
+	}
+	for _, companion := range companionsOf(dup.Source) {
+		target := companionTarget(companion, dup.Existing, destBasePath)
+		keepUnlessIdentical(companion, target)
+		for _, sidecar := range companionSidecars(companion, sidecars) {
+			keepUnlessIdentical(sidecar, sidecarTargetPath(sidecar, companion, target))
+		}
+	}
+
+	for _, path := range removable {
+		if err := activeJournal.remove(path); err != nil {
+			fmt.Printf("⚠️  Could not delete duplicate %s: %v\n", filepath.Base(path), err)
+			continue
+		}
+		fmt.Printf("🗑️  Deleted duplicate: %s\n", path)
+	}
+}
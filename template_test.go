@@ -0,0 +1,173 @@
+package main
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParsePathTemplateErrors(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"{year}/{city}", "must end with {ext}"},
+		{"{year}//{name}{ext}", "empty path segment"},
+		{"{year}/{bogus}{ext}", "unknown token {bogus}"},
+		{"{year:2006}/{name}{ext}", "does not take a layout"},
+		{"{year/{name}{ext}", "unclosed {"},
+		{"{year}}/{name}{ext}", "unexpected }"},
+		{"{year}/[{city}/{name}{ext}", "unclosed ["},
+		{"{year}/{city}]/{name}{ext}", "unexpected ]"},
+		{"{year}/[[{city}]]/{name}{ext}", "cannot be nested"},
+		{"{year}/{seq}/{name}{ext}", "{seq} may only appear in the file name"},
+	}
+
+	for _, tt := range tests {
+		if _, err := parsePathTemplate(tt.source); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("parsePathTemplate(%q) error = %v, want one containing %q", tt.source, err, tt.want)
+		}
+	}
+}
+
+func TestLayoutPattern(t *testing.T) {
+	tests := []struct {
+		layout string
+		want   string
+		match  string
+	}{
+		{"2006-01-02", `\d{4}-\d{2}-\d{2}`, "2023-07-14"},
+		{"1504", `\d{4}`, "1830"},
+		{"02 Jan 2006", `\d{2} [A-Za-z]+ \d{4}`, "14 Jul 2023"},
+		{"2006.01", `\d{4}\.\d{2}`, "2023.07"},
+	}
+
+	for _, tt := range tests {
+		got := layoutPattern(tt.layout)
+		if got != tt.want {
+			t.Errorf("layoutPattern(%q) = %q, want %q", tt.layout, got, tt.want)
+			continue
+		}
+		if !regexp.MustCompile("^" + got + "$").MatchString(tt.match) {
+			t.Errorf("layoutPattern(%q) does not match %q", tt.layout, tt.match)
+		}
+	}
+}
+
+func TestPathTemplateRoundTrip(t *testing.T) {
+	day := time.Date(2023, 7, 14, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		template string
+		fields   layoutFields
+		wantPath string
+	}{
+		{
+			name:     "default layout without a time",
+			template: defaultPathTemplate,
+			fields:   newLayoutFields("IMG_0001.jpg", day, "France", "Paris"),
+			wantPath: "2023/France/Paris/2023-07-14-Paris.jpg",
+		},
+		{
+			name:     "default layout keeps the time of the name",
+			template: defaultPathTemplate,
+			fields:   newLayoutFields("2023-07-14-1830-paris.mov", day, "France", "Paris"),
+			wantPath: "VIDEO-FILES/2023/France/Paris/2023-07-14-1830-Paris.mov",
+		},
+		{
+			name:     "month folders and a custom date layout",
+			template: "{kind}/{year}/{month}-{month-name}/{date:02 Jan 2006} {name}{ext}",
+			fields:   newLayoutFields("IMG_0002.heic", day, "Spain", "Madrid"),
+			wantPath: "photo/2023/07-July/14 Jul 2023 IMG_0002.heic",
+		},
+		{
+			name:     "sequence in the file name",
+			template: "{year}/{country}/{date}[_{seq}]{ext}",
+			fields: layoutFields{
+				Source: "IMG_0003.jpg", Date: day, Country: "Spain", City: "Madrid", Seq: "1a2b3c4d",
+			},
+			wantPath: "2023/Spain/2023-07-14_1a2b3c4d.jpg",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := parsePathTemplate(tt.template)
+			if err != nil {
+				t.Fatalf("parsePathTemplate(%q) failed: %v", tt.template, err)
+			}
+
+			dir, name := tmpl.render(tt.fields.value, false)
+			path := filepath.ToSlash(filepath.Join(dir, name))
+			if path != tt.wantPath {
+				t.Fatalf("render = %q, want %q", path, tt.wantPath)
+			}
+
+			values, ok := tmpl.parse(path)
+			if !ok {
+				t.Fatalf("parse(%q) did not match the template", path)
+			}
+			for _, key := range tmpl.keys {
+				token, layout, _ := strings.Cut(key, ":")
+				if want := tt.fields.value(token, layout); values[key] != want {
+					t.Errorf("parse(%q)[%s] = %q, want %q", path, key, values[key], want)
+				}
+			}
+
+			date, ok := dateFromValues(values)
+			if !ok || !date.Equal(day) {
+				t.Errorf("dateFromValues = %v, %v, want %v", date, ok, day)
+			}
+			if folder := tmpl.folderFromValues(values, isVideoFile(tt.fields.Source)); folder != dir {
+				t.Errorf("folderFromValues = %q, want %q", folder, dir)
+			}
+			folderValues, ok := tmpl.parseFolder(dir)
+			if !ok {
+				t.Fatalf("parseFolder(%q) did not match the template", dir)
+			}
+			if folder := tmpl.folderFromValues(folderValues, isVideoFile(tt.fields.Source)); folder != dir {
+				t.Errorf("folderFromValues(parseFolder) = %q, want %q", folder, dir)
+			}
+		})
+	}
+}
+
+func TestPathTemplateParse(t *testing.T) {
+	tmpl, err := parsePathTemplate(defaultPathTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path string
+		ok   bool
+		city string
+	}{
+		{"2023/France/Paris/2023-07-14-Paris.jpg", true, "Paris"},
+		{"2023/France/Paris/2023-07-14-Paris-1a2b3c4d.jpg", true, "Paris"},
+		{"2023/France/Paris/2023-07-14-Paris-2.jpg", true, "Paris"},
+		{"2023/United States/New York/2023-07-14-1830-New York.jpg", true, "New York"},
+		{"2023/France/Paris/IMG_0001.jpg", false, ""},
+		{"holiday/IMG_0001.jpg", false, ""},
+	}
+
+	for _, tt := range tests {
+		values, ok := tmpl.parse(tt.path)
+		if ok != tt.ok || values["city"] != tt.city {
+			t.Errorf("parse(%q) = %v city %q, want %v city %q", tt.path, ok, values["city"], tt.ok, tt.city)
+		}
+	}
+}
+
+func TestPathTemplateRenderSkipsYear(t *testing.T) {
+	tmpl, err := parsePathTemplate(defaultPathTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := newLayoutFields("IMG_0001.jpg", time.Date(2023, 7, 14, 0, 0, 0, 0, time.UTC), "France", "Paris")
+	if dir, _ := tmpl.render(fields.value, true); dir != filepath.Join("France", "Paris") {
+		t.Errorf("render with skipYear = %q, want France/Paris", dir)
+	}
+}
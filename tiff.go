@@ -4,7 +4,6 @@ import (
 	"bufio"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"regexp"
 	"strings"
@@ -243,6 +242,7 @@ func processTiffFile(filePath string, dryRun bool) error {
 			fmt.Printf("❌ Failed to rename %s to %s: %v\n", filepath.Base(filePath), newFilename, err)
 			return nil // Continue processing
 		}
+		activeJournal.renamed(filePath, newPath)
 		fmt.Printf("📝 Renamed to: %s\n", newFilename)
 		currentFilePath = newPath // Update the path for location detection
 	}
@@ -320,6 +320,7 @@ func handleLocationDetectionAndFilenameUpdate(filePath string, timestamp time.Ti
 		if err := os.Rename(filePath, newPath); err != nil {
 			return fmt.Errorf("failed to rename file: %v", err)
 		}
+		activeJournal.renamed(filePath, newPath)
 		fmt.Printf("📝 Renamed to include location: %s\n", newFilename)
 	}
 
@@ -371,35 +372,16 @@ type TiffLocationInfo struct {
 
 // extractAllDatetimeInfo extracts all datetime fields from EXIF
 func extractAllDatetimeInfo(filePath string) (*DateTimeInfo, error) {
-	cmd := exec.Command("exiftool",
-		"-DateTimeOriginal",
-		"-CreateDate",
-		"-DateTime",
-		"-ModifyDate",
-		"-T", // Tab-separated output
-		filePath)
-
-	output, err := cmd.CombinedOutput()
+	metadata, err := readMetadata(filePath)
 	if err != nil {
-		return nil, fmt.Errorf("exiftool failed: %v", err)
-	}
-
-	outputStr := strings.TrimSpace(string(output))
-	if outputStr == "" {
-		return nil, fmt.Errorf("no datetime metadata found")
-	}
-
-	// Parse tab-separated output
-	parts := strings.Split(outputStr, "\t")
-	if len(parts) < 4 {
-		return nil, fmt.Errorf("insufficient datetime fields in EXIF")
+		return nil, err
 	}
 
 	info := &DateTimeInfo{
-		DateTimeOriginal: strings.TrimSpace(parts[0]),
-		CreateDate:       strings.TrimSpace(parts[1]),
-		DateTime:         strings.TrimSpace(parts[2]),
-		ModifyDate:       strings.TrimSpace(parts[3]),
+		DateTimeOriginal: metadata.DateTimeOriginal,
+		CreateDate:       metadata.CreateDate,
+		DateTime:         metadata.DateTime,
+		ModifyDate:       metadata.ModifyDate,
 	}
 
 	return info, nil
@@ -448,16 +430,21 @@ func needsTimestampFix(info *DateTimeInfo) (bool, time.Time) {
 func updateExifTimestamp(filePath string, correctTime time.Time) error {
 	timeStr := correctTime.Format("2006:01:02 15:04:05")
 
-	cmd := exec.Command("exiftool",
-		"-overwrite_original",
-		fmt.Sprintf("-DateTimeOriginal=%s", timeStr),
-		fmt.Sprintf("-CreateDate=%s", timeStr),
-		fmt.Sprintf("-DateTime=%s", timeStr),
-		filePath)
-
-	output, err := cmd.CombinedOutput()
+	err := activeJournal.edit(filePath, func() error {
+		stdout, stderr, err := GetExiftoolPool().Execute(
+			"-overwrite_original",
+			fmt.Sprintf("-DateTimeOriginal=%s", timeStr),
+			fmt.Sprintf("-CreateDate=%s", timeStr),
+			fmt.Sprintf("-DateTime=%s", timeStr),
+			filePath)
+		if err == nil {
+			err = exiftoolWriteError(stdout, stderr)
+		}
+		return err
+	})
+	invalidateMetadataCache(filePath)
 	if err != nil {
-		return fmt.Errorf("exiftool update failed: %v, output: %s", err, output)
+		return fmt.Errorf("exiftool update failed: %v", err)
 	}
 
 	return nil
@@ -509,31 +496,11 @@ func extractLocationInfo(filePath string) (*TiffLocationInfo, error) {
 	}
 
 	// No GPS data, check image description
-	cmd := exec.Command("exiftool",
-		"-ImageDescription",
-		"-UserComment",
-		"-XPComment",
-		"-T", // Tab-separated output
-		filePath)
-
-	output, err := cmd.CombinedOutput()
+	metadata, err := readMetadata(filePath)
 	if err != nil {
-		return nil, fmt.Errorf("exiftool failed: %v", err)
-	}
-
-	outputStr := strings.TrimSpace(string(output))
-	if outputStr == "" {
-		return location, nil // No description data
-	}
-
-	// Parse tab-separated output
-	parts := strings.Split(outputStr, "\t")
-	if len(parts) > 0 {
-		description := strings.TrimSpace(parts[0])
-		if description != "" && description != "-" {
-			location.Description = description
-		}
+		return nil, err
 	}
+	location.Description = metadata.ImageDescription
 
 	return location, nil
 }
@@ -0,0 +1,632 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	_ "image/jpeg" // Decoders for perceptualHash
+	_ "image/png"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// timeshiftMaxHashDistance is how many of the 64 perceptual hash bits two photos of the same scene may differ in
+const timeshiftMaxHashDistance = 10
+
+// timeshiftAgreement is how close the offsets of two photo pairs must be to count as the same estimate
+const timeshiftAgreement = 2 * time.Minute
+
+// timeshiftSearchWindow is how far from the camera's own dates phone photos are considered for estimation
+const timeshiftSearchWindow = 7 * 24 * time.Hour
+
+// photoMetaNamePattern matches the names generateFilenameWithTime gives: YYYY-MM-DD[-HHMM]-city
+var photoMetaNamePattern = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2})(?:-(\d{4}))?-(.*)$`)
+
+// cameraFilter selects one camera's files by Make, Model and serial number (case-insensitive
+// substrings) and by the date its clock recorded, inclusive
+type cameraFilter struct {
+	make, model, serial string
+	from, to            time.Time // Zero for an open range
+}
+
+// matches reports whether the file was taken by the selected camera within the date range
+func (f cameraFilter) matches(metadata *MediaMetadata, recorded time.Time) bool {
+	contains := func(value, want string) bool {
+		return want == "" || strings.Contains(strings.ToLower(value), strings.ToLower(want))
+	}
+	if !contains(metadata.Make, f.make) || !contains(metadata.Model, f.model) || !contains(metadata.SerialNumber, f.serial) {
+		return false
+	}
+
+	day := time.Date(recorded.Year(), recorded.Month(), recorded.Day(), 0, 0, 0, 0, time.UTC)
+	return (f.from.IsZero() || !day.Before(f.from)) && (f.to.IsZero() || !day.After(f.to))
+}
+
+// cameraFile is a file of the selected camera and the time its clock recorded
+type cameraFile struct {
+	path      string
+	recorded  time.Time // Wall-clock capture time as recorded, zone-less
+	utcCreate time.Time // QuickTime CreateDate (UTC) of videos; zero for photos
+	utcOffset string    // OffsetTimeOriginal (or OffsetTime) as recorded; "" when none
+}
+
+// recordedTime returns the wall-clock time a file's camera recorded, and for QuickTime
+// videos the UTC CreateDate the time was taken from
+func recordedTime(metadata *MediaMetadata) (recorded, utcCreate time.Time, err error) {
+	wallClock, field, err := pickMetadataDate(metadata)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	if field == "CreateDate" && metadata.CreateDateIsUTC {
+		local := inZone(wallClock, time.UTC).In(defaultTimezone())
+		return inZone(local, time.UTC), wallClock, nil
+	}
+	return wallClock, time.Time{}, nil
+}
+
+// collectCameraFiles finds the files under targetPath that the filter selects
+func collectCameraFiles(targetPath string, filter cameraFilter) ([]cameraFile, error) {
+	var files []cameraFile
+	err := filepath.Walk(targetPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !isMediaFile(path) {
+			return nil
+		}
+
+		metadata, err := readMetadata(path)
+		if err != nil {
+			return nil
+		}
+		recorded, utcCreate, err := recordedTime(metadata)
+		if err != nil || !filter.matches(metadata, recorded) {
+			return nil
+		}
+		utcOffset := metadata.OffsetTimeOriginal
+		if utcOffset == "" {
+			utcOffset = metadata.OffsetTime
+		}
+		files = append(files, cameraFile{path: path, recorded: recorded, utcCreate: utcCreate, utcOffset: utcOffset})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].recorded.Before(files[j].recorded)
+	})
+	return files, nil
+}
+
+// referenceWallClock returns the local wall-clock time a phone photo was taken
+func referenceWallClock(path string) (time.Time, error) {
+	taken, err := extractPhotoDate(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return inZone(taken, time.UTC), nil
+}
+
+// perceptualHash computes a 64-bit difference hash: the image is reduced to 9x8 grey
+// cells and each bit records whether a cell is darker than its right neighbour, so
+// photos of the same scene from different cameras hash alike
+func perceptualHash(path string) (uint64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return 0, err
+	}
+	bounds := img.Bounds()
+	if bounds.Dx() < 9 || bounds.Dy() < 8 {
+		return 0, fmt.Errorf("image too small")
+	}
+
+	// Average a sparse grid of up to 8x8 samples in each cell
+	var cells [8][9]float64
+	for row := 0; row < 8; row++ {
+		y0, y1 := bounds.Min.Y+row*bounds.Dy()/8, bounds.Min.Y+(row+1)*bounds.Dy()/8
+		for col := 0; col < 9; col++ {
+			x0, x1 := bounds.Min.X+col*bounds.Dx()/9, bounds.Min.X+(col+1)*bounds.Dx()/9
+			stepX, stepY := maxInt(1, (x1-x0)/8), maxInt(1, (y1-y0)/8)
+
+			sum, samples := 0.0, 0
+			for y := y0; y < y1; y += stepY {
+				for x := x0; x < x1; x += stepX {
+					r, g, b, _ := img.At(x, y).RGBA()
+					sum += 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)
+					samples++
+				}
+			}
+			cells[row][col] = sum / float64(samples)
+		}
+	}
+
+	var hash uint64
+	for row := 0; row < 8; row++ {
+		for col := 0; col < 8; col++ {
+			hash <<= 1
+			if cells[row][col] < cells[row][col+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash, nil
+}
+
+// maxInt returns the larger of two ints
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// hashedPhoto is a decodable photo with its perceptual hash and wall-clock time
+type hashedPhoto struct {
+	path      string
+	hash      uint64
+	wallClock time.Time
+}
+
+// hashPhotos hashes the JPEG and PNG photos among paths; others cannot be decoded
+func hashPhotos(paths []string, wallClock func(string) (time.Time, bool)) []hashedPhoto {
+	var hashed []hashedPhoto
+	for _, path := range paths {
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".jpg", ".jpeg", ".png":
+		default:
+			continue
+		}
+		when, ok := wallClock(path)
+		if !ok {
+			continue
+		}
+		hash, err := perceptualHash(path)
+		if err != nil || hash == 0 || hash == ^uint64(0) {
+			continue // Undecodable, or too uniform to compare
+		}
+		hashed = append(hashed, hashedPhoto{path: path, hash: hash, wallClock: when})
+	}
+	return hashed
+}
+
+// estimateClockOffset pairs the camera's photos with the most similar phone photos under
+// referencePath and returns the offset most pairs agree on, the amount to add to the camera's times
+func estimateClockOffset(files []cameraFile, referencePath string) (time.Duration, error) {
+	recorded := make(map[string]time.Time, len(files))
+	var cameraPaths []string
+	for _, file := range files {
+		if file.utcCreate.IsZero() {
+			recorded[file.path] = file.recorded
+			cameraPaths = append(cameraPaths, file.path)
+		}
+	}
+	if len(cameraPaths) == 0 {
+		return 0, fmt.Errorf("no camera photos to compare")
+	}
+	first, last := files[0].recorded, files[len(files)-1].recorded
+
+	fmt.Printf("🔍 Hashing %d camera photos...\n", len(cameraPaths))
+	camera := hashPhotos(cameraPaths, func(path string) (time.Time, bool) {
+		return recorded[path], true
+	})
+
+	var phonePaths []string
+	filepath.Walk(referencePath, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() && isMediaFile(path) && !isVideoFile(path) {
+			phonePaths = append(phonePaths, path)
+		}
+		return nil
+	})
+	fmt.Printf("🔍 Hashing phone photos taken within %v of the camera's dates...\n", timeshiftSearchWindow)
+	phone := hashPhotos(phonePaths, func(path string) (time.Time, bool) {
+		when, err := referenceWallClock(path)
+		if err != nil || when.Before(first.Add(-timeshiftSearchWindow)) || when.After(last.Add(timeshiftSearchWindow)) {
+			return time.Time{}, false
+		}
+		return when, true
+	})
+	fmt.Printf("📷 %d camera and %d phone photos could be compared (JPEG and PNG only)\n", len(camera), len(phone))
+
+	// Each camera photo is paired with its closest phone photo when that one is clearly the best
+	var offsets []time.Duration
+	for _, shot := range camera {
+		best, second := -1, 65
+		bestDistance := 65
+		for i, reference := range phone {
+			distance := bits.OnesCount64(shot.hash ^ reference.hash)
+			if distance < bestDistance {
+				second, bestDistance, best = bestDistance, distance, i
+			} else if distance < second {
+				second = distance
+			}
+		}
+		if best < 0 || bestDistance > timeshiftMaxHashDistance || second-bestDistance < 2 {
+			continue
+		}
+		offset := phone[best].wallClock.Sub(shot.wallClock)
+		fmt.Printf("🔗 %s looks like %s (distance %d): %s\n",
+			filepath.Base(shot.path), filepath.Base(phone[best].path), bestDistance, formatOffset(offset))
+		offsets = append(offsets, offset)
+	}
+
+	offset, agreeing := agreedOffset(offsets)
+	if agreeing < 2 {
+		return 0, fmt.Errorf("found %d photo pair(s) that agree on an offset, need at least 2 - pass --pair CAMERA_PHOTO PHONE_PHOTO or --offset", agreeing)
+	}
+	fmt.Printf("📐 %d of %d photo pairs agree within %v\n", agreeing, len(offsets), timeshiftAgreement)
+	return offset, nil
+}
+
+// agreedOffset returns the median of the largest group of offsets within timeshiftAgreement
+// of each other, and the group's size
+func agreedOffset(offsets []time.Duration) (time.Duration, int) {
+	sort.Slice(offsets, func(i, j int) bool { return offsets[i] < offsets[j] })
+
+	bestStart, bestEnd := 0, 0
+	end := 0
+	for start := range offsets {
+		for end < len(offsets) && offsets[end]-offsets[start] <= timeshiftAgreement {
+			end++
+		}
+		if end-start > bestEnd-bestStart {
+			bestStart, bestEnd = start, end
+		}
+	}
+	if bestEnd == bestStart {
+		return 0, 0
+	}
+	group := offsets[bestStart:bestEnd]
+	return group[len(group)/2].Round(time.Second), len(group)
+}
+
+// pairOffset returns the offset between a camera photo and a phone photo of the same moment
+func pairOffset(cameraPath, phonePath string) (time.Duration, error) {
+	metadata, err := readMetadata(cameraPath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read %s: %v", cameraPath, err)
+	}
+	recorded, _, err := recordedTime(metadata)
+	if err != nil {
+		return 0, fmt.Errorf("no capture time in %s: %v", cameraPath, err)
+	}
+	reference, err := referenceWallClock(phonePath)
+	if err != nil {
+		return 0, fmt.Errorf("no capture time in %s: %v", phonePath, err)
+	}
+	return reference.Sub(recorded), nil
+}
+
+// shiftedFilename renames a file named by generateFilenameWithTime (YYYY-MM-DD[-HHMM]-city)
+// for its corrected time, keeping the city. Other names carry no date and are kept
+func shiftedFilename(path string, corrected time.Time) string {
+	name := filepath.Base(path)
+	ext := filepath.Ext(name)
+	match := photoMetaNamePattern.FindStringSubmatch(strings.TrimSuffix(name, ext))
+	if match == nil {
+		return name
+	}
+
+	city := match[3]
+	if match[2] == "" {
+		return generateFilenameWithTime(path, corrected, city)
+	}
+	// generateFilenameWithTime keeps the HHMM of the name it is given, so hand it the corrected one
+	return generateFilenameWithTime(corrected.Format("2006-01-02-1504")+ext, corrected, city)
+}
+
+// shiftQuickTimeTimestamp sets the UTC creation dates of a MOV/MP4 file
+func shiftQuickTimeTimestamp(filePath string, utc time.Time) error {
+	timeStr := utc.Format("2006:01:02 15:04:05")
+
+	err := activeJournal.edit(filePath, func() error {
+		stdout, stderr, err := GetExiftoolPool().Execute(
+			"-overwrite_original",
+			fmt.Sprintf("-QuickTime:CreateDate=%s", timeStr),
+			fmt.Sprintf("-QuickTime:ModifyDate=%s", timeStr),
+			fmt.Sprintf("-QuickTime:TrackCreateDate=%s", timeStr),
+			fmt.Sprintf("-QuickTime:MediaCreateDate=%s", timeStr),
+			filePath)
+		if err == nil {
+			err = exiftoolWriteError(stdout, stderr)
+		}
+		return err
+	})
+	invalidateMetadataCache(filePath)
+	if err != nil {
+		return fmt.Errorf("exiftool update failed: %v", err)
+	}
+	return nil
+}
+
+// writeShiftedTimestamp sets a photo's capture time to corrected. With rewriteOffset the OffsetTime*
+// tags are set to utcOffset; otherwise the recorded offset stays
+func writeShiftedTimestamp(filePath string, corrected time.Time, utcOffset string, rewriteOffset bool) error {
+	timeStr := corrected.Format("2006:01:02 15:04:05")
+	args := []string{
+		"-overwrite_original",
+		fmt.Sprintf("-DateTimeOriginal=%s", timeStr),
+		fmt.Sprintf("-CreateDate=%s", timeStr),
+		fmt.Sprintf("-DateTime=%s", timeStr),
+	}
+	if rewriteOffset {
+		args = append(args,
+			fmt.Sprintf("-OffsetTimeOriginal=%s", utcOffset),
+			fmt.Sprintf("-OffsetTime=%s", utcOffset),
+			fmt.Sprintf("-OffsetTimeDigitized=%s", utcOffset))
+	}
+	if isQuickTimeFile(filePath) && utcOffset != "" {
+		// Apple videos keep their local time and offset in Keys:CreationDate
+		args = append(args, fmt.Sprintf("-Keys:CreationDate=%s%s", timeStr, utcOffset))
+	}
+
+	err := activeJournal.edit(filePath, func() error {
+		stdout, stderr, err := GetExiftoolPool().Execute(append(args, filePath)...)
+		if err == nil {
+			err = exiftoolWriteError(stdout, stderr)
+		}
+		return err
+	})
+	invalidateMetadataCache(filePath)
+	if err != nil {
+		return fmt.Errorf("exiftool update failed: %v", err)
+	}
+	return nil
+}
+
+// shiftFile moves one file's capture time by offset and renames it to match. With a zone, the
+// file's UTC offset is set to the zone's at the corrected time
+func shiftFile(file cameraFile, offset time.Duration, zone *time.Location, rename, dryRun bool) error {
+	corrected := file.recorded.Add(offset)
+	utcOffset := file.utcOffset
+	if zone != nil {
+		utcOffset = inZone(corrected, zone).Format("-07:00")
+	}
+	newName := filepath.Base(file.path)
+	if rename {
+		newName = shiftedFilename(file.path, corrected)
+	}
+	newPath := filepath.Join(filepath.Dir(file.path), newName)
+	if newPath != file.path {
+		if _, err := os.Stat(newPath); err == nil {
+			return fmt.Errorf("%s already exists", newName)
+		}
+	}
+
+	if dryRun {
+		fmt.Printf("🕐 [DRY RUN] %s: %s -> %s\n", filepath.Base(file.path),
+			file.recorded.Format("2006-01-02 15:04:05"), corrected.Format("2006-01-02 15:04:05"))
+		if zone != nil && file.utcCreate.IsZero() && utcOffset != file.utcOffset {
+			fmt.Printf("🌍 [DRY RUN] UTC offset: %s -> %s\n", displayOffset(file.utcOffset), utcOffset)
+		}
+		if newPath != file.path {
+			fmt.Printf("📝 [DRY RUN] Would rename to: %s\n", newName)
+			transferSidecars(findSidecars(file.path), file.path, newPath, true, transferMove)
+		}
+		return nil
+	}
+
+	if !file.utcCreate.IsZero() {
+		if !isQuickTimeFile(file.path) {
+			return fmt.Errorf("exiftool cannot write dates into this video format")
+		}
+		if err := shiftQuickTimeTimestamp(file.path, file.utcCreate.Add(offset)); err != nil {
+			return err
+		}
+	} else if err := writeShiftedTimestamp(file.path, corrected, utcOffset, zone != nil); err != nil {
+		return err
+	}
+	fmt.Printf("🕐 %s: %s -> %s\n", filepath.Base(file.path),
+		file.recorded.Format("2006-01-02 15:04:05"), corrected.Format("2006-01-02 15:04:05"))
+
+	if newPath != file.path {
+		sidecars := findSidecars(file.path)
+		if err := os.Rename(file.path, newPath); err != nil {
+			return fmt.Errorf("failed to rename to %s: %v", newName, err)
+		}
+		activeJournal.renamed(file.path, newPath)
+		fmt.Printf("📝 Renamed to: %s\n", newName)
+		transferSidecars(sidecars, file.path, newPath, false, transferMove)
+	}
+	return nil
+}
+
+// displayOffset shows a recorded UTC offset, or that there was none
+func displayOffset(utcOffset string) string {
+	if utcOffset == "" {
+		return "none"
+	}
+	return utcOffset
+}
+
+// formatOffset formats a clock offset with its sign, e.g. +1h30m0s
+func formatOffset(offset time.Duration) string {
+	if offset < 0 {
+		return offset.String()
+	}
+	return "+" + offset.String()
+}
+
+// parseTimeshiftDate parses a --from/--to date
+func parseTimeshiftDate(flag, value string) (time.Time, error) {
+	date, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s date '%s' (use YYYY-MM-DD)", flag, value)
+	}
+	return date, nil
+}
+
+// runTimeshiftCommand corrects the capture times of one camera's files by a fixed offset,
+// given directly or estimated from phone photos of the same scenes
+func runTimeshiftCommand(args []string) error {
+	var targetPath, estimatePath string
+	var pair []string
+	var filter cameraFilter
+	var offset time.Duration
+	var zone *time.Location
+	offsetGiven := false
+	rename := true
+	dryRun := false
+	dryRunSampleSize := 0
+
+	for i := 0; i < len(args); i++ {
+		needsValue := func() (string, error) {
+			if i+1 >= len(args) {
+				return "", fmt.Errorf("%s requires a value", args[i])
+			}
+			i++
+			return args[i], nil
+		}
+
+		var err error
+		var value string
+		switch args[i] {
+		case "--offset":
+			if value, err = needsValue(); err == nil {
+				offset, err = parseClockOffset(value)
+				offsetGiven = true
+			}
+		case "--timezone":
+			if value, err = needsValue(); err == nil {
+				var ok bool
+				if zone, ok = parseUTCOffset(value); !ok {
+					if zone, err = time.LoadLocation(value); err != nil {
+						err = fmt.Errorf("unknown --timezone '%s': %v", value, err)
+					}
+				}
+			}
+		case "--estimate":
+			estimatePath, err = needsValue()
+		case "--pair":
+			if i+2 >= len(args) {
+				return fmt.Errorf("--pair requires a camera photo and a phone photo")
+			}
+			pair = []string{args[i+1], args[i+2]}
+			i += 2
+		case "--make":
+			filter.make, err = needsValue()
+		case "--model":
+			filter.model, err = needsValue()
+		case "--serial":
+			filter.serial, err = needsValue()
+		case "--from":
+			if value, err = needsValue(); err == nil {
+				filter.from, err = parseTimeshiftDate("--from", value)
+			}
+		case "--to":
+			if value, err = needsValue(); err == nil {
+				filter.to, err = parseTimeshiftDate("--to", value)
+			}
+		case "--no-rename":
+			rename = false
+		case "--dry-run":
+			dryRun = true
+			if i+1 < len(args) && !strings.HasPrefix(args[i+1], "--") {
+				if size, err := strconv.Atoi(args[i+1]); err == nil && size > 0 {
+					dryRunSampleSize = size
+					i++
+				}
+			}
+		default:
+			if strings.HasPrefix(args[i], "--") {
+				return fmt.Errorf("unknown timeshift option %s", args[i])
+			}
+			if targetPath != "" {
+				return fmt.Errorf("unexpected argument %s", args[i])
+			}
+			targetPath = args[i]
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	sources := 0
+	for _, given := range []bool{offsetGiven, estimatePath != "", pair != nil} {
+		if given {
+			sources++
+		}
+	}
+	if targetPath == "" || sources != 1 {
+		return fmt.Errorf("usage: timeshift /target/path (--offset D | --estimate /phone/path | --pair CAMERA PHONE) --make/--model/--serial ...")
+	}
+	if filter.make == "" && filter.model == "" && filter.serial == "" {
+		return fmt.Errorf("select the camera with --make, --model or --serial so other devices' files are left alone")
+	}
+	if _, err := os.Stat(targetPath); os.IsNotExist(err) {
+		return fmt.Errorf("target path does not exist: %s", targetPath)
+	}
+
+	if !confirmOperation("timeshift", targetPath, "", dryRun, dryRunSampleSize) {
+		fmt.Println("❌ Operation cancelled by user.")
+		return nil
+	}
+
+	files, err := collectCameraFiles(targetPath, filter)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		fmt.Println("📭 No files from this camera in the date range")
+		return nil
+	}
+	fmt.Printf("📷 %d file(s) from the camera, recorded %s to %s\n", len(files),
+		files[0].recorded.Format("2006-01-02 15:04"), files[len(files)-1].recorded.Format("2006-01-02 15:04"))
+
+	switch {
+	case estimatePath != "":
+		if offset, err = estimateClockOffset(files, estimatePath); err != nil {
+			return err
+		}
+		fmt.Printf("⏱️  Estimated offset: %s\n", formatOffset(offset))
+	case pair != nil:
+		if offset, err = pairOffset(pair[0], pair[1]); err != nil {
+			return err
+		}
+		fmt.Printf("⏱️  Offset from %s and %s: %s\n", filepath.Base(pair[0]), filepath.Base(pair[1]), formatOffset(offset))
+	}
+	// Whole seconds, so the fractions in the SubSecTime* tags stay right
+	offset = offset.Round(time.Second)
+	if offset == 0 && zone == nil {
+		fmt.Println("✅ The camera clock is already right")
+		return nil
+	}
+
+	if dryRunSampleSize > 0 && len(files) > dryRunSampleSize {
+		files = files[:dryRunSampleSize]
+	}
+
+	startJournal("timeshift", targetPath, dryRun)
+	defer activeJournal.close()
+
+	shifted, failed, keptOffsets := 0, 0, 0
+	for _, file := range files {
+		if err := shiftFile(file, offset, zone, rename, dryRun); err != nil {
+			fmt.Printf("❌ Failed to shift %s: %v\n", filepath.Base(file.path), err)
+			failed++
+			continue
+		}
+		shifted++
+		if zone == nil && file.utcCreate.IsZero() && file.utcOffset != "" {
+			keptOffsets++
+		}
+	}
+
+	fmt.Printf("\n📊 Timeshift: %d file(s) shifted by %s, %d failed\n", shifted, formatOffset(offset), failed)
+	if keptOffsets > 0 {
+		fmt.Printf("ℹ️  %d file(s) record a UTC offset, which was kept. If the camera was set to the wrong zone rather than the wrong time, run again with --offset 0 --timezone ZONE\n", keptOffsets)
+	}
+	return nil
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"bufio"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	// Embed the IANA database so zone lookups work without system zoneinfo
+	_ "time/tzdata"
+)
+
+//go:embed timezone-cities.txt
+var timezoneCitiesFS embed.FS
+
+// timezone-boundaries.geojson.gz is timezone-boundary-builder's zones with oceans (ODbL, release 2025c
+// as packaged by tzf-rel-lite), simplified to about 500 m and keeping only the tzid property
+//
+//go:embed timezone-boundaries.geojson.gz
+var timezoneBoundariesFS embed.FS
+
+// timezoneReference is a known location and the zone it observes
+type timezoneReference struct {
+	Latitude  float64
+	Longitude float64
+	Zone      string
+	Name      string
+}
+
+// timezoneMaxReferenceDistanceKm is how far a coordinate can be from every reference point
+// before it is treated as open ocean and given a nautical zone
+const timezoneMaxReferenceDistanceKm = 800.0
+
+// earthRadiusKm is the mean Earth radius used for distance calculations
+const earthRadiusKm = 6371.0
+
+var (
+	timezoneReferences []timezoneReference
+	timezoneOnce       sync.Once
+
+	timezoneBoundaries     *rtreeNode
+	timezoneBoundariesOnce sync.Once
+
+	timezoneLocations   = make(map[string]*time.Location)
+	timezoneLocationsMu sync.Mutex
+
+	defaultZone     *time.Location
+	defaultZoneOnce sync.Once
+)
+
+// loadTimezoneReferences loads the embedded timezone reference table
+func loadTimezoneReferences() {
+	timezoneOnce.Do(func() {
+		file, err := timezoneCitiesFS.Open("timezone-cities.txt")
+		if err != nil {
+			fmt.Printf("Warning: Could not load timezone reference table: %v\n", err)
+			return
+		}
+		defer file.Close()
+
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+
+			// latitude longitude zone name...
+			fields := strings.Fields(line)
+			if len(fields) < 3 {
+				continue
+			}
+			lat, latErr := strconv.ParseFloat(fields[0], 64)
+			lon, lonErr := strconv.ParseFloat(fields[1], 64)
+			if latErr != nil || lonErr != nil {
+				continue
+			}
+
+			timezoneReferences = append(timezoneReferences, timezoneReference{
+				Latitude:  lat,
+				Longitude: lon,
+				Zone:      fields[2],
+				Name:      strings.Join(fields[3:], " "),
+			})
+		}
+
+		if err := scanner.Err(); err != nil {
+			fmt.Printf("Warning: Error reading timezone reference table: %v\n", err)
+		}
+	})
+}
+
+// loadTimezoneBoundaries indexes the embedded timezone polygons in an R-tree
+func loadTimezoneBoundaries() {
+	timezoneBoundariesOnce.Do(func() {
+		data, err := loadGzipAsset(timezoneBoundariesFS, "timezone-boundaries.geojson.gz")
+		if err != nil {
+			fmt.Printf("⚠️  Warning: Could not load timezone boundaries: %v\n", err)
+			return
+		}
+		root, err := parseTimezoneBoundaries(data)
+		if err != nil {
+			fmt.Printf("⚠️  Warning: Could not load timezone boundaries: %v\n", err)
+			return
+		}
+		timezoneBoundaries = root
+	})
+}
+
+// parseTimezoneBoundaries reads zone polygons from a GeoJSON FeatureCollection whose features carry a tzid
+func parseTimezoneBoundaries(data []byte) (*rtreeNode, error) {
+	var collection geoJSONFeatureCollection
+	if err := json.Unmarshal(data, &collection); err != nil {
+		return nil, fmt.Errorf("failed to parse timezone boundaries: %v", err)
+	}
+
+	var leaves []*rtreeNode
+	for _, feature := range collection.Features {
+		zone, _ := feature.Properties["tzid"].(string)
+		if zone == "" {
+			continue
+		}
+		polygons, err := feature.Geometry.polygons()
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s for %s: %v", strings.ToLower(feature.Geometry.Type), zone, err)
+		}
+		for _, rings := range polygons {
+			if len(rings) == 0 || len(rings[0]) < 3 {
+				continue
+			}
+			polygon := &boundaryPolygon{zone: zone, rings: rings, bounds: ringBounds(rings[0])}
+			leaves = append(leaves, &rtreeNode{bounds: polygon.bounds, polygon: polygon})
+		}
+	}
+
+	if len(leaves) == 0 {
+		return nil, fmt.Errorf("no timezone polygons found")
+	}
+	return buildRTree(leaves), nil
+}
+
+// haversineKm returns the great-circle distance between two coordinates in kilometres
+func haversineKm(lat1, lon1, lat2, lon2 float64) float64 {
+	toRad := math.Pi / 180
+	dLat := (lat2 - lat1) * toRad
+	dLon := (lon2 - lon1) * toRad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*toRad)*math.Cos(lat2*toRad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// timezoneNameForCoordinates returns the IANA zone name observed at the given coordinates: the zone
+// whose boundary contains them, else the zone of the nearest reference location
+func timezoneNameForCoordinates(lat, lon float64) string {
+	loadTimezoneBoundaries()
+	if timezoneBoundaries != nil {
+		if polygon := timezoneBoundaries.find(lat, lon); polygon != nil {
+			return polygon.zone
+		}
+	}
+
+	// Simplifying the polygons leaves slivers along borders and coasts that no zone covers
+	loadTimezoneReferences()
+
+	bestZone := ""
+	bestDistance := math.MaxFloat64
+	for _, ref := range timezoneReferences {
+		distance := haversineKm(lat, lon, ref.Latitude, ref.Longitude)
+		if distance < bestDistance {
+			bestDistance = distance
+			bestZone = ref.Zone
+		}
+	}
+
+	if bestZone == "" || bestDistance > timezoneMaxReferenceDistanceKm {
+		return nauticalZoneName(lon)
+	}
+	return bestZone
+}
+
+// nauticalZoneName returns the Etc/GMT zone for a longitude (note the POSIX sign inversion)
+func nauticalZoneName(lon float64) string {
+	offset := int(math.Round(lon / 15))
+	if offset == 0 {
+		return "Etc/GMT"
+	}
+	return fmt.Sprintf("Etc/GMT%+d", -offset)
+}
+
+// timezoneForCoordinates returns the location observed at the given coordinates
+func timezoneForCoordinates(lat, lon float64) *time.Location {
+	zone := timezoneNameForCoordinates(lat, lon)
+
+	timezoneLocationsMu.Lock()
+	defer timezoneLocationsMu.Unlock()
+
+	if loc, exists := timezoneLocations[zone]; exists {
+		return loc
+	}
+
+	loc, err := time.LoadLocation(zone)
+	if err != nil {
+		// Unknown zone name in the table - fall back to the nautical zone
+		loc, err = time.LoadLocation(nauticalZoneName(lon))
+		if err != nil {
+			loc = time.UTC
+		}
+	}
+	timezoneLocations[zone] = loc
+	return loc
+}
+
+// defaultTimezone returns the configured default zone, or the system zone if none is set
+func defaultTimezone() *time.Location {
+	defaultZoneOnce.Do(func() {
+		defaultZone = time.Local
+
+		name := GetAppConfig().DefaultTimezone
+		if name == "" {
+			return
+		}
+
+		loc, err := time.LoadLocation(name)
+		if err != nil {
+			fmt.Printf("⚠️  Warning: Unknown default timezone '%s', using system timezone: %v\n", name, err)
+			return
+		}
+		defaultZone = loc
+	})
+
+	return defaultZone
+}
+
+// parseUTCOffset parses an EXIF offset like "+02:00", "-0530" or "Z" into a fixed zone
+func parseUTCOffset(offset string) (*time.Location, bool) {
+	offset = strings.TrimSpace(offset)
+	if offset == "Z" {
+		return time.UTC, true
+	}
+	if len(offset) < 3 || (offset[0] != '+' && offset[0] != '-') {
+		return nil, false
+	}
+
+	digits := strings.ReplaceAll(offset[1:], ":", "")
+	if len(digits) != 2 && len(digits) != 4 {
+		return nil, false
+	}
+	hours, err := strconv.Atoi(digits[:2])
+	if err != nil || hours > 14 {
+		return nil, false
+	}
+	minutes := 0
+	if len(digits) == 4 {
+		if minutes, err = strconv.Atoi(digits[2:]); err != nil || minutes > 59 {
+			return nil, false
+		}
+	}
+
+	seconds := hours*3600 + minutes*60
+	if offset[0] == '-' {
+		seconds = -seconds
+	}
+	return time.FixedZone(offset, seconds), true
+}
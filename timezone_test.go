@@ -0,0 +1,46 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimezoneNameForCoordinates(t *testing.T) {
+	tests := []struct {
+		name     string
+		lat, lon float64
+		want     string
+	}{
+		{"Paris", 48.8582, 2.2945, "Europe/Paris"},
+		{"Phoenix keeps standard time", 33.45, -112.07, "America/Phoenix"},
+		{"Kathmandu", 27.70, 85.30, "Asia/Kathmandu"},
+		{"Ushuaia", -54.80, -68.30, "America/Argentina/Ushuaia"},
+		{"Indiana side of the Illinois line", 41.60, -87.40, "America/Chicago"},
+		{"Mid-Atlantic", 30.0, -40.0, "Etc/GMT+3"},
+	}
+
+	for _, tt := range tests {
+		if got := timezoneNameForCoordinates(tt.lat, tt.lon); got != tt.want {
+			t.Errorf("%s: timezoneNameForCoordinates(%v, %v) = %s, want %s", tt.name, tt.lat, tt.lon, got, tt.want)
+		}
+	}
+}
+
+func TestTimezoneBoundaryZonesLoad(t *testing.T) {
+	loadTimezoneBoundaries()
+	if timezoneBoundaries == nil {
+		t.Fatal("timezone boundaries did not load")
+	}
+	var check func(n *rtreeNode)
+	check = func(n *rtreeNode) {
+		if n.polygon != nil {
+			if _, err := time.LoadLocation(n.polygon.zone); err != nil {
+				t.Errorf("zone %s: %v", n.polygon.zone, err)
+			}
+		}
+		for _, child := range n.children {
+			check(child)
+		}
+	}
+	check(timezoneBoundaries)
+}
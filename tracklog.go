@@ -0,0 +1,332 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// trackPoint is a recorded position
+type trackPoint struct {
+	Time     time.Time // UTC
+	Lat, Lon float64
+}
+
+// trackLog is every point of the loaded track files, sorted by time
+type trackLog struct {
+	points []trackPoint
+}
+
+// loadTrackLog reads GPX, KML and GeoJSON track files into one time-ordered log
+func loadTrackLog(paths []string) (*trackLog, error) {
+	track := &trackLog{}
+	for _, path := range paths {
+		var points []trackPoint
+		var err error
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".gpx":
+			points, err = readGPXTrack(path)
+		case ".kml":
+			points, err = readKMLTrack(path)
+		case ".geojson", ".json":
+			points, err = readGeoJSONTrack(path)
+		default:
+			return nil, fmt.Errorf("unsupported track file %s (use .gpx, .kml or .geojson)", path)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read track %s: %v", path, err)
+		}
+		if len(points) == 0 {
+			fmt.Printf("⚠️  Warning: No timed points in track %s\n", path)
+		}
+		track.points = append(track.points, points...)
+	}
+
+	if len(track.points) == 0 {
+		return nil, fmt.Errorf("no timed track points found")
+	}
+	sort.SliceStable(track.points, func(i, j int) bool {
+		return track.points[i].Time.Before(track.points[j].Time)
+	})
+	return track, nil
+}
+
+// positionAt returns the position at the instant. Between two points that are both within
+// maxGap of it the position is interpolated; otherwise the one point within maxGap is used
+func (t *trackLog) positionAt(instant time.Time, maxGap time.Duration) (lat, lon float64, found bool) {
+	points := t.points
+	next := sort.Search(len(points), func(i int) bool {
+		return !points[i].Time.Before(instant)
+	})
+
+	var before, after *trackPoint
+	if next > 0 && instant.Sub(points[next-1].Time) <= maxGap {
+		before = &points[next-1]
+	}
+	if next < len(points) && points[next].Time.Sub(instant) <= maxGap {
+		after = &points[next]
+	}
+
+	switch {
+	case before != nil && after != nil:
+		span := after.Time.Sub(before.Time)
+		if span <= 0 {
+			return after.Lat, after.Lon, true
+		}
+		fraction := float64(instant.Sub(before.Time)) / float64(span)
+		return before.Lat + (after.Lat-before.Lat)*fraction, before.Lon + (after.Lon-before.Lon)*fraction, true
+	case before != nil:
+		return before.Lat, before.Lon, true
+	case after != nil:
+		return after.Lat, after.Lon, true
+	}
+	return 0, 0, false
+}
+
+// span returns the first and last recorded times
+func (t *trackLog) span() (time.Time, time.Time) {
+	return t.points[0].Time, t.points[len(t.points)-1].Time
+}
+
+// gpxFile is the part of a GPX file the track loader reads
+type gpxFile struct {
+	Tracks []struct {
+		Segments []struct {
+			Points []gpxPoint `xml:"trkpt"`
+		} `xml:"trkseg"`
+	} `xml:"trk"`
+	Routes []struct {
+		Points []gpxPoint `xml:"rtept"`
+	} `xml:"rte"`
+	Waypoints []gpxPoint `xml:"wpt"`
+}
+
+// gpxPoint is a GPX track, route or waypoint
+type gpxPoint struct {
+	Lat  float64 `xml:"lat,attr"`
+	Lon  float64 `xml:"lon,attr"`
+	Time string  `xml:"time"`
+}
+
+// readGPXTrack reads the timed points of a GPX file
+func readGPXTrack(path string) ([]trackPoint, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var gpx gpxFile
+	if err := xml.Unmarshal(data, &gpx); err != nil {
+		return nil, err
+	}
+
+	var raw []gpxPoint
+	for _, track := range gpx.Tracks {
+		for _, segment := range track.Segments {
+			raw = append(raw, segment.Points...)
+		}
+	}
+	for _, route := range gpx.Routes {
+		raw = append(raw, route.Points...)
+	}
+	raw = append(raw, gpx.Waypoints...)
+
+	var points []trackPoint
+	for _, point := range raw {
+		if when, ok := parseTrackTime(point.Time); ok {
+			points = append(points, trackPoint{Time: when, Lat: point.Lat, Lon: point.Lon})
+		}
+	}
+	return points, nil
+}
+
+// readKMLTrack reads gx:Track elements (<when> and <gx:coord> lists) and placemarks
+// with a <TimeStamp> and a point, as exported by Google Location History and most trackers
+func readKMLTrack(path string) ([]trackPoint, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var points []trackPoint
+	var whens, coords []string
+	var placemarkWhen, placemarkCoords string
+	inTrack, inPlacemark, inPoint := false, false, false
+	var text strings.Builder
+
+	decoder := xml.NewDecoder(file)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch element := token.(type) {
+		case xml.StartElement:
+			text.Reset()
+			switch element.Name.Local {
+			case "Track":
+				inTrack, whens, coords = true, nil, nil
+			case "Placemark":
+				inPlacemark, placemarkWhen, placemarkCoords = true, "", ""
+			case "Point":
+				inPoint = true
+			}
+		case xml.CharData:
+			text.Write(element)
+		case xml.EndElement:
+			value := strings.TrimSpace(text.String())
+			switch element.Name.Local {
+			case "when":
+				if inTrack {
+					whens = append(whens, value)
+				} else if inPlacemark {
+					placemarkWhen = value
+				}
+			case "coord":
+				if inTrack {
+					coords = append(coords, value)
+				}
+			case "coordinates":
+				if inPoint {
+					placemarkCoords = value
+				}
+			case "Point":
+				inPoint = false
+			case "Track":
+				inTrack = false
+				for i := 0; i < len(whens) && i < len(coords); i++ {
+					if point, ok := kmlPoint(whens[i], strings.Fields(coords[i])); ok {
+						points = append(points, point)
+					}
+				}
+			case "Placemark":
+				inPlacemark = false
+				if placemarkWhen != "" && placemarkCoords != "" {
+					if point, ok := kmlPoint(placemarkWhen, strings.Split(placemarkCoords, ",")); ok {
+						points = append(points, point)
+					}
+				}
+			}
+			text.Reset()
+		}
+	}
+	return points, nil
+}
+
+// kmlPoint builds a point from a KML time and longitude, latitude[, altitude] fields
+func kmlPoint(when string, fields []string) (trackPoint, bool) {
+	moment, ok := parseTrackTime(when)
+	if !ok || len(fields) < 2 {
+		return trackPoint{}, false
+	}
+	lon, lonErr := strconv.ParseFloat(strings.TrimSpace(fields[0]), 64)
+	lat, latErr := strconv.ParseFloat(strings.TrimSpace(fields[1]), 64)
+	if lonErr != nil || latErr != nil {
+		return trackPoint{}, false
+	}
+	return trackPoint{Time: moment, Lat: lat, Lon: lon}, true
+}
+
+// readGeoJSONTrack reads LineString/MultiLineString features with a "coordTimes" property
+// (the layout of togeojson and most converters) and Point features with a "time" property
+func readGeoJSONTrack(path string) ([]trackPoint, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var collection geoJSONFeatureCollection
+	if err := json.Unmarshal(data, &collection); err != nil {
+		return nil, err
+	}
+
+	var points []trackPoint
+	for _, feature := range collection.Features {
+		switch feature.Geometry.Type {
+		case "Point":
+			var coordinate []float64
+			if err := json.Unmarshal(feature.Geometry.Coordinates, &coordinate); err != nil || len(coordinate) < 2 {
+				continue
+			}
+			for _, key := range []string{"time", "timestamp", "when"} {
+				if value, ok := feature.Properties[key].(string); ok {
+					if when, ok := parseTrackTime(value); ok {
+						points = append(points, trackPoint{Time: when, Lat: coordinate[1], Lon: coordinate[0]})
+					}
+					break
+				}
+			}
+		case "LineString", "MultiLineString":
+			var lines [][][]float64
+			var times [][]string
+			if feature.Geometry.Type == "LineString" {
+				var line [][]float64
+				if err := json.Unmarshal(feature.Geometry.Coordinates, &line); err != nil {
+					continue
+				}
+				lines = [][][]float64{line}
+				times = [][]string{geoJSONStrings(feature.Properties["coordTimes"])}
+			} else {
+				if err := json.Unmarshal(feature.Geometry.Coordinates, &lines); err != nil {
+					continue
+				}
+				if nested, ok := feature.Properties["coordTimes"].([]interface{}); ok {
+					for _, lineTimes := range nested {
+						times = append(times, geoJSONStrings(lineTimes))
+					}
+				}
+			}
+
+			for i, line := range lines {
+				if i >= len(times) {
+					break
+				}
+				for j, coordinate := range line {
+					if j >= len(times[i]) || len(coordinate) < 2 {
+						break
+					}
+					if when, ok := parseTrackTime(times[i][j]); ok {
+						points = append(points, trackPoint{Time: when, Lat: coordinate[1], Lon: coordinate[0]})
+					}
+				}
+			}
+		}
+	}
+	return points, nil
+}
+
+// geoJSONStrings converts a decoded JSON array of strings
+func geoJSONStrings(value interface{}) []string {
+	items, ok := value.([]interface{})
+	if !ok {
+		return nil
+	}
+	strs := make([]string, len(items))
+	for i, item := range items {
+		strs[i], _ = item.(string)
+	}
+	return strs
+}
+
+// parseTrackTime parses an ISO 8601 track timestamp into UTC
+func parseTrackTime(value string) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05Z0700", "2006-01-02T15:04:05"} {
+		if when, err := time.Parse(layout, value); err == nil {
+			return when.UTC(), true
+		}
+	}
+	return time.Time{}, false
+}
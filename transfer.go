@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Transfer modes for --transfer: how a file gets from the source to its place in the library
+const (
+	transferMove     = "move"
+	transferCopy     = "copy"
+	transferHardlink = "hardlink"
+	transferSymlink  = "symlink"
+	transferReflink  = "reflink" // A copy sharing the source's blocks until either is changed (btrfs, XFS)
+)
+
+// isValidTransferMode reports whether mode is a known transfer mode
+func isValidTransferMode(mode string) bool {
+	switch mode {
+	case transferMove, transferCopy, transferHardlink, transferSymlink, transferReflink:
+		return true
+	}
+	return false
+}
+
+// transferModeFor returns the configured transfer mode, or the command's own default when none is set
+// (merge copies, the other commands move)
+func transferModeFor(defaultMode string) string {
+	if mode := GetAppConfig().TransferMode; mode != "" {
+		return mode
+	}
+	return defaultMode
+}
+
+// cleanupSourceIfMoved cleans up the source folders a filing run emptied. Every other transfer
+// mode leaves the source untouched, so it is only done when files were moved
+func cleanupSourceIfMoved(operationType, sourcePath string, dryRun bool) {
+	if transferModeFor(transferMove) != transferMove {
+		return
+	}
+	cleanupEmptyDirectoriesIfNeeded(operationType, sourcePath, dryRun, -1)
+}
+
+// transferFile puts the file at src in place at dst, which must not exist yet, and journals it.
+// Every mode but move leaves the source untouched
+func transferFile(src, dst, mode string) error {
+	var err error
+	switch mode {
+	case transferCopy:
+		err = copyFile(src, dst)
+	case transferHardlink:
+		if err = os.Link(src, dst); err != nil {
+			err = fmt.Errorf("hard link failed (source and destination must be on one filesystem): %v", err)
+		}
+	case transferSymlink:
+		// Absolute, so the link still resolves from anywhere in the library
+		var target string
+		if target, err = filepath.Abs(src); err == nil {
+			err = os.Symlink(target, dst)
+		}
+	case transferReflink:
+		err = reflinkFile(src, dst)
+	default:
+		mode = transferMove
+		err = safeFileMove(src, dst)
+	}
+	if err != nil {
+		return err
+	}
+
+	activeJournal.transferred(mode, src, dst)
+	return nil
+}
+
+// transferVerb describes a transfer in log output
+func transferVerb(mode string) string {
+	switch mode {
+	case transferCopy:
+		return "copied"
+	case transferHardlink:
+		return "hard-linked"
+	case transferSymlink:
+		return "symlinked"
+	case transferReflink:
+		return "reflinked"
+	}
+	return "moved"
+}
@@ -0,0 +1,43 @@
+//go:build linux
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// ficlone is the FICLONE ioctl, which makes a file share another's extents
+const ficlone = 0x40049409
+
+// reflinkFile clones src to dst with FICLONE, so the copy takes no space until one of them changes
+func reflinkFile(src, dst string) error {
+	source, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to open source file: %v", err)
+	}
+	defer source.Close()
+
+	info, err := source.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat source file: %v", err)
+	}
+
+	dest, err := os.OpenFile(dst, os.O_CREATE|os.O_EXCL|os.O_WRONLY, info.Mode().Perm())
+	if err != nil {
+		return fmt.Errorf("failed to create destination file: %v", err)
+	}
+
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, dest.Fd(), ficlone, source.Fd()); errno != 0 {
+		dest.Close()
+		os.Remove(dst)
+		return fmt.Errorf("reflink failed (needs btrfs, XFS or another filesystem with reflinks, with source and destination on it): %v", errno)
+	}
+	if err := dest.Close(); err != nil {
+		return err
+	}
+
+	// A clone is a new file; keep the source's modification time
+	return os.Chtimes(dst, info.ModTime(), info.ModTime())
+}
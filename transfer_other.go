@@ -0,0 +1,10 @@
+//go:build !linux
+
+package main
+
+import "fmt"
+
+// reflinkFile is only implemented for Linux (FICLONE)
+func reflinkFile(src, dst string) error {
+	return fmt.Errorf("reflinks are only supported on Linux; use --transfer copy or hardlink")
+}
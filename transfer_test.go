@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTransferFileNeverReplacesTarget(t *testing.T) {
+	for _, mode := range []string{transferMove, transferCopy} {
+		t.Run(mode, func(t *testing.T) {
+			dir := t.TempDir()
+			first, second, target := filepath.Join(dir, "a.jpg"), filepath.Join(dir, "b.jpg"), filepath.Join(dir, "target.jpg")
+			if err := os.WriteFile(first, []byte("first"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(second, []byte("second"), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := transferFile(first, target, mode); err != nil {
+				t.Fatalf("first transfer failed: %v", err)
+			}
+			if err := transferFile(second, target, mode); err == nil {
+				t.Error("second transfer to the same target succeeded")
+			}
+			if content, _ := os.ReadFile(target); string(content) != "first" {
+				t.Errorf("target holds %q, want the first file", content)
+			}
+			if _, err := os.Stat(second); err != nil {
+				t.Errorf("the second file is gone: %v", err)
+			}
+		})
+	}
+}
+
+func TestLayoutDestinationConcurrentClaims(t *testing.T) {
+	dest := t.TempDir()
+	source := t.TempDir()
+	day := time.Date(2023, 7, 14, 0, 0, 0, 0, time.UTC)
+
+	const files = 8
+	targets := make([]string, files)
+	var wg sync.WaitGroup
+	for i := 0; i < files; i++ {
+		path := filepath.Join(source, fmt.Sprintf("IMG_%04d.jpg", i))
+		if err := os.WriteFile(path, []byte(fmt.Sprintf("burst shot %d", i)), 0644); err != nil {
+			t.Fatal(err)
+		}
+		wg.Add(1)
+		go func(i int, path string) {
+			defer wg.Done()
+			target, err := layoutDestination(dest, newLayoutFields(path, day, "France", "Paris"))
+			if err != nil {
+				t.Errorf("layoutDestination(%s) failed: %v", path, err)
+			}
+			targets[i] = target
+		}(i, path)
+	}
+	wg.Wait()
+
+	seen := make(map[string]bool)
+	for _, target := range targets {
+		if seen[target] {
+			t.Errorf("%s was given to two files", target)
+		}
+		seen[target] = true
+	}
+}
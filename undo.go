@@ -0,0 +1,361 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ChangedFileError means a file was changed after the run, so undo leaves it alone
+type ChangedFileError struct {
+	Reason string
+}
+
+func (e *ChangedFileError) Error() string {
+	return e.Reason
+}
+
+// isChangedFileError checks if an error is a ChangedFileError
+func isChangedFileError(err error) bool {
+	_, ok := err.(*ChangedFileError)
+	return ok
+}
+
+// undoStats counts the outcome of an undo
+type undoStats struct {
+	undone, changed, failed int
+}
+
+// runUndoCommand parses the undo arguments and lists the journal's runs or undoes one of them
+func runUndoCommand(args []string) error {
+	var root, runID string
+	var only []string
+	last := 0
+	dryRun := false
+
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--dry-run":
+			dryRun = true
+		case "--only":
+			if i+1 >= len(args) {
+				return fmt.Errorf("--only requires a path")
+			}
+			i++
+			only = append(only, absPath(args[i]))
+		case "--last":
+			if i+1 >= len(args) {
+				return fmt.Errorf("--last requires a count")
+			}
+			i++
+			n, err := strconv.Atoi(args[i])
+			if err != nil || n < 1 {
+				return fmt.Errorf("invalid --last count: %s", args[i])
+			}
+			last = n
+		default:
+			if strings.HasPrefix(args[i], "--") {
+				return fmt.Errorf("unknown undo option %s", args[i])
+			}
+			switch {
+			case root == "":
+				root = args[i]
+			case runID == "":
+				runID = args[i]
+			default:
+				return fmt.Errorf("unexpected argument %s", args[i])
+			}
+		}
+	}
+
+	if root == "" {
+		return fmt.Errorf("usage: undo /destination/path [RUN-ID] [--only PATH] [--last N] [--dry-run]")
+	}
+	root = absPath(root)
+	entries, err := readJournal(root)
+	if err != nil {
+		return fmt.Errorf("failed to read the journal: %v", err)
+	}
+	if len(entries) == 0 {
+		return fmt.Errorf("no journal in %s", root)
+	}
+
+	if runID == "" {
+		listJournalRuns(entries)
+		return nil
+	}
+
+	operations := undoableOperations(entries, runID, only)
+	if operations == nil {
+		return fmt.Errorf("no operations of run %s left to undo - run undo without a run ID to list the runs", runID)
+	}
+	if last > 0 && len(operations) > last {
+		operations = operations[len(operations)-last:]
+	}
+
+	fmt.Printf("↩️  Undoing %d operation(s) of run %s\n", len(operations), runID)
+	if !confirmOperation("undo", "", root, dryRun, 0) {
+		fmt.Println("❌ Operation cancelled by user.")
+		return nil
+	}
+
+	// The undo is journaled even with --no-journal: its entries mark what is already undone
+	var j *journal
+	if !dryRun {
+		if j, err = openJournal(root); err != nil {
+			return fmt.Errorf("failed to open the journal: %v", err)
+		}
+		j.record(journalEntry{Op: journalRun, Command: strings.Join(os.Args[1:], " ")})
+		defer j.file.Close()
+	}
+
+	var stats undoStats
+	previewed := make(map[string]bool) // Dry run: files a later operation was already undone on
+	for i := len(operations) - 1; i >= 0; i-- {
+		entry := operations[i]
+		if err := undoOperation(entry, dryRun, !previewed[entry.Target]); err != nil {
+			if isChangedFileError(err) {
+				fmt.Printf("⚠️  Skipping %s: %v\n", entry.Target, err)
+				stats.changed++
+			} else {
+				fmt.Printf("❌ Failed to undo %s of %s: %v\n", entry.Op, entry.Target, err)
+				stats.failed++
+			}
+			continue
+		}
+		stats.undone++
+		previewed[entry.Target] = dryRun
+		j.record(journalEntry{
+			Op:     journalUndone,
+			Source: entry.Target,
+			Target: entry.Source,
+			Undoes: fmt.Sprintf("%s:%d", entry.Run, entry.Seq),
+		})
+	}
+
+	if dryRun {
+		fmt.Printf("\n📊 Undo [DRY RUN]: %d operation(s) would be undone, %d changed since the run, %d would fail\n",
+			stats.undone, stats.changed, stats.failed)
+		return nil
+	}
+	fmt.Printf("\n📊 Undo: %d operation(s) undone, %d changed since the run and left alone, %d failed\n",
+		stats.undone, stats.changed, stats.failed)
+	return nil
+}
+
+// listJournalRuns prints each run of the journal with its operation counts
+func listJournalRuns(entries []journalEntry) {
+	undone := undoneOperations(entries)
+	type runSummary struct {
+		start                journalEntry
+		operations, reverted int
+	}
+	var order []string
+	runs := make(map[string]*runSummary)
+	for _, entry := range entries {
+		run, exists := runs[entry.Run]
+		if !exists {
+			run = &runSummary{start: entry}
+			runs[entry.Run] = run
+			order = append(order, entry.Run)
+		}
+		switch {
+		case entry.Op == journalRun:
+			run.start = entry
+		case entry.Op == journalUndone, entry.Op == journalImported:
+		case undone[fmt.Sprintf("%s:%d", entry.Run, entry.Seq)]:
+			run.operations++
+			run.reverted++
+		default:
+			run.operations++
+		}
+	}
+
+	fmt.Printf("📒 Journal runs (newest last):\n")
+	for _, id := range order {
+		run := runs[id]
+		if run.operations == 0 {
+			continue // Undo runs and runs that changed nothing
+		}
+		fmt.Printf("  %s  %s  %-40s %d operation(s)", id, run.start.Time.Local().Format("2006-01-02 15:04"), run.start.Command, run.operations)
+		if run.reverted > 0 {
+			fmt.Printf(", %d undone", run.reverted)
+		}
+		fmt.Println()
+	}
+	fmt.Println("\nUndo a run with: ./photo-meta undo /destination/path RUN-ID [--dry-run]")
+}
+
+// undoneOperations returns the RUN:SEQ keys of operations already undone
+func undoneOperations(entries []journalEntry) map[string]bool {
+	undone := make(map[string]bool)
+	for _, entry := range entries {
+		if entry.Op == journalUndone {
+			undone[entry.Undoes] = true
+		}
+	}
+	return undone
+}
+
+// undoableOperations returns the run's operations, oldest first, that are not undone yet and
+// touch one of the only paths (all when none are given)
+func undoableOperations(entries []journalEntry, runID string, only []string) []journalEntry {
+	undone := undoneOperations(entries)
+	var operations []journalEntry
+	for _, entry := range entries {
+		if entry.Run != runID || entry.Op == journalRun || entry.Op == journalUndone || entry.Op == journalImported {
+			continue
+		}
+		if undone[fmt.Sprintf("%s:%d", entry.Run, entry.Seq)] {
+			continue
+		}
+		if len(only) > 0 && !underAny(entry.Target, only) && !underAny(entry.Source, only) {
+			continue
+		}
+		operations = append(operations, entry)
+	}
+	return operations
+}
+
+// underAny reports whether path is one of the paths or inside one of them
+func underAny(path string, paths []string) bool {
+	if path == "" {
+		return false
+	}
+	for _, p := range paths {
+		if path == p || strings.HasPrefix(path, p+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// undoOperation reverses one journaled operation; a ChangedFileError leaves the file alone.
+// verify is false in a dry run once a later operation on the target was previewed, since the
+// target then still holds that operation's result
+func undoOperation(entry journalEntry, dryRun, verify bool) error {
+	switch entry.Op {
+	case transferMove, journalRename:
+		if err := checkUnchanged(entry, verify); err != nil {
+			return err
+		}
+		if _, err := os.Lstat(entry.Source); err == nil {
+			return &ChangedFileError{fmt.Sprintf("%s exists again", entry.Source)}
+		}
+		if dryRun {
+			fmt.Printf("↩️  [DRY RUN] Would move %s back to %s\n", entry.Target, entry.Source)
+			return nil
+		}
+		if err := os.MkdirAll(filepath.Dir(entry.Source), 0755); err != nil {
+			return err
+		}
+		if err := safeFileMove(entry.Target, entry.Source); err != nil {
+			return err
+		}
+		fmt.Printf("↩️  Moved back: %s\n", entry.Source)
+
+	case transferCopy, transferHardlink, transferReflink, journalCreate:
+		if err := checkUnchanged(entry, verify); err != nil {
+			return err
+		}
+		if dryRun {
+			fmt.Printf("↩️  [DRY RUN] Would remove %s\n", entry.Target)
+			return nil
+		}
+		if err := os.Remove(entry.Target); err != nil {
+			return err
+		}
+		fmt.Printf("↩️  Removed: %s\n", entry.Target)
+
+	case transferSymlink:
+		link, err := os.Readlink(entry.Target)
+		if err != nil {
+			return &ChangedFileError{fmt.Sprintf("%s is no longer a symlink", filepath.Base(entry.Target))}
+		}
+		if !filepath.IsAbs(link) {
+			link = filepath.Join(filepath.Dir(entry.Target), link)
+		}
+		if filepath.Clean(link) != entry.Source {
+			return &ChangedFileError{fmt.Sprintf("the symlink now points to %s", link)}
+		}
+		if dryRun {
+			fmt.Printf("↩️  [DRY RUN] Would remove symlink %s\n", entry.Target)
+			return nil
+		}
+		if err := os.Remove(entry.Target); err != nil {
+			return err
+		}
+		fmt.Printf("↩️  Removed symlink: %s\n", entry.Target)
+
+	case journalDelete:
+		if _, err := os.Lstat(entry.Target); err == nil {
+			return &ChangedFileError{fmt.Sprintf("%s exists again", filepath.Base(entry.Target))}
+		}
+		if _, err := os.Stat(entry.Backup); err != nil {
+			return fmt.Errorf("backup missing: %v", err)
+		}
+		if dryRun {
+			fmt.Printf("↩️  [DRY RUN] Would restore %s\n", entry.Target)
+			return nil
+		}
+		if err := os.MkdirAll(filepath.Dir(entry.Target), 0755); err != nil {
+			return err
+		}
+		if err := safeFileMove(entry.Backup, entry.Target); err != nil {
+			return err
+		}
+		fmt.Printf("↩️  Restored: %s\n", entry.Target)
+
+	case journalEdit:
+		if err := checkUnchanged(entry, verify); err != nil {
+			return err
+		}
+		if entry.Backup == "" {
+			return fmt.Errorf("no backup of the previous content was kept")
+		}
+		if _, err := os.Stat(entry.Backup); err != nil {
+			return fmt.Errorf("backup missing: %v", err)
+		}
+		if dryRun {
+			fmt.Printf("↩️  [DRY RUN] Would restore the previous content of %s\n", entry.Target)
+			return nil
+		}
+		// Replace rather than rewrite: the backup may be a hard link to the original content
+		if err := os.Rename(entry.Backup, entry.Target); err != nil {
+			// The backup stays until the copy is complete
+			os.Remove(entry.Target)
+			if err := copyFile(entry.Backup, entry.Target); err != nil {
+				return err
+			}
+			os.Remove(entry.Backup)
+		}
+		fmt.Printf("↩️  Restored previous content: %s\n", entry.Target)
+
+	default:
+		return fmt.Errorf("unknown operation %q", entry.Op)
+	}
+	return nil
+}
+
+// checkUnchanged returns a ChangedFileError unless the target still has the hash it had after the operation
+func checkUnchanged(entry journalEntry, verify bool) error {
+	if !verify {
+		return nil
+	}
+	if _, err := os.Lstat(entry.Target); err != nil {
+		return &ChangedFileError{fmt.Sprintf("%s no longer exists", filepath.Base(entry.Target))}
+	}
+	if entry.Hash == "" {
+		return nil
+	}
+	hash, err := calculateFileHash(entry.Target)
+	if err != nil {
+		return err
+	}
+	if hash != entry.Hash {
+		return &ChangedFileError{fmt.Sprintf("%s was modified after the run", filepath.Base(entry.Target))}
+	}
+	return nil
+}